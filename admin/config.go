@@ -0,0 +1,49 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/buoyantio/bb/service"
+)
+
+// ConfigPath is where the admin server exposes the settings that can be changed at runtime
+const ConfigPath = "/config"
+
+// Reconfigurable is implemented by services whose settings can be changed while they run
+type Reconfigurable interface {
+	RuntimeConfig() *service.RuntimeConfig
+	Reconfigure(changes *service.RuntimeConfig) error
+}
+
+type configEndpoint struct {
+	service Reconfigurable
+}
+
+// NewConfigEndpoint returns an Endpoint used to read the runtime settings of a service and to change them, by
+// sending only the settings to change
+func NewConfigEndpoint(service Reconfigurable) Endpoint {
+	return &configEndpoint{service: service}
+}
+
+func (e *configEndpoint) AdminPath() string { return ConfigPath }
+
+func (e *configEndpoint) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost, http.MethodPatch:
+		var changes service.RuntimeConfig
+		if !ReadJSON(w, req, &changes) {
+			return
+		}
+		if err := e.service.Reconfigure(&changes); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, fmt.Sprintf("method [%s] not allowed", req.Method), http.StatusMethodNotAllowed)
+		return
+	}
+
+	WriteJSON(w, e.service.RuntimeConfig())
+}
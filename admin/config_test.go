@@ -0,0 +1,88 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/buoyantio/bb/service"
+)
+
+type stubReconfigurable struct {
+	config          *service.Config
+	changesReceived *service.RuntimeConfig
+	errorToReturn   error
+}
+
+func (s *stubReconfigurable) RuntimeConfig() *service.RuntimeConfig {
+	return service.NewRuntimeConfig(s.config)
+}
+
+func (s *stubReconfigurable) Reconfigure(changes *service.RuntimeConfig) error {
+	s.changesReceived = changes
+	if s.errorToReturn != nil {
+		return s.errorToReturn
+	}
+
+	config, err := changes.Apply(s.config)
+	if err != nil {
+		return err
+	}
+	s.config = config
+	return nil
+}
+
+func TestConfigEndpoint(t *testing.T) {
+	t.Run("returns the runtime config", func(t *testing.T) {
+		endpoint := NewConfigEndpoint(&stubReconfigurable{config: &service.Config{PercentageFailedRequests: 10}})
+
+		w := httptest.NewRecorder()
+		endpoint.ServeHTTP(w, httptest.NewRequest(http.MethodGet, ConfigPath, nil))
+
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"percentFailure":10`) {
+			t.Fatalf("Expected runtime config with [percentFailure: 10], got [%d] %s", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("applies the settings sent and returns the updated config", func(t *testing.T) {
+		reconfigurable := &stubReconfigurable{config: &service.Config{PercentageFailedRequests: 10}}
+		endpoint := NewConfigEndpoint(reconfigurable)
+
+		w := httptest.NewRecorder()
+		endpoint.ServeHTTP(w, httptest.NewRequest(http.MethodPut, ConfigPath, strings.NewReader(`{"sleepInMillis": 250}`)))
+
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"sleepInMillis":250`) || !strings.Contains(w.Body.String(), `"percentFailure":10`) {
+			t.Fatalf("Expected updated runtime config, got [%d] %s", w.Code, w.Body.String())
+		}
+
+		if reconfigurable.changesReceived.PercentFailure != nil || reconfigurable.changesReceived.ChangesStrategy() {
+			t.Fatalf("Expected only the settings sent to change, got %+v", reconfigurable.changesReceived)
+		}
+	})
+
+	t.Run("replies with a 400 when the settings can't be applied", func(t *testing.T) {
+		endpoint := NewConfigEndpoint(&stubReconfigurable{config: &service.Config{}, errorToReturn: errors.New("expected")})
+
+		for _, body := range []string{`{"sleepInMillis": 250}`, `not json`} {
+			w := httptest.NewRecorder()
+			endpoint.ServeHTTP(w, httptest.NewRequest(http.MethodPut, ConfigPath, strings.NewReader(body)))
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("Expecting status [%d] for body [%s], got [%d]", http.StatusBadRequest, body, w.Code)
+			}
+		}
+	})
+
+	t.Run("replies with a 405 for other methods", func(t *testing.T) {
+		endpoint := NewConfigEndpoint(&stubReconfigurable{config: &service.Config{}})
+
+		w := httptest.NewRecorder()
+		endpoint.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, ConfigPath, nil))
+
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Fatalf("Expecting status [%d], got [%d]", http.StatusMethodNotAllowed, w.Code)
+		}
+	})
+}
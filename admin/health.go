@@ -0,0 +1,34 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/buoyantio/bb/service"
+)
+
+type healthEndpoint struct {
+	path  string
+	check func() error
+}
+
+// NewLivenessEndpoint returns an Endpoint replying with a 200 while the service is alive, and a 503 otherwise
+func NewLivenessEndpoint(health *service.Health) Endpoint {
+	return &healthEndpoint{path: service.HealthzPath, check: health.Live}
+}
+
+// NewReadinessEndpoint returns an Endpoint replying with a 200 while the service is ready to receive requests, and a
+// 503 explaining why otherwise
+func NewReadinessEndpoint(health *service.Health) Endpoint {
+	return &healthEndpoint{path: service.ReadyzPath, check: health.Ready}
+}
+
+func (e *healthEndpoint) AdminPath() string { return e.path }
+
+func (e *healthEndpoint) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if err := e.check(); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
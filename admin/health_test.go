@@ -0,0 +1,37 @@
+package admin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/buoyantio/bb/service"
+)
+
+func TestHealthEndpoints(t *testing.T) {
+	t.Run("replies with a 200 while alive and ready", func(t *testing.T) {
+		health := service.NewRequestHandler(&service.Config{}).Health()
+
+		for _, endpoint := range []Endpoint{NewLivenessEndpoint(health), NewReadinessEndpoint(health)} {
+			w := httptest.NewRecorder()
+			endpoint.ServeHTTP(w, httptest.NewRequest(http.MethodGet, endpoint.AdminPath(), nil))
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expecting status [%d] from [%s], got [%d]", http.StatusOK, endpoint.AdminPath(), w.Code)
+			}
+		}
+	})
+
+	t.Run("replies with a 503 and the reason when not ready", func(t *testing.T) {
+		health := service.NewRequestHandler(&service.Config{ID: "draining"}).Health()
+		health.Drain()
+
+		w := httptest.NewRecorder()
+		NewReadinessEndpoint(health).ServeHTTP(w, httptest.NewRequest(http.MethodGet, service.ReadyzPath, nil))
+
+		if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), "shutting down") {
+			t.Fatalf("Expecting status [%d] explaining the service is shutting down, got [%d] %s", http.StatusServiceUnavailable, w.Code, w.Body.String())
+		}
+	})
+}
@@ -0,0 +1,99 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/buoyantio/bb/service"
+	log "github.com/sirupsen/logrus"
+)
+
+// Endpoint is implemented by components that can be inspected or changed at runtime via the admin server.
+type Endpoint interface {
+	http.Handler
+	AdminPath() string
+}
+
+// Server is a HTTP server exposing endpoints used to inspect and change this process at runtime.
+type Server struct {
+	httpServer *http.Server
+	mux        *http.ServeMux
+	port       int
+	mu         sync.RWMutex
+	endpoints  map[string]Endpoint
+}
+
+// GetID returns the identifier of this server
+func (s *Server) GetID() string {
+	return fmt.Sprintf("admin-%d", s.port)
+}
+
+// Shutdown stops the server
+func (s *Server) Shutdown() error {
+	log.Infof("Shutting down [%s]", s.GetID())
+	return s.httpServer.Shutdown(context.Background())
+}
+
+// Register makes the Endpoint available at its admin path, replacing any Endpoint previously registered there
+func (s *Server) Register(endpoint Endpoint) {
+	log.Infof("Serving admin endpoint [%s] on [%s]", endpoint.AdminPath(), s.GetID())
+	path := endpoint.AdminPath()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.endpoints == nil {
+		s.endpoints = map[string]Endpoint{}
+	}
+	if _, registered := s.endpoints[path]; !registered {
+		s.mux.HandleFunc(path, func(w http.ResponseWriter, req *http.Request) {
+			s.mu.RLock()
+			endpoint := s.endpoints[path]
+			s.mu.RUnlock()
+			endpoint.ServeHTTP(w, req)
+		})
+	}
+	s.endpoints[path] = endpoint
+}
+
+// NewServerIfConfigured returns an admin Server listening on the configured admin port, if any
+func NewServerIfConfigured(config *service.Config) (*Server, error) {
+	if config.AdminPort == -1 {
+		return nil, nil
+	}
+
+	mux := http.NewServeMux()
+	srv := &http.Server{
+		Addr:    config.BindAddress(config.AdminPort),
+		Handler: mux,
+	}
+	go func() {
+		log.Infof("Admin server listening on port [%d]", config.AdminPort)
+		srv.ListenAndServe()
+	}()
+
+	return &Server{
+		httpServer: srv,
+		mux:        mux,
+		port:       config.AdminPort,
+	}, nil
+}
+
+// WriteJSON writes the value supplied as the JSON body of the response
+func WriteJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		log.Errorf("Error writing admin response: %v", err)
+	}
+}
+
+// ReadJSON reads the JSON body of the request into the value supplied, replying with a 400 if it can't
+func ReadJSON(w http.ResponseWriter, req *http.Request, value interface{}) bool {
+	if err := json.NewDecoder(req.Body).Decode(value); err != nil {
+		http.Error(w, fmt.Sprintf("error unmarshalling the request: %v", err), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
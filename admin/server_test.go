@@ -0,0 +1,85 @@
+package admin
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/buoyantio/bb/service"
+)
+
+type stubEndpoint struct{}
+
+func (s *stubEndpoint) AdminPath() string { return "/stub" }
+
+func (s *stubEndpoint) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	WriteJSON(w, map[string]string{"path": req.URL.Path})
+}
+
+type otherStubEndpoint struct{}
+
+func (s *otherStubEndpoint) AdminPath() string { return "/stub" }
+
+func (s *otherStubEndpoint) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	WriteJSON(w, map[string]string{"endpoint": "other"})
+}
+
+func TestServer(t *testing.T) {
+	t.Run("isn't created unless an admin port is configured", func(t *testing.T) {
+		server, err := NewServerIfConfigured(&service.Config{AdminPort: -1})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if server != nil {
+			t.Fatalf("Expected no admin server, but got [%v]", server)
+		}
+	})
+
+	t.Run("serves registered endpoints at their path", func(t *testing.T) {
+		server := &Server{mux: http.NewServeMux()}
+		server.Register(&stubEndpoint{})
+
+		theServer := httptest.NewServer(server.mux)
+		defer theServer.Close()
+
+		resp, err := http.Get(theServer.URL + "/stub")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		defer resp.Body.Close()
+
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		expectedBody := "{\"path\":\"/stub\"}\n"
+		if string(body) != expectedBody {
+			t.Fatalf("Expected body [%s], but got [%s]", expectedBody, string(body))
+		}
+
+		resp, err = http.Get(theServer.URL + "/unknown")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("Expecting response to have status [%d] but was: %v", http.StatusNotFound, resp)
+		}
+	})
+	t.Run("replaces endpoints registered again at the same path", func(t *testing.T) {
+		server := &Server{mux: http.NewServeMux()}
+		server.Register(&stubEndpoint{})
+		server.Register(&otherStubEndpoint{})
+
+		w := httptest.NewRecorder()
+		server.mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/stub", nil))
+
+		expectedBody := "{\"endpoint\":\"other\"}\n"
+		if w.Body.String() != expectedBody {
+			t.Fatalf("Expected body [%s], but got [%s]", expectedBody, w.Body.String())
+		}
+	})
+}
@@ -1,25 +1,30 @@
 package cmd
 
 import (
+	"fmt"
+
 	"github.com/buoyantio/bb/strategies"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
+var completionMode string
+
 var broadcastChannelCmd = &cobra.Command{
 	Use:     strategies.BroadcastChannelStrategyName,
 	Short:   "Forwards the request to all downstream services.",
 	Example: "bb broadcast-channel --h1-downstream-server http://localhost:9090 --grpc-downstream-server localhost:9091 --h1-server-port 9092",
 
 	Run: func(cmd *cobra.Command, args []string) {
-		svc, err := newService(config, strategies.BroadcastChannelStrategyName)
+		config.ExtraArguments[strategies.BroadcastChannelCompletionModeArgName] = completionMode
+		err := newService(config, strategies.BroadcastChannelStrategyName)
 		if err != nil {
 			log.Fatalln(err)
 		}
-		defer svc.Close()
 	},
 }
 
 func init() {
 	RootCmd.AddCommand(broadcastChannelCmd)
+	broadcastChannelCmd.PersistentFlags().StringVar(&completionMode, strategies.BroadcastChannelCompletionModeArgName, strategies.BroadcastAllCompletionMode, fmt.Sprintf("when the broadcast completes: [%s] waits for every downstream service and fails if any failed, [%s] returns the first successful response, [%s=N] returns once N downstream services succeeded, [%s] waits for every downstream service and reports errors in the payload", strategies.BroadcastAllCompletionMode, strategies.BroadcastFirstSuccessCompletionMode, strategies.BroadcastQuorumCompletionMode, strategies.BroadcastBestEffortCompletionMode))
 }
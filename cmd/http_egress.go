@@ -18,12 +18,11 @@ var httpEgressCmd = &cobra.Command{
 		config.ExtraArguments[strategies.HTTPEgressURLToInvokeArgName] = urlToInvoke
 		config.ExtraArguments[strategies.HTTPEgressHTTPMethodToUseArgName] = methodToUse
 		config.ExtraArguments[strategies.HTTPEgressHTTPTimeoutArgName] = clientTimeout
-		svc, err := newService(config, strategies.HTTPEgressStrategyName)
+		err := newService(config, strategies.HTTPEgressStrategyName)
 
 		if err != nil {
 			log.Fatalln(err)
 		}
-		defer svc.Close()
 	},
 }
 
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/buoyantio/bb/load"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+const (
+	loadTextOutput = "text"
+	loadJSONOutput = "json"
+)
+
+var loadOptions = &load.Options{}
+var loadOutput string
+
+var loadCmd = &cobra.Command{
+	Use:     "load",
+	Short:   "Sends requests to the downstream services at a fixed rate or concurrency, then reports on their latency and errors.",
+	Long:    "Sends requests to the downstream services at a fixed rate or concurrency, then reports on their latency and errors. Requests are sent to each gRPC and HTTP downstream service in turn, exactly as another bb service would.",
+	Example: "bb load --grpc-downstream-server localhost:9090 --rps 100 --concurrency 20 --duration 1m --warm-up 10s --ramp-up 30s",
+
+	Run: func(cmd *cobra.Command, args []string) {
+		if loadOutput != loadTextOutput && loadOutput != loadJSONOutput {
+			log.Fatalf("output must be [%s] or [%s], but was [%s]", loadTextOutput, loadJSONOutput, loadOutput)
+		}
+
+		clients, err := buildProtocolClients(config)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer func() {
+			for _, c := range clients {
+				c.Close()
+			}
+		}()
+
+		// interrupting the run still reports on the requests sent so far
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		report, err := load.Run(ctx, clients, loadOptions)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		if loadOutput == loadJSONOutput {
+			err = report.WriteJSON(cmd.OutOrStdout())
+		} else {
+			err = report.WriteText(cmd.OutOrStdout())
+		}
+		if err != nil {
+			log.Fatalln(err)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(loadCmd)
+	loadCmd.PersistentFlags().Float64Var(&loadOptions.RequestsPerSecond, "rps", 0, "requests per second to send, across all downstream services. If not set, each of the concurrent requests is sent as soon as the previous one completed")
+	loadCmd.PersistentFlags().IntVar(&loadOptions.Concurrency, "concurrency", 10, "maximum number of requests in flight at once")
+	loadCmd.PersistentFlags().DurationVar(&loadOptions.Duration, "duration", time.Second*30, "how long to send requests for, after the warm-up")
+	loadCmd.PersistentFlags().DurationVar(&loadOptions.WarmUp, "warm-up", 0, "how long to send requests for before they are included in the report")
+	loadCmd.PersistentFlags().DurationVar(&loadOptions.RampUp, "ramp-up", 0, "how long it takes, from the start of the warm-up, for the rate to grow linearly up to rps")
+	loadCmd.PersistentFlags().DurationVar(&loadOptions.GracePeriod, "grace-period", time.Second*10, "how long requests still in flight at the end of the duration are waited for, after which they are reported as timed out")
+	loadCmd.PersistentFlags().StringVar(&loadOutput, "output", loadTextOutput, fmt.Sprintf("format of the report, either [%s] or [%s]", loadTextOutput, loadJSONOutput))
+}
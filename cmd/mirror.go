@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/buoyantio/bb/strategies"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var shadowGRPCDownstreamServers []string
+var shadowH1DownstreamServers []string
+var shadowPercentage int
+
+var mirrorCmd = &cobra.Command{
+	Use:     strategies.MirrorStrategyName,
+	Short:   "Forwards the request to a primary downstream service and returns its response, sending a copy to shadow downstream services without waiting for them.",
+	Example: "bb mirror --grpc-downstream-server localhost:9090 --shadow-grpc-downstream-server localhost:9091 --shadow-percentage 50 --h1-server-port 8080",
+
+	Run: func(cmd *cobra.Command, args []string) {
+		config.GRPCDownstreamServers = append(config.GRPCDownstreamServers, shadowGRPCDownstreamServers...)
+		config.H1DownstreamServers = append(config.H1DownstreamServers, shadowH1DownstreamServers...)
+		allShadows := append(append([]string{}, shadowGRPCDownstreamServers...), shadowH1DownstreamServers...)
+		config.ExtraArguments[strategies.MirrorShadowDownstreamsArgName] = strings.Join(allShadows, ",")
+		config.ExtraArguments[strategies.MirrorShadowPercentageArgName] = strconv.Itoa(shadowPercentage)
+		err := newService(config, strategies.MirrorStrategyName)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(mirrorCmd)
+	mirrorCmd.PersistentFlags().StringSliceVar(&shadowGRPCDownstreamServers, "shadow-grpc-downstream-server", []string{}, "list of servers (hostname:port) to send a copy of each request to using gRPC, can be repeated")
+	mirrorCmd.PersistentFlags().StringSliceVar(&shadowH1DownstreamServers, "shadow-h1-downstream-server", []string{}, "list of servers (protocol://hostname:port) to send a copy of each request to using HTTP 1.1, can be repeated")
+	mirrorCmd.PersistentFlags().IntVar(&shadowPercentage, strategies.MirrorShadowPercentageArgName, 100, "percentage of requests copied to the shadow downstream services")
+}
@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/buoyantio/bb/strategies"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var pipelineResponseMode string
+
+var pipelineCmd = &cobra.Command{
+	Use:     strategies.PipelineStrategyName,
+	Short:   "Forwards the request to each downstream service in turn, feeding each response into the next request.",
+	Long:    "Forwards the request to each downstream service in turn, feeding each response into the next request. gRPC downstream services are called first, then HTTP ones, each in the order given. Stops at the first error.",
+	Example: "bb pipeline --grpc-downstream-server localhost:9090 --grpc-downstream-server localhost:9091 --h1-server-port 8080",
+
+	Run: func(cmd *cobra.Command, args []string) {
+		config.ExtraArguments[strategies.PipelineResponseModeArgName] = pipelineResponseMode
+		err := newService(config, strategies.PipelineStrategyName)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(pipelineCmd)
+	pipelineCmd.PersistentFlags().StringVar(&pipelineResponseMode, strategies.PipelineResponseModeArgName, strategies.PipelineConcatResponseMode, fmt.Sprintf("how the payloads of every stage make up the response: [%s] joins all of them, [%s] returns only the final one", strategies.PipelineConcatResponseMode, strategies.PipelineLastResponseMode))
+}
@@ -1,25 +1,44 @@
 package cmd
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/buoyantio/bb/strategies"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
+var loadBalancer string
+var downstreamWeights []string
+
 var pointToPointChannelCmd = &cobra.Command{
 	Use:     strategies.PointToPointStrategyName,
-	Short:   "Forwards the request to one and only one downstream service.",
+	Short:   "Forwards the request to one and only one downstream service, load balancing if more than one is configured.",
 	Example: "bb point-to-point-channel --grpc-downstream-server localhost:9090 --h1-server-port 8080",
 
 	Run: func(cmd *cobra.Command, args []string) {
-		svc, err := newService(config, strategies.PointToPointStrategyName)
+		setPointToPointArguments(cmd, config.ExtraArguments)
+		err := newService(config, strategies.PointToPointStrategyName)
 		if err != nil {
 			log.Fatalln(err)
 		}
-		defer svc.Close()
 	},
 }
 
+// setPointToPointArguments copies the load balancing flags into the strategy arguments, leaving out those not set so
+// that the strategy can tell them apart from the defaults
+func setPointToPointArguments(cmd *cobra.Command, arguments map[string]string) {
+	if cmd.Flags().Changed(strategies.PointToPointLoadBalancerArgName) {
+		arguments[strategies.PointToPointLoadBalancerArgName] = loadBalancer
+	}
+	if len(downstreamWeights) > 0 {
+		arguments[strategies.PointToPointDownstreamWeightArgName] = strings.Join(downstreamWeights, ",")
+	}
+}
+
 func init() {
 	RootCmd.AddCommand(pointToPointChannelCmd)
+	pointToPointChannelCmd.PersistentFlags().StringVar(&loadBalancer, strategies.PointToPointLoadBalancerArgName, strategies.RoundRobinLoadBalancer, fmt.Sprintf("algorithm used to pick a downstream service for each request, must be one of: %s", strings.Join(strategies.LoadBalancers, ", ")))
+	pointToPointChannelCmd.PersistentFlags().StringSliceVar(&downstreamWeights, strategies.PointToPointDownstreamWeightArgName, []string{}, "weight of a downstream service (downstream=weight), only with the weighted load balancer, defaults to 1, can be repeated")
 }
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/buoyantio/bb/strategies"
+	"github.com/buoyantio/bb/topology"
+)
+
+func TestPointToPointChannelCmd(t *testing.T) {
+	t.Run("hedges requests with the flags' defaults", func(t *testing.T) {
+		original := *config
+		defer func() { *config = original }()
+
+		banana, bananaPort := startTestService(t, strategies.TerminusStrategyName, nil, map[string]string{strategies.TerminusResponseTextArgName: "BANANA"})
+		defer stopTopology([]*runningService{banana})
+		apple, applePort := startTestService(t, strategies.TerminusStrategyName, nil, map[string]string{strategies.TerminusResponseTextArgName: "APPLE"})
+		defer stopTopology([]*runningService{apple})
+
+		port, err := topology.FreePort()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		err = pointToPointChannelCmd.ParseFlags([]string{
+			"--grpc-server-port", strconv.Itoa(port),
+			"--grpc-downstream-server", localAddress(bananaPort),
+			"--grpc-downstream-server", localAddress(applePort),
+			"--hedge-delay", "10ms",
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		nodeConfig := *config
+		nodeConfig.MetricsPort = -1
+		nodeConfig.ExtraArguments = map[string]string{}
+		setPointToPointArguments(pointToPointChannelCmd, nodeConfig.ExtraArguments)
+
+		channel, err := startService(&nodeConfig, strategies.PointToPointStrategyName)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		defer stopTopology([]*runningService{channel})
+
+		if payload := payloadOf(t, channel); payload != "BANANA" && payload != "APPLE" {
+			t.Fatalf("Expected payload from either downstream service, got [%s]", payload)
+		}
+	})
+}
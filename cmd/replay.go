@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/buoyantio/bb/replay"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var replayOptions = &replay.Options{}
+var replayInput string
+var replayOutput string
+var replayExpected string
+
+var replayCmd = &cobra.Command{
+	Use:   "replay",
+	Short: "Sends the requests read from a JSONL file to the downstream services, writing their responses as JSONL.",
+	Long: `Sends the requests read from a JSONL file to the downstream services, writing their responses as JSONL.
+
+Each line is either a request, such as {"requestUID":"1","payload":"BANANA"}, or an object with the request and when
+it was originally made, such as {"timestamp":"2024-01-02T15:04:05.5Z","request":{"requestUID":"1"}}. Requests are
+replayed keeping the time between their timestamps, unless a fixed rate is set. Responses are written in the order
+requests were read, and can be compared with those of a previous replay.`,
+	Example: "bb replay --grpc-downstream-server localhost:9090 --input requests.jsonl --output responses.jsonl --expected last-responses.jsonl",
+
+	Run: func(cmd *cobra.Command, args []string) {
+		in := cmd.InOrStdin()
+		if replayInput != "-" {
+			file, err := os.Open(replayInput)
+			if err != nil {
+				log.Fatalln(err)
+			}
+			defer file.Close()
+			in = file
+		}
+
+		out := cmd.OutOrStdout()
+		if replayOutput != "-" {
+			file, err := os.Create(replayOutput)
+			if err != nil {
+				log.Fatalln(err)
+			}
+			defer file.Close()
+			out = file
+		}
+
+		if replayExpected != "" {
+			expected, err := readExpectedResults(replayExpected)
+			if err != nil {
+				log.Fatalln(err)
+			}
+			replayOptions.Expected = expected
+		}
+
+		clients, err := buildProtocolClients(config)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer func() {
+			for _, c := range clients {
+				c.Close()
+			}
+		}()
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		summary, err := replay.Run(ctx, clients, in, out, replayOptions)
+		interrupted := errors.Is(err, context.Canceled)
+		if interrupted {
+			log.Infof("Stopped replaying due to interrupt")
+		} else if err != nil {
+			log.Fatalln(err)
+		}
+
+		log.Infof("Replayed [%d] requests, [%d] failed", summary.Requests, summary.Errors)
+		if replayOptions.Expected != nil {
+			for _, difference := range summary.Differences {
+				log.Warnf("Unexpected result: %s", difference)
+			}
+			switch {
+			case len(summary.Differences) == 0:
+				log.Infof("All results match the expected ones")
+			case interrupted:
+				// an interrupted replay still exits normally, having reported what it replayed
+				log.Warnf("[%d] results differ from the expected ones", len(summary.Differences))
+			default:
+				log.Fatalf("[%d] results differ from the expected ones", len(summary.Differences))
+			}
+		}
+	},
+}
+
+func readExpectedResults(path string) (map[string]*replay.Result, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return replay.ReadResults(file)
+}
+
+func init() {
+	RootCmd.AddCommand(replayCmd)
+	replayCmd.PersistentFlags().StringVar(&replayInput, "input", "-", "JSONL file to read requests from, - for stdin")
+	replayCmd.PersistentFlags().StringVar(&replayOutput, "output", "-", "JSONL file to write responses to, - for stdout")
+	replayCmd.PersistentFlags().StringVar(&replayExpected, "expected", "", "JSONL file written by a previous replay, whose responses are compared with the actual ones")
+	replayCmd.PersistentFlags().Float64Var(&replayOptions.RequestsPerSecond, "rps", 0, "replay requests at this fixed rate rather than as per their timestamps")
+}
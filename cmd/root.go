@@ -3,9 +3,11 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/buoyantio/bb/service"
+	"github.com/buoyantio/bb/tracing"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
@@ -15,6 +17,7 @@ var config = &service.Config{
 }
 
 var logLevel string
+var scenarioFile string
 
 // RootCmd represents the base command when called without any subcommands
 var RootCmd = &cobra.Command{
@@ -27,6 +30,9 @@ var RootCmd = &cobra.Command{
 			log.Fatalf("invalid log-level: %s", logLevel)
 		}
 		log.SetLevel(level)
+		if err := tracing.SetPropagator(config); err != nil {
+			log.Fatalln(err)
+		}
 		if config.ID == "" {
 			config.ID = fmt.Sprintf("%s-grpc:%d-h1:%d", cmd.Name(), config.GRPCServerPort, config.H1ServerPort)
 		}
@@ -46,13 +52,50 @@ func init() {
 	RootCmd.PersistentFlags().StringVar(&config.ID, "id", "", "identifier for this container")
 	RootCmd.PersistentFlags().IntVar(&config.GRPCServerPort, "grpc-server-port", -1, "port to bind a gRPC server to")
 	RootCmd.PersistentFlags().IntVar(&config.H1ServerPort, "h1-server-port", -1, "port to bind a HTTP 1.1 server to")
+	RootCmd.PersistentFlags().IntVar(&config.AdminPort, "admin-port", -1, "port to bind a HTTP admin server to, used to inspect and change this process at runtime")
+	RootCmd.PersistentFlags().StringVar(&config.BindHost, "bind-host", "", "host or IP address the gRPC, HTTP 1.1, admin and metrics servers bind to, all interfaces if not set")
+	RootCmd.PersistentFlags().IntVar(&config.MetricsPort, "metrics-port", -1, "port to bind a HTTP server exposing Prometheus metrics at /metrics to")
 	RootCmd.PersistentFlags().IntVar(&config.PercentageFailedRequests, "percent-failure", 0, "percentage of requests that this service will automatically fail")
+	RootCmd.PersistentFlags().StringArrayVar(&config.FailureTypes, "failure-type", []string{}, "how failed requests fail, as grpc:code=<code> or http:status=<status>, optionally followed by ,weight=<n>,retry-after=<duration>,message=<text> and ,trailer=<name>:<value> or ,header=<name>:<value>. Failed requests pick one of those of the protocol they were received over, in proportion to their weights, can be repeated")
+	RootCmd.PersistentFlags().StringArrayVar(&config.TransportFaults, "transport-fault", []string{}, fmt.Sprintf("fault to inject into the connection of a percentage of requests, one of: %s, e.g. reset:percent=5, trickle:percent=5,interval=10ms or close-after:percent=5,bytes=100. Resets and GOAWAY frames affect every request sharing the connection of a gRPC request, as does trickling, which holds up the responses written after it, while other faults only affect its response, can be repeated", strings.Join(service.TransportFaultKinds, ", ")))
 	RootCmd.PersistentFlags().IntVar(&config.SleepInMillis, "sleep-in-millis", 0, "amount of milliseconds to wait before actually start processing a request")
+	RootCmd.PersistentFlags().IntVar(&config.CPUBurnInMillis, "cpu-burn-in-millis", 0, "amount of milliseconds to keep the CPU busy for while processing each request, unlike sleep-in-millis which consumes no CPU")
+	RootCmd.PersistentFlags().IntVar(&config.MemoryPerRequestMB, "memory-per-request-mb", 0, "megabytes of memory to allocate and write to while processing each request, kept until the request completes and for memory-hold-for after")
+	RootCmd.PersistentFlags().DurationVar(&config.MemoryHoldFor, "memory-hold-for", 0, "how long to keep the memory allocated by each request after it completes")
+	RootCmd.PersistentFlags().IntVar(&config.MemoryLeakMBPerMinute, "memory-leak-mb-per-minute", 0, "megabytes of memory to leak in the background every minute, which are never released")
+	RootCmd.PersistentFlags().StringVar(&config.Latency, "latency", "", fmt.Sprintf("distribution of the latency added to sleep-in-millis for each request, one of: %s, e.g. normal:mean=50ms,stddev=10ms or bimodal:fast=10ms,slow=500ms,percent=5", strings.Join(service.LatencyDistributions, ", ")))
+	RootCmd.PersistentFlags().Int64Var(&config.LatencySeed, "latency-seed", 0, "seed latencies are picked with, so that runs with the same seed pick the same latencies, random if 0")
 	RootCmd.PersistentFlags().IntVar(&config.TerminateAfter, "terminate-after", 0, "terminate the process after this many requests")
 	RootCmd.PersistentFlags().BoolVar(&config.FireAndForget, "fire-and-forget", false, "do not wait for a response when contacting downstream services.")
 	RootCmd.PersistentFlags().StringSliceVar(&config.GRPCDownstreamServers, "grpc-downstream-server", []string{}, "list of servers (hostname:port) to send messages to using gRPC, can be repeated")
 	RootCmd.PersistentFlags().StringVar(&config.GRPCProxy, "grpc-proxy", "", "optional proxy to route gRPC requests")
 	RootCmd.PersistentFlags().StringSliceVar(&config.H1DownstreamServers, "h1-downstream-server", []string{}, "list of servers (protocol://hostname:port) to send messages to using HTTP 1.1, can be repeated")
 	RootCmd.PersistentFlags().DurationVar(&config.DownstreamTimeout, "downstream-timeout", time.Minute*1, "timeout to use when making downstream connections and requests.")
+	RootCmd.PersistentFlags().IntVar(&config.RetryMaxAttempts, "retry-max-attempts", 1, "maximum number of attempts, including the first one, made for each downstream request. 1 disables retries")
+	RootCmd.PersistentFlags().DurationVar(&config.RetryBackoffBase, "retry-backoff-base", time.Millisecond*25, "base for the exponential backoff between retries, each wait is picked at random up to base*2^retry")
+	RootCmd.PersistentFlags().DurationVar(&config.RetryBackoffMax, "retry-backoff-max", time.Second*1, "maximum wait between retries")
+	RootCmd.PersistentFlags().Float64Var(&config.RetryBudgetRatio, "retry-budget-ratio", 0.2, "maximum ratio of retries to original downstream requests over the last 10 seconds")
+	RootCmd.PersistentFlags().IntVar(&config.RetryBudgetMinPerSecond, "retry-budget-min-per-second", 10, "number of retries per second allowed regardless of the retry budget ratio")
+	RootCmd.PersistentFlags().StringSliceVar(&config.RetryOn, "retry-on", []string{service.RetryOnHTTP5xx, service.RetryOnConnectionError, "unavailable"}, fmt.Sprintf("errors that can be retried: %s, %s, or gRPC status codes such as unavailable or resource-exhausted, can be repeated", service.RetryOnHTTP5xx, service.RetryOnConnectionError))
+	RootCmd.PersistentFlags().DurationVar(&config.HedgeDelay, "hedge-delay", 0, "if set, send a duplicate request to another downstream service when no response was received after this long. Required as the initial delay when hedge-percentile is set. Only applies to point-to-point-channel")
+	RootCmd.PersistentFlags().Float64Var(&config.HedgePercentile, "hedge-percentile", 0, "if set, learn the hedging delay as this percentile of the observed downstream latencies, e.g. 95")
+	RootCmd.PersistentFlags().IntVar(&config.HedgeMaxAttempts, "hedge-max-attempts", 2, "maximum number of downstream services a hedged request is sent to")
+	RootCmd.PersistentFlags().IntVar(&config.CircuitBreakerConsecutiveFailures, "circuit-breaker-consecutive-failures", 0, "if set, stop sending requests to a downstream service after this many consecutive failures")
+	RootCmd.PersistentFlags().Float64Var(&config.CircuitBreakerErrorPercentage, "circuit-breaker-error-percentage", 0, "if set, stop sending requests to a downstream service once this percentage of its requests failed within circuit-breaker-window")
+	RootCmd.PersistentFlags().DurationVar(&config.CircuitBreakerWindow, "circuit-breaker-window", time.Second*10, "sliding window over which the circuit breaker error percentage is computed")
+	RootCmd.PersistentFlags().IntVar(&config.CircuitBreakerMinRequests, "circuit-breaker-min-requests", 20, "minimum number of requests within circuit-breaker-window before the error percentage can trip the circuit breaker")
+	RootCmd.PersistentFlags().DurationVar(&config.CircuitBreakerCooldown, "circuit-breaker-cooldown", time.Second*5, "how long a tripped circuit breaker fails requests before letting a probe request through")
+	RootCmd.PersistentFlags().StringSliceVar(&config.PropagateHeaders, "propagate-header", []string{}, "inbound HTTP header or gRPC metadata to copy onto downstream requests, regardless of their protocol, can be repeated")
+	RootCmd.PersistentFlags().BoolVar(&config.PropagateAllHeaders, "propagate-all-headers", false, "copy all inbound HTTP headers and gRPC metadata onto downstream requests, except those describing the connection itself")
+	RootCmd.PersistentFlags().StringVar(&config.TracingExporter, "tracing-exporter", tracing.NoExporter, fmt.Sprintf("where to export spans to, must be one of: %s", strings.Join(tracing.Exporters, ", ")))
+	RootCmd.PersistentFlags().StringVar(&config.TracingOTLPEndpoint, "tracing-otlp-endpoint", "http://localhost:4318/v1/traces", "URL of the OpenTelemetry collector spans are exported to via OTLP over HTTP")
+	RootCmd.PersistentFlags().StringVar(&config.TracingFile, "tracing-file", "bb-traces.json", "file spans are appended to as JSON when using the file exporter")
+	RootCmd.PersistentFlags().StringSliceVar(&config.TracingPropagators, "tracing-propagator", []string{tracing.W3CPropagator, tracing.B3Propagator}, fmt.Sprintf("trace context formats extracted from inbound requests and injected into downstream requests: %s, can be repeated", strings.Join(tracing.Propagators, ", ")))
+	RootCmd.PersistentFlags().Float64Var(&config.TracingSampleRatio, "tracing-sample-ratio", 1, "ratio of new traces that are sampled, traces continued from inbound requests follow their sampling decision")
+	RootCmd.PersistentFlags().BoolVar(&config.RecordHops, "record-hops", false, "add to each response a record of this service and of every downstream request made to build it, including the records of downstream services")
+	RootCmd.PersistentFlags().BoolVar(&config.Unhealthy, "unhealthy", false, "fail liveness and readiness checks, made via /healthz, /readyz and the gRPC health service, while still serving requests")
+	RootCmd.PersistentFlags().DurationVar(&config.PreStopDelay, "pre-stop-delay", 0, "when stopping, how long to keep serving requests after readiness checks start failing, so that load balancers stop sending new ones")
+	RootCmd.PersistentFlags().DurationVar(&config.ShutdownGracePeriod, "shutdown-grace-period", time.Second*10, "when stopping, how long to wait for requests in flight to complete before closing their connections")
+	RootCmd.PersistentFlags().StringVar(&scenarioFile, "scenario", "", "YAML file declaring steps that change the faults of this service along a timeline, such as failing requests or crashing")
 	RootCmd.PersistentFlags().StringVar(&logLevel, "log-level", log.InfoLevel.String(), "log level, must be one of: panic, fatal, error, warn, info, debug")
 }
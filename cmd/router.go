@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/buoyantio/bb/strategies"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var routes []string
+var defaultRoute string
+
+var routerCmd = &cobra.Command{
+	Use:     strategies.RouterStrategyName,
+	Short:   "Forwards the request to the downstream service of the first route matching it.",
+	Example: "bb router --grpc-downstream-server localhost:9090 --grpc-downstream-server localhost:9091 --route 'header:x-tenant=^gold$->localhost:9091' --default-route localhost:9090 --h1-server-port 8080",
+
+	Run: func(cmd *cobra.Command, args []string) {
+		config.ExtraArguments[strategies.RouterRoutesArgName] = strings.Join(routes, "\n")
+		config.ExtraArguments[strategies.RouterDefaultRouteArgName] = defaultRoute
+		err := newService(config, strategies.RouterStrategyName)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(routerCmd)
+	routerCmd.PersistentFlags().StringArrayVar(&routes, strategies.RouterRoutesArgName, []string{}, "route in the format field=regex->downstream, where field is uid, path, method, header:<name> or metadata:<name>. Routes are tried in order, can be repeated")
+	routerCmd.PersistentFlags().StringVar(&defaultRoute, strategies.RouterDefaultRouteArgName, "", "downstream service receiving requests that don't match any route")
+}
@@ -1,22 +1,30 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
+	"time"
 
+	"github.com/buoyantio/bb/admin"
+	"github.com/buoyantio/bb/metrics"
 	"github.com/buoyantio/bb/protocols"
+	"github.com/buoyantio/bb/scenario"
 	"github.com/buoyantio/bb/service"
 	"github.com/buoyantio/bb/strategies"
+	"github.com/buoyantio/bb/tracing"
 	log "github.com/sirupsen/logrus"
 )
 
+// buildServers returns the gRPC and HTTP servers configured, along with those already started if one fails to start
 func buildServers(config *service.Config, handler *service.RequestHandler) ([]service.Server, error) {
 	servers := make([]service.Server, 0)
 	grpcServer, err := protocols.NewGrpcServerIfConfigured(config, handler)
 	if err != nil {
-		return nil, err
+		return servers, err
 	}
 
 	if grpcServer != nil {
@@ -25,7 +33,7 @@ func buildServers(config *service.Config, handler *service.RequestHandler) ([]se
 
 	httpServer, err := protocols.NewHTTPServerIfConfigured(config, handler)
 	if err != nil {
-		return nil, err
+		return servers, err
 	}
 
 	if httpServer != nil {
@@ -35,7 +43,8 @@ func buildServers(config *service.Config, handler *service.RequestHandler) ([]se
 	return servers, nil
 }
 
-func buildClients(config *service.Config) ([]service.Client, error) {
+// buildProtocolClients returns a gRPC or HTTP client for every downstream service, without any decorators
+func buildProtocolClients(config *service.Config) ([]service.Client, error) {
 	clients := make([]service.Client, 0)
 	grpcClients, err := protocols.NewGrpcClientsIfConfigured(config)
 	if err != nil {
@@ -47,7 +56,45 @@ func buildClients(config *service.Config) ([]service.Client, error) {
 	if err != nil {
 		return nil, err
 	}
-	clients = append(clients, httpClients...)
+	return append(clients, httpClients...), nil
+}
+
+// buildClients wraps the protocol clients in the decorators configured, such as retries or circuit breakers
+func buildClients(config *service.Config, clients []service.Client) ([]service.Client, error) {
+	var err error
+	if config.RecordHops {
+		wrappedClients := make([]service.Client, 0)
+		for _, c := range clients {
+			wrappedClients = append(wrappedClients, service.MakeHopRecording(c))
+		}
+		clients = wrappedClients
+	}
+
+	if config.CircuitBreakerConsecutiveFailures > 0 || config.CircuitBreakerErrorPercentage > 0 {
+		settings, err := service.NewCircuitBreakerSettings(config)
+		if err != nil {
+			return nil, err
+		}
+
+		wrappedClients := make([]service.Client, 0)
+		for _, c := range clients {
+			wrappedClients = append(wrappedClients, service.MakeCircuitBreaking(c, settings))
+		}
+		clients = wrappedClients
+	}
+
+	if config.RetryMaxAttempts > 1 {
+		policy, err := service.NewRetryPolicy(config)
+		if err != nil {
+			return nil, err
+		}
+
+		wrappedClients := make([]service.Client, 0)
+		for _, c := range clients {
+			wrappedClients = append(wrappedClients, service.MakeRetrying(c, policy))
+		}
+		clients = wrappedClients
+	}
 
 	if config.FireAndForget {
 		wrappedClients := make([]service.Client, 0)
@@ -60,35 +107,329 @@ func buildClients(config *service.Config) ([]service.Client, error) {
 	return clients, err
 }
 
-func newService(config *service.Config, strategyName string) (*service.Service, error) {
+// runningService is a service whose servers are accepting requests, along with everything that has to be shut down
+// once it stops
+type runningService struct {
+	*service.Service
+	config          *service.Config
+	strategyName    string
+	protocolClients []service.Client
+	handler         *service.RequestHandler
+	mu              sync.Mutex
+	adminServer     *admin.Server
+	metricsServer   *metrics.Server
+	tracingProvider *tracing.Provider
+	stopScenario    context.CancelFunc
+	crashed         chan struct{}
+	crashOnce       sync.Once
+}
+
+// Stopping returns a channel that is written to when the service decides to stop by itself, e.g. due to terminate-after
+func (s *runningService) Stopping() <-chan struct{} {
+	return s.handler.Stopping()
+}
+
+// Crashed returns a channel that is closed when a scenario step crashes the service, which then has to be killed
+// rather than shut down
+func (s *runningService) Crashed() <-chan struct{} {
+	return s.crashed
+}
+
+func (s *runningService) crash() {
+	s.crashOnce.Do(func() { close(s.crashed) })
+}
+
+// runScenario changes the faults of the service along the steps of sc, until the scenario ends or the service stops
+func (s *runningService) runScenario(sc *scenario.Scenario) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.mu.Lock()
+	s.stopScenario = cancel
+	s.mu.Unlock()
+
+	go func() {
+		if err := sc.Run(ctx, s, s.crash); err != nil {
+			log.Errorf("Scenario of service [%s] stopped: %v", s.config.ID, err)
+		}
+	}()
+}
+
+// RuntimeConfig returns the settings of this service that can be changed while it runs
+func (s *runningService) RuntimeConfig() *service.RuntimeConfig {
+	return service.NewRuntimeConfig(s.handler.Config())
+}
+
+// Reconfigure changes the settings of this service while it runs. Changing its downstream services or strategy
+// arguments builds a new strategy, and new clients if needed, which are only swapped in once they were all built.
+func (s *runningService) Reconfigure(changes *service.RuntimeConfig) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current := s.handler.Config()
+	config, err := changes.Apply(current)
+	if err != nil {
+		return err
+	}
+
+	if !changes.ChangesStrategy() {
+		s.handler.Reconfigure(config, s.handler.CurrentStrategy())
+		log.Infof("Service [%s] reconfigured as: %+v", config.ID, service.NewRuntimeConfig(config))
+		return nil
+	}
+
+	clients := s.Clients
+	protocolClients := s.protocolClients
+	if changes.ChangesDownstreams() {
+		if protocolClients, err = buildProtocolClients(config); err != nil {
+			return err
+		}
+		if clients, err = buildClients(config, protocolClients); err != nil {
+			closeClients(protocolClients)
+			return err
+		}
+	}
+
+	strategy, err := newStrategyByName(s.strategyName, config, s.Servers, clients)
+	if err != nil {
+		if changes.ChangesDownstreams() {
+			closeClients(protocolClients)
+		}
+		return err
+	}
+
+	s.handler.Reconfigure(config, strategy)
+	if endpoint, isEndpoint := strategy.(admin.Endpoint); isEndpoint && s.adminServer != nil {
+		s.adminServer.Register(endpoint)
+	}
+	s.Strategy = strategy
+
+	if changes.ChangesDownstreams() {
+		previousClients := s.Clients
+		s.Clients = clients
+		s.protocolClients = protocolClients
+		s.handler.Health().SetDownstreams(protocolClients)
+		// requests still being sent via the previous clients get up to the downstream timeout to complete
+		time.AfterFunc(current.DownstreamTimeout, func() { closeClients(previousClients) })
+	}
+
+	log.Infof("Service [%s] reconfigured as: %+v", config.ID, service.NewRuntimeConfig(config))
+	return nil
+}
+
+func closeClients(clients []service.Client) {
+	for _, c := range clients {
+		if err := c.Close(); err != nil {
+			log.Errorln(err)
+		}
+	}
+}
+
+// shutdown drains the service: it fails readiness checks while still serving requests for the pre-stop delay, then
+// stops accepting requests and waits up to the shutdown grace period for those in flight, and finally closes its
+// downstream connections
+func (s *runningService) shutdown() {
+	s.drain()
+	if s.config.PreStopDelay > 0 {
+		log.Infof("Service [%s] is no longer ready, waiting [%v] before it stops accepting requests", s.config.ID, s.config.PreStopDelay)
+		time.Sleep(s.config.PreStopDelay)
+	}
+	s.stopGracefully()
+}
+
+// drain makes the service fail readiness checks, while it keeps serving requests
+func (s *runningService) drain() {
+	s.cancelScenario()
+	s.handler.Health().Drain()
+}
+
+// stopGracefully stops the service, waiting up to the shutdown grace period for requests in flight
+func (s *runningService) stopGracefully() {
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.ShutdownGracePeriod)
+	defer cancel()
+	s.stop(ctx)
+}
+
+// kill stops the service abruptly, as a crash would, closing the connections of requests still in flight
+func (s *runningService) kill() {
+	s.cancelScenario()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.stop(ctx)
+}
+
+func (s *runningService) cancelScenario() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopScenario != nil {
+		s.stopScenario()
+	}
+}
+
+// stop shuts down the servers, waiting for requests in flight until ctx is done, and then everything else. Clients
+// are only ever closed here, as the service is done with them.
+func (s *runningService) stop(ctx context.Context) {
+	s.handler.MemoryLeak().SetRate(0)
+
+	var wg sync.WaitGroup
+	for _, server := range s.Servers {
+		wg.Add(1)
+		go func(server service.Server) {
+			defer wg.Done()
+			// servers of a killed service are shut down with a cancelled ctx, which isn't an error
+			if err := server.Shutdown(ctx); err != nil && err != context.Canceled {
+				log.Errorf("Error shutting down [%s]: %v", server.GetID(), err)
+			}
+		}(server)
+	}
+	wg.Wait()
+
+	s.mu.Lock()
+	closeClients(s.protocolClients)
+	s.mu.Unlock()
+
+	if s.adminServer != nil {
+		s.adminServer.Shutdown()
+	}
+
+	if s.metricsServer != nil {
+		s.metricsServer.Shutdown()
+	}
+
+	if s.tracingProvider != nil {
+		s.tracingProvider.Shutdown()
+	}
+}
+
+// startService builds the service described by config and starts its servers, without waiting for it to stop. If
+// that fails, everything started so far is stopped, so that it can be started again.
+func startService(config *service.Config, strategyName string) (*runningService, error) {
+	// hedging treats all downstream services as replicas of each other, which only point-to-point-channel does
+	if config.Hedging() && strategyName != strategies.PointToPointStrategyName && len(config.GRPCDownstreamServers)+len(config.H1DownstreamServers) > 0 {
+		return nil, fmt.Errorf("hedging only applies to strategy [%s], but was set for [%s]", strategies.PointToPointStrategyName, strategyName)
+	}
+
+	if _, err := service.NewLatencyDistribution(config.Latency, config.LatencySeed); err != nil {
+		return nil, err
+	}
+
+	if config.CPUBurnInMillis < 0 || config.MemoryPerRequestMB < 0 || config.MemoryHoldFor < 0 || config.MemoryLeakMBPerMinute < 0 {
+		return nil, fmt.Errorf("CPU burn, memory per request, memory hold time and memory leak must not be negative")
+	}
+
+	if _, err := service.NewFailureTypes(config.FailureTypes); err != nil {
+		return nil, err
+	}
+
+	if _, err := service.NewTransportFaults(config.TransportFaults); err != nil {
+		return nil, err
+	}
+
+	// services run by topology run are given a TracerProvider of their own by it, others export their spans themselves
+	var tracingProvider *tracing.Provider
+	if config.TracerProvider == nil {
+		var err error
+		tracingProvider, err = tracing.NewProviderIfConfigured(config)
+		if err != nil {
+			return nil, err
+		}
+		if tracingProvider != nil {
+			config.TracerProvider = tracingProvider.TracerProvider(config.ID)
+		}
+	}
 
 	handler := service.NewRequestHandler(config)
+	running := &runningService{
+		Service:         &service.Service{},
+		config:          config,
+		strategyName:    strategyName,
+		handler:         handler,
+		tracingProvider: tracingProvider,
+		crashed:         make(chan struct{}),
+	}
+
+	started := false
+	defer func() {
+		if !started {
+			running.kill()
+			if tracingProvider != nil {
+				config.TracerProvider = nil
+			}
+		}
+	}()
 
 	servers, err := buildServers(config, handler)
+	running.Servers = servers
+	if err != nil {
+		return nil, err
+	}
+
+	protocolClients, err := buildProtocolClients(config)
+	running.protocolClients = protocolClients
 	if err != nil {
 		return nil, err
 	}
+	handler.Health().SetDownstreams(protocolClients)
 
-	clients, err := buildClients(config)
+	clients, err := buildClients(config, protocolClients)
 	if err != nil {
 		return nil, err
 	}
 
 	strategy, err := newStrategyByName(strategyName, config, servers, clients)
 	if err != nil {
-		log.Fatalln(err)
+		return nil, err
+	}
+
+	adminServer, err := admin.NewServerIfConfigured(config)
+	running.adminServer = adminServer
+	if err != nil {
+		return nil, err
+	}
+
+	if endpoint, isEndpoint := strategy.(admin.Endpoint); isEndpoint && adminServer != nil {
+		adminServer.Register(endpoint)
+	}
+
+	metricsServer, err := metrics.NewServerIfConfigured(config.BindHost, config.MetricsPort)
+	running.metricsServer = metricsServer
+	if err != nil {
+		return nil, err
 	}
 
 	//TODO: this is awful as there's a circular dep between server and strategy
 	handler.Strategy = strategy
+	handler.StrategyName = strategyName
+
+	running.Strategy = strategy
+	running.Clients = clients
+	log.Infof("Process configured as: %+v", running.Service)
+
+	if adminServer != nil {
+		adminServer.Register(admin.NewConfigEndpoint(running))
+		adminServer.Register(admin.NewLivenessEndpoint(handler.Health()))
+		adminServer.Register(admin.NewReadinessEndpoint(handler.Health()))
+	}
 
-	service := &service.Service{
-		Strategy: strategy,
-		Servers:  servers,
-		Clients:  clients,
+	started = true
+	return running, nil
+}
+
+// newService starts the service described by config, and waits for it to stop
+func newService(config *service.Config, strategyName string) error {
+	var sc *scenario.Scenario
+	if scenarioFile != "" {
+		var err error
+		if sc, err = scenario.ReadFile(scenarioFile); err != nil {
+			return err
+		}
 	}
 
-	log.Infof("Process configured as: %+v", service)
+	running, err := startService(config, strategyName)
+	if err != nil {
+		return err
+	}
+	if sc != nil {
+		running.runScenario(sc)
+	}
 
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
@@ -97,15 +438,16 @@ func newService(config *service.Config, strategyName string) (*service.Service,
 	select {
 	case <-stop:
 		log.Infof("Stopping service [%s] due to interrupt", config.ID)
-	case <-handler.Stopping():
+	case <-running.Stopping():
 		log.Infof("Stopping service [%s] due to handler", config.ID)
+	case <-running.Crashed():
+		log.Errorf("Service [%s] crashed due to its scenario", config.ID)
+		running.kill()
+		os.Exit(1)
 	}
 
-	for _, server := range servers {
-		server.Shutdown()
-	}
-
-	return service, nil
+	running.shutdown()
+	return nil
 }
 
 type strategyConstructor func(*service.Config, []service.Server, []service.Client) (service.Strategy, error)
@@ -115,6 +457,10 @@ var strategyByName = map[string]strategyConstructor{
 	strategies.BroadcastChannelStrategyName: strategies.NewBroadcastChannel,
 	strategies.TerminusStrategyName:         strategies.NewTerminusStrategy,
 	strategies.HTTPEgressStrategyName:       strategies.NewHTTPEgress,
+	strategies.PipelineStrategyName:         strategies.NewPipeline,
+	strategies.TrafficSplitStrategyName:     strategies.NewTrafficSplit,
+	strategies.MirrorStrategyName:           strategies.NewMirror,
+	strategies.RouterStrategyName:           strategies.NewRouter,
 }
 
 func newStrategyByName(strategyName string, config *service.Config, servers []service.Server, clients []service.Client) (service.Strategy, error) {
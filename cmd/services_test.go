@@ -0,0 +1,236 @@
+package cmd
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	pb "github.com/buoyantio/bb/gen"
+	"github.com/buoyantio/bb/scenario"
+	"github.com/buoyantio/bb/service"
+	"github.com/buoyantio/bb/strategies"
+	"github.com/buoyantio/bb/topology"
+)
+
+func startTestService(t *testing.T, strategyName string, grpcDownstreamServers []string, arguments map[string]string) (*runningService, int) {
+	port, err := topology.FreePort()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	running, err := startService(&service.Config{
+		ID:                    strategyName,
+		GRPCServerPort:        port,
+		H1ServerPort:          -1,
+		AdminPort:             -1,
+		MetricsPort:           -1,
+		GRPCDownstreamServers: grpcDownstreamServers,
+		DownstreamTimeout:     time.Second * 5,
+		ExtraArguments:        arguments,
+	}, strategyName)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return running, port
+}
+
+func payloadOf(t *testing.T, running *runningService) string {
+	resp, err := running.handler.Handle(context.TODO(), &pb.TheRequest{RequestUID: t.Name()})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return resp.Payload
+}
+
+func TestRunningServiceReconfigure(t *testing.T) {
+	banana, bananaPort := startTestService(t, strategies.TerminusStrategyName, nil, map[string]string{strategies.TerminusResponseTextArgName: "BANANA"})
+	defer stopTopology([]*runningService{banana})
+	apple, applePort := startTestService(t, strategies.TerminusStrategyName, nil, map[string]string{strategies.TerminusResponseTextArgName: "APPLE"})
+	defer stopTopology([]*runningService{apple})
+
+	t.Run("changes the response text", func(t *testing.T) {
+		err := apple.Reconfigure(&service.RuntimeConfig{Arguments: map[string]string{strategies.TerminusResponseTextArgName: "CHERRY"}})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if payload := payloadOf(t, apple); payload != "CHERRY" {
+			t.Fatalf("Expected payload [CHERRY], got [%s]", payload)
+		}
+	})
+
+	t.Run("changes the downstream services", func(t *testing.T) {
+		channel, _ := startTestService(t, strategies.PointToPointStrategyName, []string{localAddress(bananaPort)}, map[string]string{})
+		defer stopTopology([]*runningService{channel})
+
+		if payload := payloadOf(t, channel); payload != "BANANA" {
+			t.Fatalf("Expected payload [BANANA], got [%s]", payload)
+		}
+
+		err := channel.Reconfigure(&service.RuntimeConfig{GRPCDownstreamServers: []string{localAddress(applePort)}})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if payload := payloadOf(t, channel); payload != "CHERRY" {
+			t.Fatalf("Expected payload [CHERRY], got [%s]", payload)
+		}
+
+		if len(channel.Clients) != 1 || channel.Clients[0].GetID() != localAddress(applePort) {
+			t.Fatalf("Expected service to keep track of its new clients, got %v", channel.Clients)
+		}
+	})
+
+	t.Run("keeps the current settings when the new ones can't be applied", func(t *testing.T) {
+		err := banana.Reconfigure(&service.RuntimeConfig{GRPCDownstreamServers: []string{localAddress(applePort)}})
+		if err == nil {
+			t.Fatalf("Expecting error, got nothing")
+		}
+
+		if payload := payloadOf(t, banana); payload != "BANANA" {
+			t.Fatalf("Expected payload [BANANA], got [%s]", payload)
+		}
+
+		if len(banana.RuntimeConfig().GRPCDownstreamServers) != 0 {
+			t.Fatalf("Expected no downstream services, got %+v", banana.RuntimeConfig())
+		}
+	})
+}
+
+func TestRunningServiceShutdown(t *testing.T) {
+	t.Run("keeps serving requests for the pre-stop delay while not ready", func(t *testing.T) {
+		banana, bananaPort := startTestService(t, strategies.TerminusStrategyName, nil, map[string]string{strategies.TerminusResponseTextArgName: "BANANA"})
+		banana.config.PreStopDelay = time.Millisecond * 300
+		banana.config.ShutdownGracePeriod = time.Second
+
+		clients, err := buildProtocolClients(&service.Config{GRPCDownstreamServers: []string{localAddress(bananaPort)}, DownstreamTimeout: time.Second})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		defer closeClients(clients)
+
+		stopped := make(chan struct{})
+		go func() {
+			banana.shutdown()
+			close(stopped)
+		}()
+
+		for banana.handler.Health().Ready() == nil {
+			time.Sleep(time.Millisecond * 10)
+		}
+
+		resp, err := clients[0].Send(context.TODO(), &pb.TheRequest{RequestUID: t.Name()})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if resp.Payload != "BANANA" {
+			t.Fatalf("Expected payload [BANANA], got [%s]", resp.Payload)
+		}
+
+		<-stopped
+		_, err = clients[0].Send(context.TODO(), &pb.TheRequest{RequestUID: t.Name()})
+		if err == nil {
+			t.Fatalf("Expecting error, got nothing")
+		}
+	})
+}
+
+func TestRunningServiceScenario(t *testing.T) {
+	t.Run("fails requests during a step and then crashes", func(t *testing.T) {
+		banana, _ := startTestService(t, strategies.TerminusStrategyName, nil, map[string]string{strategies.TerminusResponseTextArgName: "BANANA"})
+		percentFailure := 100
+		banana.runScenario(&scenario.Scenario{Steps: []*scenario.Step{
+			{Duration: time.Millisecond * 300, PercentFailure: &percentFailure},
+			{Crash: true},
+		}})
+
+		for banana.handler.Config().PercentageFailedRequests != 100 {
+			time.Sleep(time.Millisecond * 10)
+		}
+		if _, err := banana.handler.Handle(context.TODO(), &pb.TheRequest{RequestUID: t.Name()}); err == nil {
+			t.Fatalf("Expecting error, got nothing")
+		}
+
+		select {
+		case <-banana.Crashed():
+		case <-time.After(time.Second * 5):
+			t.Fatalf("Expected the service to crash")
+		}
+		banana.kill()
+	})
+
+	t.Run("stops the scenario when the service shuts down", func(t *testing.T) {
+		banana, _ := startTestService(t, strategies.TerminusStrategyName, nil, map[string]string{strategies.TerminusResponseTextArgName: "BANANA"})
+		banana.runScenario(&scenario.Scenario{Steps: []*scenario.Step{
+			{Duration: time.Millisecond * 50},
+			{Crash: true},
+		}})
+
+		banana.shutdown()
+		select {
+		case <-banana.Crashed():
+			t.Fatalf("Expected the service not to crash once shut down")
+		case <-time.After(time.Millisecond * 200):
+		}
+	})
+}
+
+func TestStartService(t *testing.T) {
+	t.Run("rejects hedging for strategies other than point-to-point-channel", func(t *testing.T) {
+		_, err := startService(&service.Config{
+			ID:                    "broadcast",
+			GRPCServerPort:        -1,
+			H1ServerPort:          -1,
+			AdminPort:             -1,
+			MetricsPort:           -1,
+			GRPCDownstreamServers: []string{"localhost:9090", "localhost:9091"},
+			HedgeDelay:            time.Millisecond * 10,
+			HedgeMaxAttempts:      2,
+		}, strategies.BroadcastChannelStrategyName)
+		if err == nil {
+			t.Fatalf("Expecting error, got nothing")
+		}
+	})
+
+	t.Run("stops the servers already started when it fails, so that it can be started again", func(t *testing.T) {
+		port, err := topology.FreePort()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		config := &service.Config{
+			ID:             "restarted",
+			GRPCServerPort: port,
+			H1ServerPort:   -1,
+			AdminPort:      -1,
+			MetricsPort:    -1,
+		}
+
+		// point-to-point-channel fails without downstream services, once its gRPC server was started
+		_, err = startService(config, strategies.PointToPointStrategyName)
+		if err == nil {
+			t.Fatalf("Expecting error, got nothing")
+		}
+
+		running, err := startService(config, strategies.TerminusStrategyName)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		defer stopTopology([]*runningService{running})
+
+		clients, err := buildProtocolClients(&service.Config{GRPCDownstreamServers: []string{localAddress(port)}, DownstreamTimeout: time.Second})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		defer closeClients(clients)
+
+		if _, err := clients[0].Send(context.TODO(), &pb.TheRequest{RequestUID: t.Name()}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+}
+
+func localAddress(port int) string {
+	return "127.0.0.1:" + strconv.Itoa(port)
+}
@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/buoyantio/bb/metrics"
+	"github.com/buoyantio/bb/service"
+	"github.com/buoyantio/bb/topology"
+	"github.com/buoyantio/bb/tracing"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+const kubernetesFormat = "k8s"
+
+var renderFormat string
+var renderOptions = &topology.RenderOptions{}
+
+var topologyCmd = &cobra.Command{
+	Use:   "topology",
+	Short: "Works with topology files, which declare a whole graph of services in YAML.",
+}
+
+var topologyRunCmd = &cobra.Command{
+	Use:   "run <file>",
+	Short: "Runs every service declared in a topology file within this process.",
+	Long: `Runs every service declared in a topology file within this process, on loopback ports.
+
+Each node in the file declares its name, strategy, ports, fault settings, an optional scenario changing those along a
+timeline, and the nodes it sends requests to. Strategy arguments can refer to a downstream node as ${node}, which is
+replaced by the address used to reach it. Every other setting, such as timeouts, retries or tracing, is taken from the
+flags and shared by all nodes, and a single metrics server is exposed for the whole process. A node crashed by its
+scenario is stopped abruptly, while the others keep running.`,
+	Example: "bb topology run examples/bb-readme/topology.yaml --metrics-port 9100",
+	Args:    cobra.ExactArgs(1),
+
+	Run: func(cmd *cobra.Command, args []string) {
+		t, err := topology.ReadFile(args[0])
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		tracingProvider, err := tracing.NewProviderIfConfigured(config)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		metricsServer, err := metrics.NewServerIfConfigured(config.BindHost, config.MetricsPort)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		nodes, err := startTopology(t, config, tracingProvider)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+		log.Infof("Topology [%s] is ready and waiting for incoming connections", args[0])
+
+		stopped := make(chan *runningService)
+		crashed := make(chan *runningService)
+		for _, node := range nodes {
+			go func(node *runningService) {
+				select {
+				case <-node.Stopping():
+					stopped <- node
+				case <-node.Crashed():
+					crashed <- node
+				}
+			}(node)
+		}
+
+		running := len(nodes)
+		for running > 0 {
+			select {
+			case <-stop:
+				log.Infof("Stopping topology [%s] due to interrupt", args[0])
+				running = 0
+			case node := <-stopped:
+				log.Infof("Stopping node [%s] due to handler", node.config.ID)
+				node.shutdown()
+				nodes = withoutNode(nodes, node)
+				running--
+			case node := <-crashed:
+				log.Errorf("Node [%s] crashed due to its scenario", node.config.ID)
+				node.kill()
+				nodes = withoutNode(nodes, node)
+				running--
+			}
+		}
+
+		stopTopology(nodes)
+
+		if metricsServer != nil {
+			metricsServer.Shutdown()
+		}
+
+		if tracingProvider != nil {
+			tracingProvider.Shutdown()
+		}
+	},
+}
+
+var topologyRenderCmd = &cobra.Command{
+	Use:   "render <file>",
+	Short: "Writes the manifests needed to deploy every service declared in a topology file.",
+	Long: `Writes the manifests needed to deploy every service declared in a topology file.
+
+With the k8s format, a Namespace is written along with a Deployment and a Service for each node, named after the
+namespace and the node. Ports not set in the topology default to 9090 for gRPC, 8080 for HTTP 1.1 and 9990 for the
+admin server.`,
+	Example: "bb topology render examples/bb-readme/topology.yaml --format k8s --mesh linkerd | kubectl apply -f -",
+	Args:    cobra.ExactArgs(1),
+
+	Run: func(cmd *cobra.Command, args []string) {
+		if renderFormat != kubernetesFormat {
+			log.Fatalf("format must be [%s], but was [%s]", kubernetesFormat, renderFormat)
+		}
+
+		t, err := topology.ReadFile(args[0])
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		if err := t.RenderKubernetes(cmd.OutOrStdout(), renderOptions); err != nil {
+			log.Fatalln(err)
+		}
+	},
+}
+
+// startTopology starts every node of the topology, each one after its downstream nodes. Metrics are process-wide, so
+// nodes never start their own metrics server, and their spans are exported by the tracing provider supplied, if any,
+// each node under its own service name.
+func startTopology(t *topology.Topology, base *service.Config, tracingProvider *tracing.Provider) ([]*runningService, error) {
+	nodeConfigs, err := t.Configs(base, topology.FreePort)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, nodeConfig := range nodeConfigs {
+		if strategyByName[nodeConfig.Node.Strategy] == nil {
+			return nil, fmt.Errorf("node [%s] has strategy [%s], but there's no strategy with that name", nodeConfig.Node.Name, nodeConfig.Node.Strategy)
+		}
+	}
+
+	nodes := make([]*runningService, 0, len(nodeConfigs))
+	for _, nodeConfig := range nodeConfigs {
+		nodeConfig.Config.MetricsPort = -1
+		if tracingProvider != nil {
+			nodeConfig.Config.TracerProvider = tracingProvider.TracerProvider(nodeConfig.Config.ID)
+		}
+
+		node, err := startService(nodeConfig.Config, nodeConfig.Node.Strategy)
+		if err != nil {
+			stopTopology(nodes)
+			return nil, err
+		}
+
+		if nodeConfig.Node.Scenario != nil {
+			node.runScenario(nodeConfig.Node.Scenario)
+		}
+
+		log.Infof("Node [%s] started with gRPC port [%d], HTTP 1.1 port [%d] and admin port [%d]", nodeConfig.Node.Name, nodeConfig.Config.GRPCServerPort, nodeConfig.Config.H1ServerPort, nodeConfig.Config.AdminPort)
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// stopTopology drains all nodes at once, waiting for the longest pre-stop delay among them, and then stops them in the
+// reverse order they were started in, so that no node outlives its callers
+func stopTopology(nodes []*runningService) {
+	var preStopDelay time.Duration
+	for _, node := range nodes {
+		node.drain()
+		if node.config.PreStopDelay > preStopDelay {
+			preStopDelay = node.config.PreStopDelay
+		}
+	}
+
+	if preStopDelay > 0 {
+		log.Infof("Nodes are no longer ready, waiting [%v] before they stop accepting requests", preStopDelay)
+		time.Sleep(preStopDelay)
+	}
+
+	for i := len(nodes) - 1; i >= 0; i-- {
+		nodes[i].stopGracefully()
+	}
+}
+
+func withoutNode(nodes []*runningService, node *runningService) []*runningService {
+	remaining := make([]*runningService, 0, len(nodes))
+	for _, n := range nodes {
+		if n != node {
+			remaining = append(remaining, n)
+		}
+	}
+	return remaining
+}
+
+func init() {
+	RootCmd.AddCommand(topologyCmd)
+	topologyCmd.AddCommand(topologyRunCmd)
+	topologyCmd.AddCommand(topologyRenderCmd)
+	topologyRenderCmd.PersistentFlags().StringVar(&renderFormat, "format", kubernetesFormat, fmt.Sprintf("format of the manifests, only [%s] is supported", kubernetesFormat))
+	topologyRenderCmd.PersistentFlags().StringVar(&renderOptions.Namespace, "namespace", "", "namespace to deploy to, defaults to the name of the topology")
+	topologyRenderCmd.PersistentFlags().StringVar(&renderOptions.Image, "image", "buoyantio/bb:latest", "container image to run each node with")
+	topologyRenderCmd.PersistentFlags().StringVar(&renderOptions.Mesh, "mesh", topology.NoMesh, fmt.Sprintf("service mesh to annotate pods for proxy injection, must be one of: %s", strings.Join(topology.Meshes, ", ")))
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/buoyantio/bb/service"
+	"github.com/buoyantio/bb/topology"
+)
+
+func TestStartTopology(t *testing.T) {
+	t.Run("serves requests through every node of the topology", func(t *testing.T) {
+		h1Port, err := topology.FreePort()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		graph, err := topology.Parse(strings.NewReader(fmt.Sprintf(`
+nodes:
+- name: gateway
+  strategy: point-to-point-channel
+  h1-server-port: %d
+  downstreams:
+  - node: middle
+- name: middle
+  strategy: point-to-point-channel
+  downstreams:
+  - node: terminus
+    protocol: h1
+- name: terminus
+  strategy: terminus
+  arguments:
+    response-text: BANANA
+`, h1Port)))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		nodes, err := startTopology(graph, &service.Config{DownstreamTimeout: time.Second * 5}, nil)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		defer stopTopology(nodes)
+
+		var resp *http.Response
+		for attempt := 0; attempt < 50; attempt++ {
+			if resp, err = http.Get(fmt.Sprintf("http://127.0.0.1:%d", h1Port)); err == nil {
+				break
+			}
+			time.Sleep(time.Millisecond * 20)
+		}
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "BANANA") {
+			t.Fatalf("Expected response with payload [BANANA], got [%d] %s", resp.StatusCode, body)
+		}
+	})
+
+	t.Run("returns error for nodes with an unknown strategy", func(t *testing.T) {
+		graph := &topology.Topology{Nodes: []*topology.Node{{Name: "a", Strategy: "unknown"}}}
+
+		_, err := startTopology(graph, &service.Config{}, nil)
+		if err == nil {
+			t.Fatalf("Expecting error, got nothing")
+		}
+	})
+}
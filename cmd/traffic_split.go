@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/buoyantio/bb/strategies"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var splits []string
+
+var trafficSplitCmd = &cobra.Command{
+	Use:     strategies.TrafficSplitStrategyName,
+	Short:   "Forwards the request to one downstream service, picked as per weights that can be changed at runtime via the admin server.",
+	Example: "bb traffic-split --split grpc:localhost:9090=90 --split h1:http://localhost:9091=10 --h1-server-port 8080 --admin-port 9990",
+
+	Run: func(cmd *cobra.Command, args []string) {
+		weights := make([]string, 0)
+		for _, split := range splits {
+			protocol, downstream, weight, err := strategies.ParseTrafficSplit(split)
+			if err != nil {
+				log.Fatalln(err)
+			}
+
+			if protocol == "grpc" {
+				config.GRPCDownstreamServers = append(config.GRPCDownstreamServers, downstream)
+			} else {
+				config.H1DownstreamServers = append(config.H1DownstreamServers, downstream)
+			}
+			weights = append(weights, fmt.Sprintf("%s=%d", downstream, weight))
+		}
+
+		config.ExtraArguments[strategies.TrafficSplitArgName] = strings.Join(weights, ",")
+		err := newService(config, strategies.TrafficSplitStrategyName)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(trafficSplitCmd)
+	trafficSplitCmd.PersistentFlags().StringArrayVar(&splits, strategies.TrafficSplitArgName, []string{}, "downstream service and its weight, as protocol:downstream=weight where protocol is grpc or h1, can be repeated")
+}
@@ -0,0 +1,162 @@
+package load
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	pb "github.com/buoyantio/bb/gen"
+	"github.com/buoyantio/bb/service"
+	log "github.com/sirupsen/logrus"
+)
+
+// Options configures how much load is generated and for how long.
+type Options struct {
+	// RequestsPerSecond is the rate requests are sent at. If zero, each worker sends requests back to back.
+	RequestsPerSecond float64
+
+	// Concurrency is how many requests can be in flight at once.
+	Concurrency int
+
+	// Duration is how long the load is measured for, after the warm-up.
+	Duration time.Duration
+
+	// WarmUp is how long requests are sent for before any are measured.
+	WarmUp time.Duration
+
+	// RampUp is how long it takes for the rate to grow linearly to RequestsPerSecond, starting with the warm-up.
+	RampUp time.Duration
+
+	// GracePeriod is how long requests still in flight at the end of the duration are waited for, after which they are
+	// counted as timed out.
+	GracePeriod time.Duration
+}
+
+// Validate returns an error if the Options can't be used to generate load
+func (o *Options) Validate() error {
+	if o.Concurrency < 1 {
+		return fmt.Errorf("concurrency must be at least 1, but was [%d]", o.Concurrency)
+	}
+
+	if o.RequestsPerSecond < 0 || o.Duration <= 0 || o.WarmUp < 0 || o.RampUp < 0 {
+		return fmt.Errorf("requests per second [%f], warm-up [%v] and ramp-up [%v] can't be negative and duration [%v] must be positive", o.RequestsPerSecond, o.WarmUp, o.RampUp, o.Duration)
+	}
+
+	if o.GracePeriod < 0 {
+		return fmt.Errorf("grace period can't be negative, but was [%v]", o.GracePeriod)
+	}
+
+	if o.RampUp > 0 && o.RequestsPerSecond == 0 {
+		return fmt.Errorf("ramp-up requires a rate of requests per second to ramp up to")
+	}
+
+	return nil
+}
+
+// dueAt returns when the n-th request is due, counting from the start of the run, so that requests are sent at
+// RequestsPerSecond once ramped up, and at a rate growing linearly until then.
+func (o *Options) dueAt(n uint64) time.Duration {
+	rate := o.RequestsPerSecond
+	rampUp := o.RampUp.Seconds()
+
+	// during the ramp-up, the n-th request is due when rate*t^2/(2*rampUp) requests have been sent
+	if rampUp > 0 && float64(n) < rate*rampUp/2 {
+		return time.Duration(math.Sqrt(2*float64(n)*rampUp/rate) * float64(time.Second))
+	}
+	return time.Duration((float64(n)/rate + rampUp/2) * float64(time.Second))
+}
+
+// pace sends a token to the workers every time a request is due, until the context is done. At a fixed rate, tokens
+// carry when their request was due, so that requests held up by busy workers are measured from then rather than from
+// when they were actually sent. Otherwise, tokens carry the zero time, as requests are due as soon as they're sent.
+func pace(ctx context.Context, options *Options, start time.Time, tokens chan<- time.Time) {
+	defer close(tokens)
+
+	for n := uint64(0); ; n++ {
+		var due time.Time
+		if options.RequestsPerSecond > 0 {
+			due = start.Add(options.dueAt(n))
+			timer := time.NewTimer(time.Until(due))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case tokens <- due:
+		}
+	}
+}
+
+// Run sends requests to the clients supplied, in turn, as per the Options, and reports on the requests sent after
+// the warm-up. Requests still in flight at the end of the duration are waited for up to the grace period, unless ctx
+// is done, in which case they are left out of the report.
+func Run(ctx context.Context, clients []service.Client, options *Options) (*Report, error) {
+	if len(clients) == 0 {
+		return nil, fmt.Errorf("load requires at least one target, but had clients [%v]", clients)
+	}
+
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	measureFrom := start.Add(options.WarmUp)
+	measureUntil := measureFrom.Add(options.Duration)
+	runCtx, cancel := context.WithDeadline(ctx, measureUntil)
+	defer cancel()
+	sendCtx, cancelSend := context.WithDeadline(ctx, measureUntil.Add(options.GracePeriod))
+	defer cancelSend()
+
+	tokens := make(chan time.Time)
+	go pace(runCtx, options, start, tokens)
+
+	log.Infof("Sending load to %v for [%v] after a warm-up of [%v]", clients, options.Duration, options.WarmUp)
+
+	var sent uint64
+	recorder := newRecorder()
+	var wg sync.WaitGroup
+	for i := 0; i < options.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for due := range tokens {
+				n := atomic.AddUint64(&sent, 1)
+				client := clients[(n-1)%uint64(len(clients))]
+				req := &pb.TheRequest{RequestUID: fmt.Sprintf("load-%d-%d", start.UnixNano(), n)}
+
+				requestStart := due
+				if requestStart.IsZero() {
+					requestStart = time.Now()
+				}
+				_, err := client.Send(sendCtx, req)
+				latency := time.Since(requestStart)
+
+				// requests cut short by ctx say nothing about the target, unlike those outlasting the grace period
+				if requestStart.Before(measureFrom) || ctx.Err() != nil {
+					continue
+				}
+				if err != nil && sendCtx.Err() != nil {
+					err = context.DeadlineExceeded
+				}
+				recorder.record(latency, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// the run is cut short if ctx is done before the end of the duration
+	measuredUntil := time.Now()
+	if measuredUntil.After(measureUntil) {
+		measuredUntil = measureUntil
+	}
+	return recorder.report(measuredUntil.Sub(measureFrom)), nil
+}
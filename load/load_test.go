@@ -0,0 +1,270 @@
+package load
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pb "github.com/buoyantio/bb/gen"
+	"github.com/buoyantio/bb/service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type countingClient struct {
+	sent         int64
+	errToReturn  error
+	sleepForSend time.Duration
+}
+
+func (c *countingClient) Close() error { return nil }
+
+func (c *countingClient) GetID() string { return "counting" }
+
+func (c *countingClient) Send(ctx context.Context, req *pb.TheRequest) (*pb.TheResponse, error) {
+	atomic.AddInt64(&c.sent, 1)
+	time.Sleep(c.sleepForSend)
+	return &pb.TheResponse{RequestUID: req.RequestUID}, c.errToReturn
+}
+
+// slowClient responds after delay, unless ctx is done first
+type slowClient struct {
+	delay time.Duration
+}
+
+func (c *slowClient) Close() error { return nil }
+
+func (c *slowClient) GetID() string { return "slow" }
+
+func (c *slowClient) Send(ctx context.Context, req *pb.TheRequest) (*pb.TheResponse, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(c.delay):
+		return &pb.TheResponse{RequestUID: req.RequestUID}, nil
+	}
+}
+
+func TestRun(t *testing.T) {
+	t.Run("sends requests at a fixed concurrency", func(t *testing.T) {
+		client := &countingClient{sleepForSend: time.Millisecond}
+		report, err := Run(context.TODO(), []service.Client{client}, &Options{Concurrency: 4, Duration: 100 * time.Millisecond})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if report.Requests == 0 || report.Successes != report.Requests || report.SuccessRate != 1 {
+			t.Fatalf("Expected only successful requests, but got %+v", report)
+		}
+
+		if report.Percentiles.P50 < 1 || report.MinMillis < 1 {
+			t.Fatalf("Expected latencies of at least 1ms, but got %+v", report)
+		}
+	})
+
+	t.Run("sends requests at a fixed rate", func(t *testing.T) {
+		client := &countingClient{}
+		report, err := Run(context.TODO(), []service.Client{client}, &Options{RequestsPerSecond: 100, Concurrency: 4, Duration: 500 * time.Millisecond})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if report.Requests < 25 || report.Requests > 75 {
+			t.Fatalf("Expected around [50] requests at 100 requests per second, but got [%d]", report.Requests)
+		}
+	})
+
+	t.Run("doesn't report on requests sent during the warm-up", func(t *testing.T) {
+		client := &countingClient{}
+		report, err := Run(context.TODO(), []service.Client{client}, &Options{RequestsPerSecond: 100, Concurrency: 1, Duration: 200 * time.Millisecond, WarmUp: 200 * time.Millisecond})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		sent := int(atomic.LoadInt64(&client.sent))
+		if report.Requests >= sent-5 {
+			t.Fatalf("Expected warm-up requests to be left out of the report, but reported [%d] of [%d] requests", report.Requests, sent)
+		}
+	})
+
+	t.Run("measures latency from when requests were due at a fixed rate", func(t *testing.T) {
+		client := &countingClient{sleepForSend: 20 * time.Millisecond}
+		report, err := Run(context.TODO(), []service.Client{client}, &Options{RequestsPerSecond: 200, Concurrency: 1, Duration: 300 * time.Millisecond})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		// a single worker sends one request every 20ms, so requests due every 5ms wait longer and longer to be sent
+		if report.MaxMillis < 100 {
+			t.Fatalf("Expected the time requests waited to be sent to count towards their latency, but got %+v", report)
+		}
+	})
+
+	t.Run("reports the rate over the time requests were measured for", func(t *testing.T) {
+		client := &countingClient{}
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		report, err := Run(ctx, []service.Client{client}, &Options{RequestsPerSecond: 100, Concurrency: 1, Duration: 10 * time.Second})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if report.RequestsPerSecond < 50 || report.RequestsPerSecond > 150 {
+			t.Fatalf("Expected around [100] requests per second when stopped early, but got [%f]", report.RequestsPerSecond)
+		}
+	})
+
+	t.Run("waits for requests still in flight at the end of the duration up to the grace period", func(t *testing.T) {
+		clients := []service.Client{&slowClient{delay: 100 * time.Millisecond}}
+		report, err := Run(context.TODO(), clients, &Options{Concurrency: 1, Duration: 50 * time.Millisecond, GracePeriod: time.Second})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if report.Requests != 1 || report.Successes != 1 {
+			t.Fatalf("Expected the request in flight at the end of the duration to succeed, but got %+v", report)
+		}
+
+		report, err = Run(context.TODO(), clients, &Options{Concurrency: 1, Duration: 50 * time.Millisecond, GracePeriod: 10 * time.Millisecond})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if report.Requests != 1 || report.Errors["deadline exceeded"] != 1 {
+			t.Fatalf("Expected the request outlasting the grace period to time out, but got %+v", report)
+		}
+	})
+
+	t.Run("reports errors by kind", func(t *testing.T) {
+		clients := []service.Client{
+			&countingClient{errToReturn: status.Error(codes.Unavailable, "expected")},
+			&countingClient{errToReturn: &service.HTTPStatusError{StatusCode: 503}},
+		}
+		report, err := Run(context.TODO(), clients, &Options{Concurrency: 2, Duration: 50 * time.Millisecond})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if report.Successes != 0 || report.Errors["grpc Unavailable"] == 0 || report.Errors["http 503"] == 0 {
+			t.Fatalf("Expected only gRPC unavailable and HTTP 503 errors, but got %+v", report)
+		}
+	})
+
+	t.Run("returns error if misconfigured", func(t *testing.T) {
+		clients := []service.Client{&countingClient{}}
+		invalidOptions := []*Options{
+			{Concurrency: 0, Duration: time.Second},
+			{Concurrency: 1, Duration: 0},
+			{Concurrency: 1, Duration: time.Second, RequestsPerSecond: -1},
+			{Concurrency: 1, Duration: time.Second, RampUp: time.Second},
+			{Concurrency: 1, Duration: time.Second, GracePeriod: -time.Second},
+		}
+
+		for _, options := range invalidOptions {
+			_, err := Run(context.TODO(), clients, options)
+			if err == nil {
+				t.Fatalf("Expecting error for options %+v, got nothing", options)
+			}
+		}
+
+		_, err := Run(context.TODO(), []service.Client{}, &Options{Concurrency: 1, Duration: time.Second})
+		if err == nil {
+			t.Fatalf("Expecting error, got nothing")
+		}
+	})
+}
+
+func TestOptions(t *testing.T) {
+	t.Run("spaces requests evenly at a fixed rate", func(t *testing.T) {
+		options := &Options{RequestsPerSecond: 100}
+
+		for n, expected := range map[uint64]time.Duration{0: 0, 1: 10 * time.Millisecond, 100: time.Second} {
+			if due := options.dueAt(n); due != expected {
+				t.Fatalf("Expected request [%d] to be due after [%v], but got [%v]", n, expected, due)
+			}
+		}
+	})
+
+	t.Run("ramps the rate up linearly", func(t *testing.T) {
+		options := &Options{RequestsPerSecond: 100, RampUp: 10 * time.Second}
+
+		// 5 requests are sent in the first second, when the rate grows to 10 per second, 500 during the whole
+		// ramp-up, then 100 per second
+		expectedDue := map[uint64]time.Duration{
+			0:   0,
+			5:   time.Second,
+			500: 10 * time.Second,
+			600: 11 * time.Second,
+		}
+		for n, expected := range expectedDue {
+			if due := options.dueAt(n); due.Round(time.Millisecond) != expected {
+				t.Fatalf("Expected request [%d] to be due after [%v], but got [%v]", n, expected, due)
+			}
+		}
+	})
+}
+
+func TestReport(t *testing.T) {
+	r := newRecorder()
+	for i := 1; i <= 1000; i++ {
+		var err error
+		if i%100 == 0 {
+			err = &net.OpError{Op: "dial", Err: errors.New("expected")}
+		}
+		r.record(time.Duration(i)*time.Millisecond, err)
+	}
+	report := r.report(time.Second)
+
+	t.Run("computes percentiles and success rate", func(t *testing.T) {
+		expected := Percentiles{P50: 500, P90: 900, P99: 990, P999: 999}
+		if report.Percentiles != expected {
+			t.Fatalf("Expected percentiles %+v, but got %+v", expected, report.Percentiles)
+		}
+
+		if report.Requests != 1000 || report.Successes != 990 || report.SuccessRate != 0.99 || report.RequestsPerSecond != 1000 {
+			t.Fatalf("Expected [990] of [1000] requests to succeed, but got %+v", report)
+		}
+
+		if report.Errors["connection error"] != 10 {
+			t.Fatalf("Expected [10] connection errors, but got %v", report.Errors)
+		}
+	})
+
+	t.Run("counts latencies into histogram buckets", func(t *testing.T) {
+		total := 0
+		for _, bucket := range report.Histogram {
+			total += bucket.Count
+		}
+
+		if total != 1000 || report.Histogram[0].Count != 1 || report.Histogram[len(report.Histogram)-1].UpperBound != "+Inf" {
+			t.Fatalf("Expected every request to fall in a bucket, but got %+v", report.Histogram)
+		}
+	})
+
+	t.Run("writes the report as JSON and text", func(t *testing.T) {
+		var jsonOutput bytes.Buffer
+		if err := report.WriteJSON(&jsonOutput); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		var decoded Report
+		if err := json.Unmarshal(jsonOutput.Bytes(), &decoded); err != nil || decoded.Percentiles != report.Percentiles {
+			t.Fatalf("Expected JSON report to decode to %+v, but got %+v (error: %v)", report, decoded, err)
+		}
+
+		var textOutput bytes.Buffer
+		if err := report.WriteText(&textOutput); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if !strings.Contains(textOutput.String(), "p99 990.000") || !strings.Contains(textOutput.String(), "connection error") {
+			t.Fatalf("Expected text report to contain percentiles and errors, but got:\n%s", textOutput.String())
+		}
+	})
+}
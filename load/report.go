@@ -0,0 +1,206 @@
+package load
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/buoyantio/bb/service"
+	"google.golang.org/grpc/status"
+)
+
+// histogramBoundsInMillis are the upper bounds of the buckets of the latency histogram
+var histogramBoundsInMillis = []float64{1, 2, 5, 10, 20, 50, 100, 200, 500, 1000, 2000, 5000, 10000}
+
+// Percentiles holds latency percentiles, in milliseconds
+type Percentiles struct {
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	P99  float64 `json:"p99"`
+	P999 float64 `json:"p999"`
+}
+
+// Bucket counts the requests whose latency, in milliseconds, was at most UpperBound and more than the previous bucket's.
+type Bucket struct {
+	UpperBound string `json:"le"`
+	Count      int    `json:"count"`
+}
+
+// Report summarises the requests sent while generating load.
+type Report struct {
+	Requests          int            `json:"requests"`
+	Successes         int            `json:"successes"`
+	SuccessRate       float64        `json:"successRate"`
+	RequestsPerSecond float64        `json:"requestsPerSecond"`
+	MinMillis         float64        `json:"minMillis"`
+	MeanMillis        float64        `json:"meanMillis"`
+	MaxMillis         float64        `json:"maxMillis"`
+	Percentiles       Percentiles    `json:"percentiles"`
+	Histogram         []Bucket       `json:"histogram"`
+	Errors            map[string]int `json:"errors"`
+}
+
+// WriteJSON writes the report as JSON
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// WriteText writes the report in a human-readable format
+func (r *Report) WriteText(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Requests:      %d (%.1f/s)\n", r.Requests, r.RequestsPerSecond)
+	fmt.Fprintf(&b, "Success rate:  %.2f%%\n", r.SuccessRate*100)
+	fmt.Fprintf(&b, "Latency (ms):  min %.3f, mean %.3f, max %.3f\n", r.MinMillis, r.MeanMillis, r.MaxMillis)
+	fmt.Fprintf(&b, "Percentiles:   p50 %.3f, p90 %.3f, p99 %.3f, p999 %.3f\n", r.Percentiles.P50, r.Percentiles.P90, r.Percentiles.P99, r.Percentiles.P999)
+
+	fmt.Fprintf(&b, "Histogram:\n")
+	for _, bucket := range r.Histogram {
+		bar := ""
+		if r.Requests > 0 {
+			bar = strings.Repeat("#", int(math.Round(40*float64(bucket.Count)/float64(r.Requests))))
+		}
+		fmt.Fprintf(&b, "  <= %6s ms  %8d  %s\n", bucket.UpperBound, bucket.Count, bar)
+	}
+
+	if len(r.Errors) > 0 {
+		fmt.Fprintf(&b, "Errors:\n")
+		kinds := make([]string, 0, len(r.Errors))
+		for kind := range r.Errors {
+			kinds = append(kinds, kind)
+		}
+		sort.Strings(kinds)
+		for _, kind := range kinds {
+			fmt.Fprintf(&b, "  %-30s %d\n", kind, r.Errors[kind])
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// classifyError groups errors by their gRPC code or HTTP status, or by whether the target couldn't be reached
+func classifyError(err error) string {
+	var httpErr *service.HTTPStatusError
+	if errors.As(err, &httpErr) {
+		return fmt.Sprintf("http %d", httpErr.StatusCode)
+	}
+
+	if grpcStatus, isGRPC := status.FromError(err); isGRPC {
+		return fmt.Sprintf("grpc %s", grpcStatus.Code())
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "deadline exceeded"
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return "connection error"
+	}
+	return "other"
+}
+
+type recorder struct {
+	mu        sync.Mutex
+	latencies []time.Duration
+	errors    map[string]int
+}
+
+func newRecorder() *recorder {
+	return &recorder{errors: map[string]int{}}
+}
+
+func (r *recorder) record(latency time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.latencies = append(r.latencies, latency)
+	if err != nil {
+		r.errors[classifyError(err)]++
+	}
+}
+
+func toMillis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// percentile returns the p-th percentile of the sorted latencies supplied
+func percentile(sorted []time.Duration, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	// the tolerance keeps floating point errors from pushing exact ranks, e.g. p999 of 1000 requests, to the next one
+	index := int(math.Ceil(p/100*float64(len(sorted))-1e-9)) - 1
+	if index < 0 {
+		index = 0
+	}
+	return toMillis(sorted[index])
+}
+
+// report summarises the requests recorded over elapsed, the time they were actually measured for
+func (r *recorder) report(elapsed time.Duration) *Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sorted := make([]time.Duration, len(r.latencies))
+	copy(sorted, r.latencies)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	failures := 0
+	for _, count := range r.errors {
+		failures += count
+	}
+
+	report := &Report{
+		Requests:  len(sorted),
+		Successes: len(sorted) - failures,
+		Percentiles: Percentiles{
+			P50:  percentile(sorted, 50),
+			P90:  percentile(sorted, 90),
+			P99:  percentile(sorted, 99),
+			P999: percentile(sorted, 99.9),
+		},
+		Errors: map[string]int{},
+	}
+	for kind, count := range r.errors {
+		report.Errors[kind] = count
+	}
+
+	if elapsed > 0 {
+		report.RequestsPerSecond = float64(len(sorted)) / elapsed.Seconds()
+	}
+
+	var total time.Duration
+	for _, latency := range sorted {
+		total += latency
+	}
+	if len(sorted) > 0 {
+		report.SuccessRate = float64(report.Successes) / float64(len(sorted))
+		report.MinMillis = toMillis(sorted[0])
+		report.MaxMillis = toMillis(sorted[len(sorted)-1])
+		report.MeanMillis = toMillis(total / time.Duration(len(sorted)))
+	}
+
+	next := 0
+	for _, bound := range histogramBoundsInMillis {
+		bucket := Bucket{UpperBound: fmt.Sprintf("%g", bound)}
+		for next < len(sorted) && toMillis(sorted[next]) <= bound {
+			bucket.Count++
+			next++
+		}
+		report.Histogram = append(report.Histogram, bucket)
+	}
+	report.Histogram = append(report.Histogram, Bucket{UpperBound: "+Inf", Count: len(sorted) - next})
+
+	return report
+}
@@ -0,0 +1,147 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	// InjectedFailure is the fault recorded when a request is failed on purpose
+	InjectedFailure = "failure"
+
+	// InjectedLatency is the fault recorded when a request is delayed on purpose
+	InjectedLatency = "latency"
+
+	// InjectedTransportFault is the fault recorded when the connection of a request is broken on purpose
+	InjectedTransportFault = "transport"
+)
+
+// RequestMetrics counts requests, errors and requests in flight, and records their latency, labelled by the component
+// handling them, and by the service it belongs to where a process can run several services.
+type RequestMetrics struct {
+	requests *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	inFlight *prometheus.GaugeVec
+	latency  *prometheus.HistogramVec
+}
+
+// Start records a request being handled by the component identified by the label values supplied, and returns a
+// function to be called with its outcome once it completes.
+func (m *RequestMetrics) Start(labelValues ...string) func(err error) {
+	start := time.Now()
+	m.requests.WithLabelValues(labelValues...).Inc()
+	m.inFlight.WithLabelValues(labelValues...).Inc()
+
+	return func(err error) {
+		m.inFlight.WithLabelValues(labelValues...).Dec()
+		m.latency.WithLabelValues(labelValues...).Observe(time.Since(start).Seconds())
+		if err != nil {
+			m.errors.WithLabelValues(labelValues...).Inc()
+		}
+	}
+}
+
+func newRequestMetrics(subsystem string, description string, labels ...string) *RequestMetrics {
+	return &RequestMetrics{
+		requests: promauto.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "bb",
+			Subsystem: subsystem,
+			Name:      "requests_total",
+			Help:      "Total number of requests " + description,
+		}, labels),
+		errors: promauto.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "bb",
+			Subsystem: subsystem,
+			Name:      "errors_total",
+			Help:      "Total number of failed requests " + description,
+		}, labels),
+		inFlight: promauto.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "bb",
+			Subsystem: subsystem,
+			Name:      "requests_in_flight",
+			Help:      "Number of requests " + description + " currently in flight",
+		}, labels),
+		latency: promauto.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "bb",
+			Subsystem: subsystem,
+			Name:      "request_duration_seconds",
+			Help:      "Latency of requests " + description,
+			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 16),
+		}, labels),
+	}
+}
+
+var (
+	// Servers records the requests received by each gRPC and HTTP server
+	Servers = newRequestMetrics("server", "received by the server", "server")
+
+	// Clients records the requests sent to each downstream service by each service
+	Clients = newRequestMetrics("client", "sent to the downstream service", "service", "client")
+
+	// Strategies records the requests processed by the strategy of each service, as topologies run several services
+	// in the same process
+	Strategies = newRequestMetrics("strategy", "processed by the strategy", "service", "strategy")
+
+	injectedFaults = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "bb",
+		Name:      "injected_faults_total",
+		Help:      "Total number of faults injected into requests, by service and type of fault",
+	}, []string{"service", "fault"})
+
+	injectedLatency = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "bb",
+		Name:      "injected_latency_seconds_total",
+		Help:      "Total latency injected into requests, by service",
+	}, []string{"service"})
+
+	burnedCPU = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "bb",
+		Name:      "burned_cpu_seconds_total",
+		Help:      "Total time spent spinning the CPU on purpose while handling requests",
+	})
+
+	allocatedMemory = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "bb",
+		Name:      "allocated_memory_bytes_total",
+		Help:      "Total memory allocated on purpose while handling requests",
+	})
+
+	leakedMemory = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "bb",
+		Name:      "leaked_memory_bytes",
+		Help:      "Memory leaked on purpose, which is never released",
+	})
+)
+
+// RecordInjectedFailure counts a request failed on purpose by the service supplied
+func RecordInjectedFailure(service string) {
+	injectedFaults.WithLabelValues(service, InjectedFailure).Inc()
+}
+
+// RecordInjectedTransportFault counts a request whose connection was broken on purpose by the service supplied
+func RecordInjectedTransportFault(service string) {
+	injectedFaults.WithLabelValues(service, InjectedTransportFault).Inc()
+}
+
+// RecordInjectedLatency counts a request delayed on purpose by the service supplied, and for how long
+func RecordInjectedLatency(service string, latency time.Duration) {
+	injectedFaults.WithLabelValues(service, InjectedLatency).Inc()
+	injectedLatency.WithLabelValues(service).Add(latency.Seconds())
+}
+
+// RecordBurnedCPU counts the time spent spinning the CPU on purpose while handling a request
+func RecordBurnedCPU(burned time.Duration) {
+	burnedCPU.Add(burned.Seconds())
+}
+
+// RecordAllocatedMemory counts the memory allocated on purpose while handling a request
+func RecordAllocatedMemory(bytes int) {
+	allocatedMemory.Add(float64(bytes))
+}
+
+// RecordLeakedMemory counts memory leaked on purpose
+func RecordLeakedMemory(bytes int) {
+	leakedMemory.Add(float64(bytes))
+}
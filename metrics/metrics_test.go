@@ -0,0 +1,134 @@
+package metrics
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRequestMetrics(t *testing.T) {
+	t.Run("counts requests, errors and requests in flight", func(t *testing.T) {
+		m := newRequestMetrics("test", "handled by the test", "component")
+
+		succeed := m.Start("a")
+		fail := m.Start("a")
+		other := m.Start("b")
+
+		if inFlight := testutil.ToFloat64(m.inFlight.WithLabelValues("a")); inFlight != 2 {
+			t.Fatalf("Expected [2] requests in flight, but got [%v]", inFlight)
+		}
+
+		succeed(nil)
+		fail(errors.New("expected"))
+
+		if inFlight := testutil.ToFloat64(m.inFlight.WithLabelValues("a")); inFlight != 0 {
+			t.Fatalf("Expected no requests in flight, but got [%v]", inFlight)
+		}
+
+		if requests := testutil.ToFloat64(m.requests.WithLabelValues("a")); requests != 2 {
+			t.Fatalf("Expected [2] requests, but got [%v]", requests)
+		}
+
+		if errs := testutil.ToFloat64(m.errors.WithLabelValues("a")); errs != 1 {
+			t.Fatalf("Expected [1] error, but got [%v]", errs)
+		}
+
+		if latencies := testutil.CollectAndCount(m.latency); latencies != 1 {
+			t.Fatalf("Expected latencies to be recorded only for completed requests, but got [%d] series", latencies)
+		}
+
+		other(nil)
+		if requests := testutil.ToFloat64(m.requests.WithLabelValues("b")); requests != 1 {
+			t.Fatalf("Expected [1] request, but got [%v]", requests)
+		}
+	})
+
+	t.Run("labels requests by every label supplied", func(t *testing.T) {
+		m := newRequestMetrics("test_labels", "handled by the test", "service", "strategy")
+
+		m.Start("a", "terminus")(nil)
+		m.Start("b", "terminus")(nil)
+		m.Start("b", "terminus")(nil)
+
+		if requests := testutil.ToFloat64(m.requests.WithLabelValues("a", "terminus")); requests != 1 {
+			t.Fatalf("Expected [1] request for service [a], but got [%v]", requests)
+		}
+
+		if requests := testutil.ToFloat64(m.requests.WithLabelValues("b", "terminus")); requests != 2 {
+			t.Fatalf("Expected [2] requests for service [b], but got [%v]", requests)
+		}
+	})
+}
+
+func TestInjectedFaults(t *testing.T) {
+	t.Run("counts injected faults by service and type", func(t *testing.T) {
+		failuresBefore := testutil.ToFloat64(injectedFaults.WithLabelValues("a", InjectedFailure))
+		latencyBefore := testutil.ToFloat64(injectedLatency.WithLabelValues("a"))
+
+		RecordInjectedFailure("a")
+		RecordInjectedLatency("a", 500*time.Millisecond)
+		RecordInjectedFailure("b")
+
+		if failures := testutil.ToFloat64(injectedFaults.WithLabelValues("a", InjectedFailure)) - failuresBefore; failures != 1 {
+			t.Fatalf("Expected [1] injected failure for service [a], but got [%v]", failures)
+		}
+
+		if latency := testutil.ToFloat64(injectedLatency.WithLabelValues("a")) - latencyBefore; latency != 0.5 {
+			t.Fatalf("Expected [0.5] seconds of injected latency for service [a], but got [%v]", latency)
+		}
+	})
+}
+
+func TestServer(t *testing.T) {
+	t.Run("isn't created unless a metrics port is configured", func(t *testing.T) {
+		server, err := NewServerIfConfigured("", -1)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if server != nil {
+			t.Fatalf("Expected no metrics server, but got [%v]", server)
+		}
+	})
+
+	t.Run("listens on the bind host configured", func(t *testing.T) {
+		server, err := NewServerIfConfigured("127.0.0.1", 0)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		defer server.Shutdown()
+
+		if server.httpServer.Addr != "127.0.0.1:0" {
+			t.Fatalf("Expected metrics server to listen on [127.0.0.1:0], but got [%s]", server.httpServer.Addr)
+		}
+	})
+
+	t.Run("serves metrics in the Prometheus exposition format", func(t *testing.T) {
+		Servers.Start("grpc-9090")(nil)
+
+		theServer := httptest.NewServer(newMetricsHandler())
+		defer theServer.Close()
+
+		resp, err := http.Get(theServer.URL + MetricsPath)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		defer resp.Body.Close()
+
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		expectedLine := `bb_server_requests_total{server="grpc-9090"} 1`
+		if !strings.Contains(string(body), expectedLine) {
+			t.Fatalf("Expected metrics to contain [%s], but got:\n%s", expectedLine, body)
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	log "github.com/sirupsen/logrus"
+)
+
+// MetricsPath is where metrics are served, in the Prometheus exposition format
+const MetricsPath = "/metrics"
+
+// Server is a HTTP server exposing metrics to be scraped by Prometheus.
+type Server struct {
+	httpServer *http.Server
+	port       int
+}
+
+// GetID returns the identifier of this server
+func (s *Server) GetID() string {
+	return fmt.Sprintf("metrics-%d", s.port)
+}
+
+// Shutdown stops the server
+func (s *Server) Shutdown() error {
+	log.Infof("Shutting down [%s]", s.GetID())
+	return s.httpServer.Shutdown(context.Background())
+}
+
+func newMetricsHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(MetricsPath, promhttp.Handler())
+	return mux
+}
+
+// NewServerIfConfigured returns a metrics Server listening on the host and port supplied, unless the port is -1. An
+// empty host listens on every interface.
+func NewServerIfConfigured(bindHost string, port int) (*Server, error) {
+	if port == -1 {
+		return nil, nil
+	}
+
+	srv := &http.Server{
+		Addr:    net.JoinHostPort(bindHost, strconv.Itoa(port)),
+		Handler: newMetricsHandler(),
+	}
+	go func() {
+		log.Infof("Metrics server listening on [%s]", srv.Addr)
+		srv.ListenAndServe()
+	}()
+
+	return &Server{
+		httpServer: srv,
+		port:       port,
+	}, nil
+}
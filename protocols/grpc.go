@@ -2,14 +2,25 @@ package protocols
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
+	"reflect"
 	"time"
 
 	pb "github.com/buoyantio/bb/gen"
+	"github.com/buoyantio/bb/metrics"
 	"github.com/buoyantio/bb/service"
 	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 type theGrpcServer struct {
@@ -17,40 +28,160 @@ type theGrpcServer struct {
 	grpcServer     *grpc.Server
 	port           int
 	serviceHandler *service.RequestHandler
+	listener       *faultyListener
 }
 
 func (s *theGrpcServer) GetID() string {
 	return fmt.Sprintf("grpc-%d", s.port)
 }
 
-func (s *theGrpcServer) Shutdown() error {
+func (s *theGrpcServer) Shutdown(ctx context.Context) error {
 	log.Infof("Shutting down [%s]", s.GetID())
-	s.grpcServer.GracefulStop()
-	return nil
+	// a server shut down straight after it was created may not be serving on its listener yet, which would stay open
+	defer s.listener.Close()
+
+	stopped := make(chan struct{})
+	go func() {
+		s.grpcServer.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		log.Warnf("Requests to [%s] still in flight after the shutdown grace period, closing their connections", s.GetID())
+		s.grpcServer.Stop()
+		<-stopped
+		return ctx.Err()
+	}
 }
 
 func (s *theGrpcServer) TheFunction(ctx context.Context, req *pb.TheRequest) (*pb.TheResponse, error) {
-	resp, err := s.serviceHandler.Handle(ctx, req)
+	md, _ := metadata.FromIncomingContext(ctx)
+	method, _ := grpc.Method(ctx)
+	inboundReq := service.NewInboundRequest("grpc", method, method, md)
+	recordOutcome := metrics.Servers.Start(s.GetID())
+	resp, err := s.serviceHandler.Handle(service.WithInboundRequest(ctx, inboundReq), req)
+	recordOutcome(err)
+
+	var failure *service.InjectedFailure
+	if errors.As(err, &failure) {
+		if trailers := failure.Headers(); len(trailers) > 0 {
+			grpc.SetTrailer(ctx, metadata.New(trailers))
+		}
+	}
+
+	if fault := s.serviceHandler.PickTransportFault(); fault != nil {
+		return s.injectTransportFault(ctx, fault, resp, err)
+	}
 	log.Infof("Received gRPC request [%s] [%s] Returning response [%+v]", req.RequestUID, req, resp)
 	return resp, err
 }
 
+// injectTransportFault breaks the response to a request, or the connection it was received over
+func (s *theGrpcServer) injectTransportFault(ctx context.Context, fault *service.TransportFault, resp *pb.TheResponse, err error) (*pb.TheResponse, error) {
+	switch fault.Kind {
+	case service.HangFault:
+		<-ctx.Done()
+		return nil, ctx.Err()
+	case service.StallBodyFault:
+		grpc.SendHeader(ctx, metadata.MD{})
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	var conn *faultyConn
+	var found bool
+	if s.listener != nil {
+		conn, found = s.listener.connFor(ctx)
+	}
+	if !found {
+		log.Errorf("Can't inject [%s] as the connection of the request wasn't found", fault)
+		return resp, err
+	}
+
+	// resets and GOAWAY frames apply to the whole connection, other faults only to the stream of the request
+	var streamID uint32
+	if fault.Kind != service.ResetFault && fault.Kind != service.GoAwayFault {
+		var streamErr error
+		if streamID, streamErr = streamIDFor(ctx); streamErr != nil {
+			log.Errorf("Can't inject [%s]: %v", fault, streamErr)
+			return nil, status.Errorf(codes.Internal, "can't inject [%s]: %v", fault, streamErr)
+		}
+	}
+
+	conn.injectFault(streamID, fault)
+	return resp, err
+}
+
+// streamIDFor returns the ID of the HTTP/2 stream the gRPC request carried by ctx was received over. gRPC doesn't
+// expose it, so it is read from the server's stream, which holds it in its unexported id field. As a gRPC upgrade
+// could remove that field, an error is returned if it isn't found, rather than the fault going silently missing.
+func streamIDFor(ctx context.Context) (uint32, error) {
+	transportStream := grpc.ServerTransportStreamFromContext(ctx)
+	stream := reflect.ValueOf(transportStream)
+	if stream.Kind() != reflect.Ptr || stream.IsNil() || stream.Elem().Kind() != reflect.Struct {
+		return 0, fmt.Errorf("the request wasn't received over a HTTP/2 stream, but [%T]", transportStream)
+	}
+
+	id := stream.Elem().FieldByName("id")
+	if !id.IsValid() || id.Kind() != reflect.Uint32 {
+		return 0, fmt.Errorf("the ID of the HTTP/2 stream of the request can't be read, as [%T] has no id field", transportStream)
+	}
+	return uint32(id.Uint()), nil
+}
+
 type theGrpcClient struct {
-	id         string
-	conn       *grpc.ClientConn
-	grpcClient pb.TheServiceClient
-	timeout    time.Duration
+	id                string
+	serviceID         string
+	conn              *grpc.ClientConn
+	grpcClient        pb.TheServiceClient
+	timeout           time.Duration
+	headerPropagation *service.HeaderPropagation
+	tracer            trace.Tracer
 }
 
 func (c *theGrpcClient) GetID() string {
 	return c.id
 }
 
+// Connected reports whether the connection to the downstream service is up, or idle and able to come up on demand
+func (c *theGrpcClient) Connected() bool {
+	if c.conn == nil {
+		return true
+	}
+
+	state := c.conn.GetState()
+	return state == connectivity.Ready || state == connectivity.Idle
+}
+
 func (c *theGrpcClient) Send(ctx context.Context, req *pb.TheRequest) (*pb.TheResponse, error) {
 	cctx, cancel := context.WithDeadline(ctx, time.Now().Add(c.timeout))
 	defer cancel()
 
-	return c.grpcClient.TheFunction(cctx, req)
+	recordOutcome := metrics.Clients.Start(c.serviceID, c.id)
+	cctx, span := service.StartClientSpan(cctx, c.tracer, c.id, req)
+
+	md := metadata.MD{}
+	for name, values := range c.headerPropagation.HeadersFromContext(ctx, "grpc") {
+		md.Append(name, values...)
+	}
+
+	// the trace context of the span just started replaces any propagated from the inbound request
+	traceContext := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(cctx, traceContext)
+	for name, value := range traceContext {
+		md.Set(name, value)
+	}
+	if md.Len() > 0 {
+		cctx = metadata.NewOutgoingContext(cctx, md)
+	}
+
+	resp, err := c.grpcClient.TheFunction(cctx, req)
+	service.EndSpan(span, err)
+	recordOutcome(err)
+	return resp, service.HopErrorFromStatus(err)
 }
 
 func (c *theGrpcClient) Close() error {
@@ -65,21 +196,24 @@ func NewGrpcServerIfConfigured(config *service.Config, serviceHandler *service.R
 	}
 
 	grpcServerPort := config.GRPCServerPort
-	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", grpcServerPort))
+	lis, err := net.Listen("tcp", config.BindAddress(grpcServerPort))
 	if err != nil {
 		return nil, err
 	}
 	grpcServer := grpc.NewServer()
+	listener := newFaultyListener(lis)
 
 	theGrpcServer := &theGrpcServer{
 		grpcServer:     grpcServer,
 		port:           grpcServerPort,
 		serviceHandler: serviceHandler,
+		listener:       listener,
 	}
 
 	pb.RegisterTheServiceServer(grpcServer, theGrpcServer)
+	healthpb.RegisterHealthServer(grpcServer, &grpcHealthServer{health: serviceHandler.Health()})
 	log.Infof("gRPC server listening on port [%d]", grpcServerPort)
-	go func() { grpcServer.Serve(lis) }()
+	go func() { grpcServer.Serve(listener) }()
 	return theGrpcServer, nil
 }
 
@@ -88,6 +222,7 @@ func NewGrpcServerIfConfigured(config *service.Config, serviceHandler *service.R
 func NewGrpcClientsIfConfigured(config *service.Config) ([]service.Client, error) {
 	clients := make([]service.Client, 0)
 
+	headerPropagation := service.NewHeaderPropagation(config)
 	for _, serverURL := range config.GRPCDownstreamServers {
 		target := serverURL
 		authority := ""
@@ -115,10 +250,13 @@ func NewGrpcClientsIfConfigured(config *service.Config) ([]service.Client, error
 		client := pb.NewTheServiceClient(conn)
 		clients = append(clients,
 			&theGrpcClient{
-				id:         clientID,
-				conn:       conn,
-				grpcClient: client,
-				timeout:    config.DownstreamTimeout,
+				id:                clientID,
+				serviceID:         config.ID,
+				conn:              conn,
+				grpcClient:        client,
+				timeout:           config.DownstreamTimeout,
+				headerPropagation: headerPropagation,
+				tracer:            config.Tracer(),
 			},
 		)
 	}
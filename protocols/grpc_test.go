@@ -3,10 +3,19 @@ package protocols
 import (
 	"context"
 	"errors"
+	"net"
 	"testing"
+	"time"
 
 	pb "github.com/buoyantio/bb/gen"
 	"github.com/buoyantio/bb/service"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 func TestTheGrpcServer(t *testing.T) {
@@ -42,6 +51,31 @@ func TestTheGrpcServer(t *testing.T) {
 		}
 	})
 
+	t.Run("passes the incoming metadata on to the strategy", func(t *testing.T) {
+		strategy := &stubStrategy{
+			theResponseToReturn: &pb.TheResponse{},
+		}
+
+		requestHandler := service.NewRequestHandler(&service.Config{})
+		requestHandler.Strategy = strategy
+		grpcServer := theGrpcServer{serviceHandler: requestHandler}
+
+		ctx := metadata.NewIncomingContext(context.TODO(), metadata.Pairs("x-tenant", "gold"))
+		_, err := grpcServer.TheFunction(ctx, &pb.TheRequest{RequestUID: "123"})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		inboundReq, ok := service.InboundRequestFromContext(strategy.theContextReceived)
+		if !ok {
+			t.Fatalf("Expected strategy to receive the inbound request, but it was missing")
+		}
+
+		if inboundReq.Protocol != "grpc" || len(inboundReq.Header("X-Tenant")) != 1 || inboundReq.Header("X-Tenant")[0] != "gold" {
+			t.Fatalf("Expected inbound gRPC request with metadata [x-tenant: gold], but got %+v", inboundReq)
+		}
+	})
+
 	t.Run("returns error if strategy returned error", func(t *testing.T) {
 		expectedError := errors.New("expected")
 
@@ -66,4 +100,200 @@ func TestTheGrpcServer(t *testing.T) {
 			t.Fatalf("Expecting returned error to be [%v] but was [%v]", expectedError, actualError)
 		}
 	})
+
+	t.Run("closes connections of requests still in flight after the shutdown grace period", func(t *testing.T) {
+		strategy := &blockingStrategy{received: make(chan struct{})}
+		requestHandler := service.NewRequestHandler(&service.Config{})
+		requestHandler.Strategy = strategy
+		grpcServer := grpc.NewServer()
+		theServer := &theGrpcServer{grpcServer: grpcServer, serviceHandler: requestHandler}
+		pb.RegisterTheServiceServer(grpcServer, theServer)
+
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		theServer.listener = newFaultyListener(lis)
+		go grpcServer.Serve(theServer.listener)
+
+		conn, err := grpc.Dial(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		defer conn.Close()
+
+		sent := make(chan error, 1)
+		go func() {
+			_, err := pb.NewTheServiceClient(conn).TheFunction(context.TODO(), &pb.TheRequest{RequestUID: "in-flight"})
+			sent <- err
+		}()
+		<-strategy.received
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+		defer cancel()
+		if err := theServer.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("Expected shutdown to exceed its grace period, got [%v]", err)
+		}
+
+		if err := <-sent; err == nil {
+			t.Fatalf("Expecting error, got nothing")
+		}
+	})
+
+	t.Run("fails injected failures with their status, details and trailers", func(t *testing.T) {
+		requestHandler := service.NewRequestHandler(&service.Config{
+			PercentageFailedRequests: 100,
+			FailureTypes:             []string{"grpc:code=RESOURCE_EXHAUSTED,retry-after=2s,trailer=x-quota:exceeded"},
+		})
+		requestHandler.Strategy = &stubStrategy{theResponseToReturn: &pb.TheResponse{}}
+		grpcServer := grpc.NewServer()
+		pb.RegisterTheServiceServer(grpcServer, &theGrpcServer{grpcServer: grpcServer, serviceHandler: requestHandler})
+
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		go grpcServer.Serve(lis)
+		defer grpcServer.Stop()
+
+		conn, err := grpc.Dial(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		defer conn.Close()
+
+		var trailer metadata.MD
+		_, err = pb.NewTheServiceClient(conn).TheFunction(context.TODO(), &pb.TheRequest{RequestUID: "injected"}, grpc.Trailer(&trailer))
+		if status.Code(err) != codes.ResourceExhausted {
+			t.Fatalf("Expecting error with code [%s], got [%v]", codes.ResourceExhausted, err)
+		}
+
+		if len(status.Convert(err).Details()) != 2 {
+			t.Fatalf("Expected error info and retry info details, got %v", status.Convert(err).Details())
+		}
+
+		if pushback := trailer.Get("grpc-retry-pushback-ms"); len(pushback) != 1 || pushback[0] != "2000" {
+			t.Fatalf("Expected trailer to push retries back by [2000] ms, got %v", trailer)
+		}
+		if quota := trailer.Get("x-quota"); len(quota) != 1 || quota[0] != "exceeded" {
+			t.Fatalf("Expected trailer [x-quota] to be [exceeded], got %v", trailer)
+		}
+	})
+
+	t.Run("replies to failed requests with the hop recorded for them", func(t *testing.T) {
+		requestHandler := service.NewRequestHandler(&service.Config{
+			ID:                       "expected-id",
+			PercentageFailedRequests: 100,
+			FailureTypes:             []string{"grpc:code=UNAVAILABLE,retry-after=1s"},
+			RecordHops:               true,
+		})
+		requestHandler.Strategy = &stubStrategy{theResponseToReturn: &pb.TheResponse{}}
+		grpcServer := grpc.NewServer()
+		pb.RegisterTheServiceServer(grpcServer, &theGrpcServer{grpcServer: grpcServer, serviceHandler: requestHandler})
+
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		go grpcServer.Serve(lis)
+		defer grpcServer.Stop()
+
+		conn, err := grpc.Dial(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		defer conn.Close()
+
+		client := &theGrpcClient{id: t.Name(), conn: conn, grpcClient: pb.NewTheServiceClient(conn), timeout: time.Minute}
+		_, err = client.Send(context.TODO(), &pb.TheRequest{RequestUID: "failed"})
+
+		var hopErr *service.HopError
+		if !errors.As(err, &hopErr) {
+			t.Fatalf("Expecting error carrying a hop, got [%v]", err)
+		}
+		if hopErr.Hop.ServiceID != "expected-id" || len(hopErr.Hop.InjectedFaults) != 1 || hopErr.Hop.InjectedFaults[0] != "failure" {
+			t.Fatalf("Expected hop of the server recording the injected failure, got %v", hopErr.Hop)
+		}
+
+		if status.Code(err) != codes.Unavailable || len(status.Convert(hopErr.Err).Details()) != 2 {
+			t.Fatalf("Expected error with code [%s] and the failure's details, but no hop, got %v", codes.Unavailable, status.Convert(hopErr.Err).Details())
+		}
+	})
+}
+
+func TestTheGrpcClient(t *testing.T) {
+	t.Run("propagates inbound metadata configured to be propagated", func(t *testing.T) {
+		stubClient := &stubTheServiceClient{theResponseToReturn: &pb.TheResponse{}}
+		client := theGrpcClient{
+			id:                t.Name(),
+			grpcClient:        stubClient,
+			timeout:           time.Minute,
+			headerPropagation: service.NewHeaderPropagation(&service.Config{PropagateAllHeaders: true}),
+		}
+
+		inboundReq := service.NewInboundRequest("http", "POST", "/", map[string][]string{
+			"Traceparent":    {"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"},
+			"X-Tenant":       {"gold"},
+			"Content-Length": {"42"},
+		})
+		_, err := client.Send(service.WithInboundRequest(context.TODO(), inboundReq), &pb.TheRequest{RequestUID: "123"})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		md, _ := metadata.FromOutgoingContext(stubClient.theContextReceived)
+		if tenant := md.Get("x-tenant"); len(tenant) != 1 || tenant[0] != "gold" {
+			t.Fatalf("Expected metadata [x-tenant: gold] to be propagated, but got %v", md)
+		}
+
+		if len(md.Get("traceparent")) != 1 {
+			t.Fatalf("Expected metadata [traceparent] to be propagated, but got %v", md)
+		}
+
+		if len(md.Get("content-length")) != 0 {
+			t.Fatalf("Expected metadata [content-length] not to be propagated, but got %v", md)
+		}
+	})
+
+	t.Run("injects the trace context into the metadata", func(t *testing.T) {
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+		defer otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+
+		stubClient := &stubTheServiceClient{theResponseToReturn: &pb.TheResponse{}}
+		client := theGrpcClient{
+			id:         t.Name(),
+			grpcClient: stubClient,
+			timeout:    time.Minute,
+		}
+
+		inboundTraceparent := "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+		ctx := otel.GetTextMapPropagator().Extract(context.TODO(), propagation.MapCarrier{"traceparent": inboundTraceparent})
+		_, err := client.Send(ctx, &pb.TheRequest{RequestUID: "123"})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		md, _ := metadata.FromOutgoingContext(stubClient.theContextReceived)
+		if traceparent := md.Get("traceparent"); len(traceparent) != 1 || traceparent[0] != inboundTraceparent {
+			t.Fatalf("Expected metadata [traceparent: %s], but got %v", inboundTraceparent, md)
+		}
+	})
+
+	t.Run("sends no metadata when there is nothing to propagate", func(t *testing.T) {
+		stubClient := &stubTheServiceClient{theResponseToReturn: &pb.TheResponse{}}
+		client := theGrpcClient{
+			id:         t.Name(),
+			grpcClient: stubClient,
+			timeout:    time.Minute,
+		}
+
+		_, err := client.Send(context.TODO(), &pb.TheRequest{RequestUID: "123"})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if md, ok := metadata.FromOutgoingContext(stubClient.theContextReceived); ok && md.Len() > 0 {
+			t.Fatalf("Expected no metadata to be sent, but got %v", md)
+		}
+	})
 }
@@ -0,0 +1,69 @@
+package protocols
+
+import (
+	"context"
+	"time"
+
+	pb "github.com/buoyantio/bb/gen"
+	"github.com/buoyantio/bb/service"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// grpcHealthWatchInterval is how often the health of a service is checked for changes while being watched
+var grpcHealthWatchInterval = time.Second
+
+// grpcHealthServer implements the standard gRPC health service. The server as a whole, named "", is serving while
+// the service is alive, and TheService is serving while the service is ready to receive requests.
+type grpcHealthServer struct {
+	healthpb.UnimplementedHealthServer
+	health *service.Health
+}
+
+func (s *grpcHealthServer) status(name string) (healthpb.HealthCheckResponse_ServingStatus, bool) {
+	var err error
+	switch name {
+	case "":
+		err = s.health.Live()
+	case pb.TheService_ServiceDesc.ServiceName:
+		err = s.health.Ready()
+	default:
+		return healthpb.HealthCheckResponse_SERVICE_UNKNOWN, false
+	}
+
+	if err != nil {
+		return healthpb.HealthCheckResponse_NOT_SERVING, true
+	}
+	return healthpb.HealthCheckResponse_SERVING, true
+}
+
+func (s *grpcHealthServer) Check(_ context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	servingStatus, known := s.status(req.Service)
+	if !known {
+		return nil, status.Errorf(codes.NotFound, "unknown service [%s]", req.Service)
+	}
+	return &healthpb.HealthCheckResponse{Status: servingStatus}, nil
+}
+
+func (s *grpcHealthServer) Watch(req *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	ticker := time.NewTicker(grpcHealthWatchInterval)
+	defer ticker.Stop()
+
+	lastStatus := healthpb.HealthCheckResponse_ServingStatus(-1)
+	for {
+		servingStatus, _ := s.status(req.Service)
+		if servingStatus != lastStatus {
+			if err := stream.Send(&healthpb.HealthCheckResponse{Status: servingStatus}); err != nil {
+				return err
+			}
+			lastStatus = servingStatus
+		}
+
+		select {
+		case <-stream.Context().Done():
+			return status.FromContextError(stream.Context().Err()).Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package protocols
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	pb "github.com/buoyantio/bb/gen"
+	"github.com/buoyantio/bb/service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func TestGrpcHealthServer(t *testing.T) {
+	t.Run("reports the server as serving while alive, and TheService while ready", func(t *testing.T) {
+		handler := service.NewRequestHandler(&service.Config{})
+		handler.Health().Drain()
+		healthServer := &grpcHealthServer{health: handler.Health()}
+
+		resp, err := healthServer.Check(context.TODO(), &healthpb.HealthCheckRequest{Service: ""})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if resp.Status != healthpb.HealthCheckResponse_SERVING {
+			t.Fatalf("Expected server to be serving, got [%s]", resp.Status)
+		}
+
+		resp, err = healthServer.Check(context.TODO(), &healthpb.HealthCheckRequest{Service: pb.TheService_ServiceDesc.ServiceName})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if resp.Status != healthpb.HealthCheckResponse_NOT_SERVING {
+			t.Fatalf("Expected draining service not to be serving, got [%s]", resp.Status)
+		}
+	})
+
+	t.Run("returns not found for unknown services", func(t *testing.T) {
+		healthServer := &grpcHealthServer{health: service.NewRequestHandler(&service.Config{}).Health()}
+
+		_, err := healthServer.Check(context.TODO(), &healthpb.HealthCheckRequest{Service: "unknown"})
+		if status.Code(err) != codes.NotFound {
+			t.Fatalf("Expecting error with code [%s], got [%v]", codes.NotFound, err)
+		}
+	})
+
+	t.Run("sends every change to watchers", func(t *testing.T) {
+		defer func(interval time.Duration) { grpcHealthWatchInterval = interval }(grpcHealthWatchInterval)
+		grpcHealthWatchInterval = time.Millisecond * 10
+
+		handler := service.NewRequestHandler(&service.Config{})
+		grpcServer := grpc.NewServer()
+		healthpb.RegisterHealthServer(grpcServer, &grpcHealthServer{health: handler.Health()})
+
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		go grpcServer.Serve(lis)
+		defer grpcServer.Stop()
+
+		conn, err := grpc.Dial(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		defer conn.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel()
+		stream, err := healthpb.NewHealthClient(conn).Watch(ctx, &healthpb.HealthCheckRequest{Service: pb.TheService_ServiceDesc.ServiceName})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		for _, expectedStatus := range []healthpb.HealthCheckResponse_ServingStatus{healthpb.HealthCheckResponse_SERVING, healthpb.HealthCheckResponse_NOT_SERVING} {
+			resp, err := stream.Recv()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if resp.Status != expectedStatus {
+				t.Fatalf("Expected status [%s], got [%s]", expectedStatus, resp.Status)
+			}
+			handler.Health().Drain()
+		}
+	})
+}
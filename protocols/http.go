@@ -10,11 +10,16 @@ import (
 	"strings"
 	"time"
 
+	"github.com/buoyantio/bb/admin"
 	pb "github.com/buoyantio/bb/gen"
+	"github.com/buoyantio/bb/metrics"
 	"github.com/buoyantio/bb/service"
 	"github.com/gogo/protobuf/jsonpb"
 	"github.com/golang/protobuf/proto"
 	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var marshaller = &jsonpb.Marshaler{}
@@ -25,46 +30,78 @@ type theHTTPServer struct {
 }
 
 type httpHandler struct {
+	serverID       string
 	serviceHandler *service.RequestHandler
+	healthChecks   map[string]http.Handler
 }
 
 func (s *theHTTPServer) GetID() string {
 	return fmt.Sprintf("h1-%d", s.port)
 }
 
-func (s *theHTTPServer) Shutdown() error {
+func (s *theHTTPServer) Shutdown(ctx context.Context) error {
 	log.Infof("Shutting down [%s]", s.GetID())
-	return s.httpServer.Shutdown(context.Background())
+	err := s.httpServer.Shutdown(ctx)
+	if err != nil {
+		log.Warnf("Requests to [%s] still in flight after the shutdown grace period, closing their connections", s.GetID())
+		s.httpServer.Close()
+	}
+	return err
 }
 
 func (h *httpHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	var protoReq pb.TheRequest
+	if healthCheck, ok := h.healthChecks[req.URL.Path]; ok && req.Method == http.MethodGet {
+		healthCheck.ServeHTTP(w, req)
+		return
+	}
+
+	var protoReq *pb.TheRequest
+	var err error
+
+	recordOutcome := metrics.Servers.Start(h.serverID)
+	defer func() { recordOutcome(err) }()
 
 	if req.ContentLength > 0 {
-		r, err := unmarshalProtoRequest(req)
+		protoReq, err = unmarshalProtoRequest(req)
 		if err != nil {
 			dealWithErrorDuringHandling(w, fmt.Errorf("error unmarshalling the request: %v", err))
 			return
 		}
-		protoReq = r
 	} else {
 		newRequestUID := newRequestUID("http", h.serviceHandler.ConfigID())
 		log.Infof("Received request with empty body, assigning new request UID [%s] to it", newRequestUID)
-		protoReq = pb.TheRequest{
+		protoReq = &pb.TheRequest{
 			RequestUID: newRequestUID,
 		}
 	}
 
 	log.Debugf("Received HTTP request [%s] [%+v] Context [%+v] Body [%+v]", protoReq.RequestUID, req, req.Context(), protoReq)
 
-	protoResponse, err := h.serviceHandler.Handle(req.Context(), &protoReq)
+	inboundReq := service.NewInboundRequest("http", req.Method, req.URL.Path, req.Header)
+	protoResponse, err := h.serviceHandler.Handle(service.WithInboundRequest(req.Context(), inboundReq), protoReq)
+	var hopErr *service.HopError
+	if errors.As(err, &hopErr) {
+		dealWithErrorWithHop(w, hopErr)
+		return
+	}
 	if err != nil {
-		dealWithErrorDuringHandling(w, fmt.Errorf("error handling http request: %v", err))
+		dealWithErrorDuringHandling(w, fmt.Errorf("error handling http request: %w", err))
 		return
 	}
 
 	log.Infof("Received HTTP request [%s] [%s %s] Body [%+v] Returning response [%+v]", protoReq.RequestUID, req.Method, req.URL, protoReq, protoResponse)
 
+	if fault := h.serviceHandler.PickTransportFault(); fault != nil {
+		body, err := marshallProtobufToJSON(protoResponse)
+		if err == nil {
+			err = writeWithTransportFault(w, req, fault, body)
+		}
+		if err != nil {
+			dealWithErrorDuringHandling(w, fmt.Errorf("error injecting transport fault: %v", err))
+		}
+		return
+	}
+
 	if err = marshalProtoResponse(w, protoResponse); err != nil {
 		dealWithErrorDuringHandling(w, fmt.Errorf("error marshalling the response: %v", err))
 		return
@@ -73,8 +110,11 @@ func (h *httpHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 
 type httpClient struct {
 	id                        string
+	serviceID                 string
 	serverURL                 string
 	clientForDownsteamServers *http.Client
+	headerPropagation         *service.HeaderPropagation
+	tracer                    trace.Tracer
 }
 
 func (c *httpClient) Close() error { return nil }
@@ -82,6 +122,15 @@ func (c *httpClient) Close() error { return nil }
 func (c *httpClient) GetID() string { return c.id }
 
 func (c *httpClient) Send(ctx context.Context, req *pb.TheRequest) (*pb.TheResponse, error) {
+	recordOutcome := metrics.Clients.Start(c.serviceID, c.id)
+	ctx, span := service.StartClientSpan(ctx, c.tracer, c.id, req)
+	resp, err := c.send(ctx, req)
+	service.EndSpan(span, err)
+	recordOutcome(err)
+	return resp, err
+}
+
+func (c *httpClient) send(ctx context.Context, req *pb.TheRequest) (*pb.TheResponse, error) {
 	json, err := marshallProtobufToJSON(req)
 	if err != nil {
 		return nil, err
@@ -91,14 +140,36 @@ func (c *httpClient) Send(ctx context.Context, req *pb.TheRequest) (*pb.TheRespo
 	if err != nil {
 		return nil, err
 	}
+	for name, values := range c.headerPropagation.HeadersFromContext(ctx, "http") {
+		for _, value := range values {
+			httpReq.Header.Add(name, value)
+		}
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(httpReq.Header))
 	httpReq.Header.Set("Content-Type", "application/json")
 	resp, err := c.clientForDownsteamServers.Do(httpReq.WithContext(ctx))
 	if err != nil {
 		return nil, err
 	}
 
-	var protoResp pb.TheResponse
 	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		statusErr := &service.HTTPStatusError{StatusCode: resp.StatusCode, Body: string(body)}
+
+		// services recording hops reply with their hop, and the error message as payload
+		var withHop pb.TheResponse
+		if resp.Header.Get("Content-Type") == "application/json" && jsonpb.UnmarshalString(string(body), &withHop) == nil && len(withHop.Hops) == 1 {
+			statusErr.Body = withHop.Payload
+			return nil, &service.HopError{Err: statusErr, Hop: withHop.Hops[0]}
+		}
+		return nil, statusErr
+	}
+
+	var protoResp pb.TheResponse
 	err = unmarshalJSONToProtobuf(resp.Body, &protoResp)
 
 	return &protoResp, err
@@ -129,10 +200,10 @@ func marshalProtoResponse(httpResp http.ResponseWriter, protoResp proto.Message)
 	return nil
 }
 
-func unmarshalProtoRequest(httpReq *http.Request) (pb.TheRequest, error) {
+func unmarshalProtoRequest(httpReq *http.Request) (*pb.TheRequest, error) {
 	var protoReq pb.TheRequest
 	err := unmarshalJSONToProtobuf(httpReq.Body, &protoReq)
-	return protoReq, err
+	return &protoReq, err
 }
 
 func unmarshalJSONToProtobuf(r io.Reader, out proto.Message) error {
@@ -152,12 +223,44 @@ func unmarshalJSONToProtobuf(r io.Reader, out proto.Message) error {
 
 func dealWithErrorDuringHandling(w http.ResponseWriter, err error) {
 	log.Errorf("Error while handling HTTP request: %v", err)
-	http.Error(w, err.Error(), http.StatusInternalServerError)
+	http.Error(w, err.Error(), errorStatus(w, err))
+}
+
+// dealWithErrorWithHop replies like dealWithErrorDuringHandling, but with a JSON body carrying the error message as
+// payload along with the hop recorded for the request
+func dealWithErrorWithHop(w http.ResponseWriter, hopErr *service.HopError) {
+	body, err := marshallProtobufToJSON(&pb.TheResponse{Payload: hopErr.Error(), Hops: []*pb.Hop{hopErr.Hop}})
+	if err != nil {
+		dealWithErrorDuringHandling(w, fmt.Errorf("error handling http request: %w", hopErr))
+		return
+	}
+
+	log.Errorf("Error while handling HTTP request: %v", hopErr)
+	statusCode := errorStatus(w, hopErr)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	fmt.Fprint(w, body)
+}
+
+// errorStatus returns the HTTP status to reply to a failed request with, setting the headers of injected failures
+func errorStatus(w http.ResponseWriter, err error) int {
+	var failure *service.InjectedFailure
+	if errors.As(err, &failure) && failure.HTTPStatus != 0 {
+		for name, value := range failure.Headers() {
+			w.Header().Set(name, value)
+		}
+		return failure.HTTPStatus
+	}
+	return http.StatusInternalServerError
 }
 
 func newHTTPHandler(serviceHandler *service.RequestHandler) *httpHandler {
 	return &httpHandler{
 		serviceHandler: serviceHandler,
+		healthChecks: map[string]http.Handler{
+			service.HealthzPath: admin.NewLivenessEndpoint(serviceHandler.Health()),
+			service.ReadyzPath:  admin.NewReadinessEndpoint(serviceHandler.Health()),
+		},
 	}
 }
 
@@ -167,9 +270,11 @@ func NewHTTPServerIfConfigured(config *service.Config, serviceHandler *service.R
 		return nil, nil
 	}
 
+	handler := newHTTPHandler(serviceHandler)
+	handler.serverID = fmt.Sprintf("h1-%d", config.H1ServerPort)
 	srv := &http.Server{
-		Addr:    fmt.Sprintf(":%d", config.H1ServerPort),
-		Handler: newHTTPHandler(serviceHandler),
+		Addr:    config.BindAddress(config.H1ServerPort),
+		Handler: handler,
 	}
 	go func() {
 		log.Infof("HTTP 1.1 server listening on port [%d]", config.H1ServerPort)
@@ -191,11 +296,15 @@ func NewHTTPClientsIfConfigured(config *service.Config) ([]service.Client, error
 		Timeout: config.DownstreamTimeout,
 	}
 
+	headerPropagation := service.NewHeaderPropagation(config)
 	for _, serverURL := range config.H1DownstreamServers {
 		clients = append(clients, &httpClient{
 			id:                        serverURL,
+			serviceID:                 config.ID,
 			serverURL:                 serverURL,
 			clientForDownsteamServers: httpClientToUse,
+			headerPropagation:         headerPropagation,
+			tracer:                    config.Tracer(),
 		})
 	}
 
@@ -9,10 +9,12 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	pb "github.com/buoyantio/bb/gen"
 	"github.com/buoyantio/bb/service"
 	"github.com/gogo/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
 )
 
 func TestTheHTTPServer(t *testing.T) {
@@ -50,7 +52,7 @@ func TestTheHTTPServer(t *testing.T) {
 		jsonpb.UnmarshalString(string(bytesResp), &actualProtoResponse)
 
 		if expectedProtoResponse.Payload != actualProtoResponse.Payload {
-			t.Fatalf("Expected HTTP response to contain protobuf [%v] but it was [%v]", expectedProtoResponse, actualProtoResponse)
+			t.Fatalf("Expected HTTP response to contain protobuf [%v] but it was [%v]", expectedProtoResponse, &actualProtoResponse)
 		}
 
 		if actualProtoResponse.RequestUID == "" {
@@ -107,7 +109,44 @@ func TestTheHTTPServer(t *testing.T) {
 		jsonpb.UnmarshalString(string(bytesResp), &actualProtoResponse)
 
 		if expectedProtoResponse.Payload != actualProtoResponse.Payload {
-			t.Fatalf("Expected HTTP response to contain protobuf [%v] but it was [%v]", expectedProtoResponse, actualProtoResponse)
+			t.Fatalf("Expected HTTP response to contain protobuf [%v] but it was [%v]", expectedProtoResponse, &actualProtoResponse)
+		}
+	})
+
+	t.Run("passes the request path, method and headers on to the strategy", func(t *testing.T) {
+		strategy := &stubStrategy{
+			theResponseToReturn: &pb.TheResponse{},
+		}
+
+		requestHandler := service.NewRequestHandler(&service.Config{})
+		requestHandler.Strategy = strategy
+		handler := newHTTPHandler(requestHandler)
+		theServer := httptest.NewServer(handler)
+		defer theServer.Close()
+
+		req, err := http.NewRequest(http.MethodPost, theServer.URL+"/checkout", strings.NewReader(""))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		req.Header.Set("X-Tenant", "gold")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		defer resp.Body.Close()
+
+		inboundReq, ok := service.InboundRequestFromContext(strategy.theContextReceived)
+		if !ok {
+			t.Fatalf("Expected strategy to receive the inbound request, but it was missing")
+		}
+
+		if inboundReq.Protocol != "http" || inboundReq.Method != http.MethodPost || inboundReq.Path != "/checkout" {
+			t.Fatalf("Expected inbound HTTP request [POST /checkout], but got %+v", inboundReq)
+		}
+
+		if tenant := inboundReq.Header("x-tenant"); len(tenant) != 1 || tenant[0] != "gold" {
+			t.Fatalf("Expected inbound request to have header [x-tenant: gold], but got %+v", inboundReq.Headers)
 		}
 	})
 
@@ -187,6 +226,83 @@ func TestTheHTTPServer(t *testing.T) {
 			t.Fatalf("Expecting response body to contain the error message [%s], but got [%s]", expectedInBody, stringResp)
 		}
 	})
+
+	t.Run("serves health checks instead of passing them on to the strategy", func(t *testing.T) {
+		strategy := &stubStrategy{theResponseToReturn: &pb.TheResponse{}}
+		requestHandler := service.NewRequestHandler(&service.Config{})
+		requestHandler.Strategy = strategy
+		requestHandler.Health().Drain()
+		theServer := httptest.NewServer(newHTTPHandler(requestHandler))
+		defer theServer.Close()
+
+		expectedStatuses := map[string]int{
+			service.HealthzPath: http.StatusOK,
+			service.ReadyzPath:  http.StatusServiceUnavailable,
+		}
+		for path, expectedStatus := range expectedStatuses {
+			resp, err := http.Get(theServer.URL + path)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != expectedStatus {
+				t.Fatalf("Expecting [%s] to have status [%d] but was: %v", path, expectedStatus, resp)
+			}
+		}
+
+		if strategy.theRequestReceived != nil {
+			t.Fatalf("Expected health checks not to reach the strategy, but it received [%v]", strategy.theRequestReceived)
+		}
+	})
+
+	t.Run("closes connections of requests still in flight after the shutdown grace period", func(t *testing.T) {
+		strategy := &blockingStrategy{received: make(chan struct{})}
+		requestHandler := service.NewRequestHandler(&service.Config{})
+		requestHandler.Strategy = strategy
+		httpServer := httptest.NewServer(newHTTPHandler(requestHandler))
+		theServer := &theHTTPServer{httpServer: httpServer.Config}
+
+		sent := make(chan error, 1)
+		go func() {
+			resp, err := http.Post(httpServer.URL, "application/json", strings.NewReader(`{"requestUID": "in-flight"}`))
+			if err == nil {
+				resp.Body.Close()
+			}
+			sent <- err
+		}()
+		<-strategy.received
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+		defer cancel()
+		if err := theServer.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("Expected shutdown to exceed its grace period, got [%v]", err)
+		}
+
+		if err := <-sent; err == nil {
+			t.Fatalf("Expecting error, got nothing")
+		}
+	})
+
+	t.Run("fails injected failures with their status and headers", func(t *testing.T) {
+		requestHandler := service.NewRequestHandler(&service.Config{
+			PercentageFailedRequests: 100,
+			FailureTypes:             []string{"http:status=503,retry-after=2s,header=X-Quota:exceeded"},
+		})
+		requestHandler.Strategy = &stubStrategy{theResponseToReturn: &pb.TheResponse{}}
+		theServer := httptest.NewServer(newHTTPHandler(requestHandler))
+		defer theServer.Close()
+
+		resp, err := http.Post(theServer.URL, "application/json", strings.NewReader(`{"requestUID": "injected"}`))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusServiceUnavailable || resp.Header.Get("Retry-After") != "2" || resp.Header.Get("X-Quota") != "exceeded" {
+			t.Fatalf("Expecting a [503] retrying after [2] seconds with header [X-Quota], got: %v", resp)
+		}
+	})
 }
 
 func TestHTTPClient(t *testing.T) {
@@ -230,6 +346,108 @@ func TestHTTPClient(t *testing.T) {
 		}
 	})
 
+	t.Run("returns the hops recorded by the server", func(t *testing.T) {
+		expectedHop := &pb.Hop{
+			ServiceID:      "expected-id",
+			Strategy:       "broadcast-channel",
+			DownstreamHops: []*pb.Hop{{ClientID: "a:9090", Error: "expected"}, {ClientID: "b:9091", LatencyInMillis: 1.5}},
+		}
+		strategy := &stubStrategy{
+			theResponseToReturn: &pb.TheResponse{Hops: []*pb.Hop{expectedHop}},
+		}
+
+		requestHandler := service.NewRequestHandler(&service.Config{})
+		requestHandler.Strategy = strategy
+		theServer := httptest.NewServer(newHTTPHandler(requestHandler))
+		defer theServer.Close()
+
+		client := httpClient{
+			id:                        t.Name(),
+			serverURL:                 theServer.URL,
+			clientForDownsteamServers: http.DefaultClient,
+		}
+
+		actualProtoResponse, err := client.Send(context.Background(), &pb.TheRequest{RequestUID: "123"})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if len(actualProtoResponse.Hops) != 1 || !proto.Equal(expectedHop, actualProtoResponse.Hops[0]) {
+			t.Fatalf("Expected HTTP response to contain hop [%v] but it was %v", expectedHop, actualProtoResponse.Hops)
+		}
+	})
+
+	t.Run("returns the hop recorded by the server for failed requests", func(t *testing.T) {
+		requestHandler := service.NewRequestHandler(&service.Config{
+			ID:                       "expected-id",
+			PercentageFailedRequests: 100,
+			FailureTypes:             []string{"http:status=503,message=overloaded"},
+			RecordHops:               true,
+		})
+		requestHandler.Strategy = &stubStrategy{theResponseToReturn: &pb.TheResponse{}}
+		theServer := httptest.NewServer(newHTTPHandler(requestHandler))
+		defer theServer.Close()
+
+		client := httpClient{
+			id:                        t.Name(),
+			serverURL:                 theServer.URL,
+			clientForDownsteamServers: http.DefaultClient,
+		}
+
+		_, err := client.Send(context.Background(), &pb.TheRequest{RequestUID: "123"})
+		var hopErr *service.HopError
+		if !errors.As(err, &hopErr) {
+			t.Fatalf("Expecting error carrying a hop, got [%v]", err)
+		}
+		if hopErr.Hop.ServiceID != "expected-id" || len(hopErr.Hop.InjectedFaults) != 1 || hopErr.Hop.InjectedFaults[0] != "failure" {
+			t.Fatalf("Expected hop of the server recording the injected failure, got %v", hopErr.Hop)
+		}
+
+		var statusErr *service.HTTPStatusError
+		if !errors.As(err, &statusErr) || statusErr.StatusCode != 503 || statusErr.Body != "overloaded" {
+			t.Fatalf("Expected HTTP status [503] with message [overloaded], got %#v", statusErr)
+		}
+	})
+
+	t.Run("propagates inbound headers configured to be propagated", func(t *testing.T) {
+		receivedHeaders := make(chan http.Header, 1)
+		theServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			receivedHeaders <- r.Header
+			fmt.Fprint(w, "{}")
+		}))
+		defer theServer.Close()
+
+		client := httpClient{
+			id:                        t.Name(),
+			serverURL:                 theServer.URL,
+			clientForDownsteamServers: http.DefaultClient,
+			headerPropagation:         service.NewHeaderPropagation(&service.Config{PropagateHeaders: []string{"traceparent", "content-type"}}),
+		}
+
+		inboundReq := service.NewInboundRequest("grpc", "/m", "/m", map[string][]string{
+			"traceparent":  {"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"},
+			"x-tenant":     {"gold"},
+			"content-type": {"application/grpc"},
+		})
+		_, err := client.Send(service.WithInboundRequest(context.Background(), inboundReq), &pb.TheRequest{RequestUID: "123"})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		headers := <-receivedHeaders
+		if headers.Get("Traceparent") != "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01" {
+			t.Fatalf("Expected header [traceparent] to be propagated, but got %v", headers)
+		}
+
+		if headers.Get("X-Tenant") != "" {
+			t.Fatalf("Expected header [x-tenant] not to be propagated, but got %v", headers)
+		}
+
+		if headers.Get("Content-Type") != "application/json" {
+			t.Fatalf("Expected request content type to be [application/json], but got %v", headers)
+		}
+	})
+
 	t.Run("returns error when server returned any 5xx error", func(t *testing.T) {
 		expectedProtoRequest := &pb.TheRequest{
 			RequestUID: "123",
@@ -256,6 +474,11 @@ func TestHTTPClient(t *testing.T) {
 			t.Fatalf("Expecting error, got nothing")
 		}
 
+		statusErr, isStatusErr := err.(*service.HTTPStatusError)
+		if !isStatusErr || statusErr.StatusCode != http.StatusInternalServerError {
+			t.Fatalf("Expecting error to carry HTTP status [%d], but got [%v]", http.StatusInternalServerError, err)
+		}
+
 		actualProtoRequest := strategy.theRequestReceived
 		if expectedProtoRequest.RequestUID != actualProtoRequest.RequestUID {
 			t.Fatalf("Expected HTTP request to contain protobuf [%v] but it was [%v]", expectedProtoRequest, actualProtoRequest)
@@ -4,15 +4,42 @@ import (
 	"context"
 
 	pb "github.com/buoyantio/bb/gen"
+	"google.golang.org/grpc"
 )
 
 type stubStrategy struct {
 	theRequestReceived  *pb.TheRequest
+	theContextReceived  context.Context
 	theResponseToReturn *pb.TheResponse
 	theErrorToReturn    error
 }
 
 func (h *stubStrategy) Do(ctx context.Context, req *pb.TheRequest) (*pb.TheResponse, error) {
 	h.theRequestReceived = req
+	h.theContextReceived = ctx
 	return h.theResponseToReturn, h.theErrorToReturn
 }
+
+type stubTheServiceClient struct {
+	theContextReceived  context.Context
+	theRequestReceived  *pb.TheRequest
+	theResponseToReturn *pb.TheResponse
+	theErrorToReturn    error
+}
+
+func (c *stubTheServiceClient) TheFunction(ctx context.Context, req *pb.TheRequest, opts ...grpc.CallOption) (*pb.TheResponse, error) {
+	c.theContextReceived = ctx
+	c.theRequestReceived = req
+	return c.theResponseToReturn, c.theErrorToReturn
+}
+
+// blockingStrategy doesn't return until the request is cancelled, signalling once it received a request
+type blockingStrategy struct {
+	received chan struct{}
+}
+
+func (h *blockingStrategy) Do(ctx context.Context, req *pb.TheRequest) (*pb.TheResponse, error) {
+	close(h.received)
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
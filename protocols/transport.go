@@ -0,0 +1,320 @@
+package protocols
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/buoyantio/bb/service"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/peer"
+)
+
+const (
+	// http2FrameHeaderLength is the length of the header every HTTP/2 frame starts with, c.f. RFC 9113 section 4.1
+	http2FrameHeaderLength = 9
+
+	// frame types and flags needed to tell when a stream ends, c.f. RFC 9113 section 6
+	http2HeadersFrame   = 0x1
+	http2RstStreamFrame = 0x3
+	http2EndStreamFlag  = 0x1
+)
+
+// http2GoAwayFrame is a GOAWAY frame with the highest possible last stream ID and no error, which asks the client to
+// stop sending requests over the connection while letting those in flight complete, c.f. RFC 9113 section 6.8
+var http2GoAwayFrame = []byte{0, 0, 8, 0x7, 0, 0, 0, 0, 0, 0x7f, 0xff, 0xff, 0xff, 0, 0, 0, 0}
+
+// faultyListener keeps track of the connections it accepts, so that transport faults can be injected into the
+// connection a gRPC request was received over
+type faultyListener struct {
+	net.Listener
+	mu    sync.Mutex
+	conns map[string]*faultyConn
+}
+
+func newFaultyListener(lis net.Listener) *faultyListener {
+	return &faultyListener{Listener: lis, conns: map[string]*faultyConn{}}
+}
+
+func (l *faultyListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	faulty := &faultyConn{Conn: conn, listener: l}
+	l.mu.Lock()
+	l.conns[conn.RemoteAddr().String()] = faulty
+	l.mu.Unlock()
+	return faulty, nil
+}
+
+// connFor returns the connection the gRPC request carried by ctx was received over
+func (l *faultyListener) connFor(ctx context.Context) (*faultyConn, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil, false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	conn, ok := l.conns[p.Addr.String()]
+	return conn, ok
+}
+
+// faultyConn is a HTTP/2 connection that can slow down or break the response to a single request, or send a GOAWAY
+// frame along with what the server writes to it. The server's frames are tracked as they are written, so that faults
+// only apply to the frames of the stream the request was received over, and GOAWAY frames are only sent in between.
+type faultyConn struct {
+	net.Conn
+	listener *faultyListener
+
+	// mu guards the faults, which are injected while the server writes to the connection
+	mu            sync.Mutex
+	faults        map[uint32]*streamFault
+	goAwayPending bool
+	closeOnce     sync.Once
+
+	// writeMu guards the frame being written, as the server may write from more than one goroutine. It is held while a
+	// frame is trickled, as its bytes can't be interleaved with those of other frames, so trickling the frames of one
+	// stream holds up the frames of every other stream written after them on the connection.
+	writeMu     sync.Mutex
+	frameHeader []byte
+	frame       http2Frame
+}
+
+// http2Frame is the frame being written, of which remaining bytes are still to be written
+type http2Frame struct {
+	streamID  uint32
+	remaining int
+	endStream bool
+}
+
+// streamFault is a transport fault injected into the frames of a single stream, along with how many more bytes of
+// them can be written before the connection is reset by a close-after fault
+type streamFault struct {
+	fault     *service.TransportFault
+	remaining int
+}
+
+// injectFault makes the frames written from now on for streamID subject to fault. Resets and GOAWAY frames apply to
+// the whole connection, as HTTP/2 multiplexes streams over it, and so does the delay of trickled frames, as frames are
+// written one after the other.
+func (c *faultyConn) injectFault(streamID uint32, fault *service.TransportFault) {
+	if fault.Kind == service.ResetFault {
+		resetConnection(c.Conn)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch fault.Kind {
+	case service.GoAwayFault:
+		c.goAwayPending = true
+	case service.TrickleFault, service.CloseAfterFault:
+		if c.faults == nil {
+			c.faults = map[uint32]*streamFault{}
+		}
+		c.faults[streamID] = &streamFault{fault: fault, remaining: fault.Bytes}
+	}
+}
+
+// Write writes b frame by frame, holding on to frame headers until they are complete, so that each frame is written
+// as the fault of its stream requires
+func (c *faultyConn) Write(b []byte) (int, error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	written := 0
+	for written < len(b) {
+		if c.frame.remaining == 0 {
+			n := http2FrameHeaderLength - len(c.frameHeader)
+			if n > len(b)-written {
+				n = len(b) - written
+			}
+			c.frameHeader = append(c.frameHeader, b[written:written+n]...)
+			written += n
+			if len(c.frameHeader) < http2FrameHeaderLength {
+				break
+			}
+
+			if err := c.startFrame(); err != nil {
+				return written, err
+			}
+			continue
+		}
+
+		n := c.frame.remaining
+		if n > len(b)-written {
+			n = len(b) - written
+		}
+		if err := c.writeFrameBytes(b[written : written+n]); err != nil {
+			return written, err
+		}
+		written += n
+		c.frame.remaining -= n
+		if c.frame.remaining == 0 {
+			c.endFrame()
+		}
+	}
+	return written, nil
+}
+
+// startFrame writes the complete frame header held on to, preceded by a GOAWAY frame if one is pending
+func (c *faultyConn) startFrame() error {
+	header := c.frameHeader
+	c.frameHeader = c.frameHeader[:0]
+	c.frame = http2Frame{
+		streamID:  binary.BigEndian.Uint32(header[5:9]) & 0x7fffffff,
+		remaining: int(header[0])<<16 | int(header[1])<<8 | int(header[2]),
+		// END_STREAM on DATA or HEADERS frames, or a RST_STREAM frame, ends the stream
+		endStream: (header[3] <= http2HeadersFrame && header[4]&http2EndStreamFlag != 0) || header[3] == http2RstStreamFrame,
+	}
+
+	c.mu.Lock()
+	goAwayPending := c.goAwayPending
+	c.goAwayPending = false
+	c.mu.Unlock()
+	if goAwayPending {
+		if _, err := c.Conn.Write(http2GoAwayFrame); err != nil {
+			return err
+		}
+	}
+
+	if err := c.writeFrameBytes(header); err != nil {
+		return err
+	}
+	if c.frame.remaining == 0 {
+		c.endFrame()
+	}
+	return nil
+}
+
+// writeFrameBytes writes bytes of the current frame, as the fault injected into its stream requires
+func (c *faultyConn) writeFrameBytes(b []byte) error {
+	c.mu.Lock()
+	streamFault := c.faults[c.frame.streamID]
+	c.mu.Unlock()
+
+	if streamFault == nil || c.frame.streamID == 0 {
+		_, err := c.Conn.Write(b)
+		return err
+	}
+
+	switch streamFault.fault.Kind {
+	case service.TrickleFault:
+		_, err := trickle(b, streamFault.fault.Interval, c.Conn.Write)
+		return err
+	case service.CloseAfterFault:
+		if len(b) > streamFault.remaining {
+			c.Conn.Write(b[:streamFault.remaining])
+			resetConnection(c.Conn)
+			return net.ErrClosed
+		}
+		streamFault.remaining -= len(b)
+	}
+	_, err := c.Conn.Write(b)
+	return err
+}
+
+// endFrame stops injecting the fault of the stream of the frame just written, if it was the last one of the stream
+func (c *faultyConn) endFrame() {
+	if !c.frame.endStream {
+		return
+	}
+
+	c.mu.Lock()
+	delete(c.faults, c.frame.streamID)
+	c.mu.Unlock()
+}
+
+func (c *faultyConn) Close() error {
+	c.closeOnce.Do(func() {
+		c.listener.mu.Lock()
+		delete(c.listener.conns, c.RemoteAddr().String())
+		c.listener.mu.Unlock()
+	})
+	return c.Conn.Close()
+}
+
+// resetConnection closes conn abruptly, making TCP connections send a RST instead of a FIN
+func resetConnection(conn net.Conn) {
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		tcpConn.SetLinger(0)
+	}
+	conn.Close()
+}
+
+// trickle writes b one byte at a time, waiting interval after each
+func trickle(b []byte, interval time.Duration, write func(p []byte) (int, error)) (int, error) {
+	for i := range b {
+		if _, err := write(b[i : i+1]); err != nil {
+			return i, err
+		}
+		time.Sleep(interval)
+	}
+	return len(b), nil
+}
+
+// writeWithTransportFault writes the HTTP response body, injecting fault into its connection
+func writeWithTransportFault(w http.ResponseWriter, req *http.Request, fault *service.TransportFault, body string) error {
+	switch fault.Kind {
+	case service.ResetFault, service.CloseAfterFault:
+		hijacker, ok := w.(http.Hijacker)
+		if !ok {
+			return fmt.Errorf("can't inject [%s] as the connection can't be taken over", fault)
+		}
+		conn, _, err := hijacker.Hijack()
+		if err != nil {
+			return err
+		}
+
+		if fault.Kind == service.CloseAfterFault {
+			response := fmt.Sprintf("HTTP/1.1 200 OK\r\nContent-Length: %d\r\nContent-Type: application/json\r\n\r\n%s", len(body), body)
+			if fault.Bytes < len(response) {
+				response = response[:fault.Bytes]
+			}
+			conn.Write([]byte(response))
+		}
+		resetConnection(conn)
+		return nil
+	case service.HangFault:
+		<-req.Context().Done()
+		return nil
+	case service.GoAwayFault:
+		w.Header().Set("Connection", "close")
+		_, err := fmt.Fprint(w, body)
+		return err
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return fmt.Errorf("can't inject [%s] as the response can't be flushed", fault)
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	if fault.Kind == service.StallBodyFault {
+		<-req.Context().Done()
+		return nil
+	}
+
+	_, err := trickle([]byte(body), fault.Interval, func(p []byte) (int, error) {
+		if err := req.Context().Err(); err != nil {
+			return 0, err
+		}
+		n, err := w.Write(p)
+		flusher.Flush()
+		return n, err
+	})
+	if err != nil {
+		log.Debugf("Stopped trickling the response: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,252 @@
+package protocols
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	pb "github.com/buoyantio/bb/gen"
+	"github.com/buoyantio/bb/service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// recordingConn records what is written to it, and how many writes it took
+type recordingConn struct {
+	net.Conn
+	written bytes.Buffer
+	writes  int
+	closed  bool
+}
+
+func (c *recordingConn) Write(b []byte) (int, error) {
+	c.writes++
+	return c.written.Write(b)
+}
+
+func (c *recordingConn) Close() error {
+	c.closed = true
+	return nil
+}
+
+// http2Frame returns a frame of type for streamID, with flags and payload
+func newHTTP2Frame(frameType byte, flags byte, streamID byte, payload string) []byte {
+	return append([]byte{0, 0, byte(len(payload)), frameType, flags, 0, 0, 0, streamID}, payload...)
+}
+
+func startFaultyGrpcServer(t *testing.T, transportFaults ...string) (*grpc.ClientConn, *service.RequestHandler) {
+	requestHandler := service.NewRequestHandler(&service.Config{TransportFaults: transportFaults})
+	requestHandler.Strategy = &stubStrategy{theResponseToReturn: &pb.TheResponse{Payload: "BANANA"}}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	listener := newFaultyListener(lis)
+	grpcServer := grpc.NewServer()
+	pb.RegisterTheServiceServer(grpcServer, &theGrpcServer{grpcServer: grpcServer, serviceHandler: requestHandler, listener: listener})
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn, requestHandler
+}
+
+// streamWithoutID is a gRPC server stream that, unlike gRPC's own, has no id field
+type streamWithoutID struct {
+	grpc.ServerTransportStream
+}
+
+func TestFaultyConn(t *testing.T) {
+	t.Run("sends GOAWAY frames in between the frames written", func(t *testing.T) {
+		recorded := &recordingConn{}
+		conn := &faultyConn{Conn: recorded}
+		settings := newHTTP2Frame(0x4, 0, 0, "")
+		data := newHTTP2Frame(0x0, 0, 1, "abc")
+
+		conn.Write(data[:5])
+		conn.injectFault(1, &service.TransportFault{Kind: service.GoAwayFault})
+		conn.Write(append(data[5:], settings...))
+
+		// the header of the data frame is held on to until complete, so the GOAWAY frame is sent before it
+		expected := append(append(append([]byte{}, http2GoAwayFrame...), data...), settings...)
+		if !bytes.Equal(recorded.written.Bytes(), expected) {
+			t.Fatalf("Expected [%v] to be written, got [%v]", expected, recorded.written.Bytes())
+		}
+	})
+
+	t.Run("trickles the frames of the faulted stream only, until it ends", func(t *testing.T) {
+		recorded := &recordingConn{}
+		conn := &faultyConn{Conn: recorded}
+		conn.injectFault(1, &service.TransportFault{Kind: service.TrickleFault})
+
+		faulted := newHTTP2Frame(0x0, http2EndStreamFlag, 1, "abc")
+		conn.Write(newHTTP2Frame(0x0, 0, 3, "other"))
+		if recorded.writes != 2 {
+			t.Fatalf("Expected frames of other streams to be written as is, got [%d] writes", recorded.writes)
+		}
+
+		conn.Write(faulted)
+		if recorded.writes != 2+len(faulted) {
+			t.Fatalf("Expected the frame of the faulted stream to be written byte by byte, got [%d] writes", recorded.writes-2)
+		}
+
+		if len(conn.faults) != 0 {
+			t.Fatalf("Expected the fault to be removed once its stream ended, got %v", conn.faults)
+		}
+	})
+
+	t.Run("resets the connection after the bytes of the faulted stream set to close after", func(t *testing.T) {
+		recorded := &recordingConn{}
+		conn := &faultyConn{Conn: recorded}
+		conn.injectFault(1, &service.TransportFault{Kind: service.CloseAfterFault, Bytes: 13})
+
+		other := newHTTP2Frame(0x0, 0, 3, "other")
+		if _, err := conn.Write(other); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		_, err := conn.Write(newHTTP2Frame(0x0, 0, 1, "BANANA"))
+		if err == nil {
+			t.Fatalf("Expecting error, got nothing")
+		}
+		expected := append(other, newHTTP2Frame(0x0, 0, 1, "BANANA")[:13]...)
+		if !bytes.Equal(recorded.written.Bytes(), expected) || !recorded.closed {
+			t.Fatalf("Expected [%v] to be written before closing, got [%v]", expected, recorded.written.Bytes())
+		}
+	})
+}
+
+func TestGrpcTransportFaults(t *testing.T) {
+	t.Run("keeps serving requests on a new connection after a GOAWAY", func(t *testing.T) {
+		conn, _ := startFaultyGrpcServer(t, "goaway:percent=100")
+
+		for i := 0; i < 3; i++ {
+			resp, err := pb.NewTheServiceClient(conn).TheFunction(context.TODO(), &pb.TheRequest{RequestUID: "goaway"})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if resp.Payload != "BANANA" {
+				t.Fatalf("Expected payload [BANANA], got [%s]", resp.Payload)
+			}
+		}
+	})
+
+	t.Run("only trickles the response to the faulted request", func(t *testing.T) {
+		conn, requestHandler := startFaultyGrpcServer(t, "trickle:percent=100,interval=2ms")
+		client := pb.NewTheServiceClient(conn)
+
+		start := time.Now()
+		if _, err := client.TheFunction(context.TODO(), &pb.TheRequest{RequestUID: "trickle"}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		trickled := time.Since(start)
+
+		requestHandler.Reconfigure(&service.Config{}, requestHandler.CurrentStrategy())
+		start = time.Now()
+		if _, err := client.TheFunction(context.TODO(), &pb.TheRequest{RequestUID: "trickle"}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if notTrickled := time.Since(start); notTrickled*4 > trickled {
+			t.Fatalf("Expected the next request over the connection not to be trickled, but took [%v] compared to [%v]", notTrickled, trickled)
+		}
+	})
+
+	t.Run("returns error if the ID of the stream of the request can't be read", func(t *testing.T) {
+		ctx := grpc.NewContextWithServerTransportStream(context.TODO(), &streamWithoutID{})
+		if _, err := streamIDFor(ctx); err == nil {
+			t.Fatalf("Expecting error, got nothing")
+		}
+	})
+
+	t.Run("fails requests whose connection is reset", func(t *testing.T) {
+		conn, _ := startFaultyGrpcServer(t, "reset:percent=100")
+
+		_, err := pb.NewTheServiceClient(conn).TheFunction(context.TODO(), &pb.TheRequest{RequestUID: "reset"})
+		if status.Code(err) != codes.Unavailable {
+			t.Fatalf("Expecting error with code [%s], got [%v]", codes.Unavailable, err)
+		}
+	})
+
+	t.Run("never responds to requests that hang", func(t *testing.T) {
+		conn, _ := startFaultyGrpcServer(t, "hang:percent=100")
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+		defer cancel()
+		_, err := pb.NewTheServiceClient(conn).TheFunction(ctx, &pb.TheRequest{RequestUID: "hang"})
+		if status.Code(err) != codes.DeadlineExceeded {
+			t.Fatalf("Expecting error with code [%s], got [%v]", codes.DeadlineExceeded, err)
+		}
+	})
+}
+
+func TestHTTPTransportFaults(t *testing.T) {
+	startFaultyHTTPServer := func(t *testing.T, transportFault string) *httptest.Server {
+		requestHandler := service.NewRequestHandler(&service.Config{TransportFaults: []string{transportFault}})
+		requestHandler.Strategy = &stubStrategy{theResponseToReturn: &pb.TheResponse{Payload: "BANANA"}}
+		theServer := httptest.NewServer(newHTTPHandler(requestHandler))
+		t.Cleanup(theServer.Close)
+		return theServer
+	}
+
+	post := func(client *http.Client, url string) (*http.Response, string, error) {
+		resp, err := client.Post(url, "application/json", strings.NewReader(`{"requestUID": "fault"}`))
+		if err != nil {
+			return nil, "", err
+		}
+		defer resp.Body.Close()
+		var body bytes.Buffer
+		_, err = body.ReadFrom(resp.Body)
+		return resp, body.String(), err
+	}
+
+	for _, transportFault := range []string{"reset:percent=100", "close-after:percent=100,bytes=70", "stall-body:percent=100", "hang:percent=100"} {
+		t.Run("fails requests with "+transportFault, func(t *testing.T) {
+			theServer := startFaultyHTTPServer(t, transportFault)
+
+			_, _, err := post(&http.Client{Timeout: time.Millisecond * 200}, theServer.URL)
+			if err == nil {
+				t.Fatalf("Expecting error, got nothing")
+			}
+		})
+	}
+
+	t.Run("trickles the whole response", func(t *testing.T) {
+		theServer := startFaultyHTTPServer(t, "trickle:percent=100,interval=1ms")
+
+		start := time.Now()
+		_, body, err := post(http.DefaultClient, theServer.URL)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if !strings.Contains(body, "BANANA") || time.Since(start) < time.Duration(len(body))*time.Millisecond {
+			t.Fatalf("Expected response [%s] to be trickled at 1 byte per millisecond, took [%v]", body, time.Since(start))
+		}
+	})
+
+	t.Run("asks for the connection to be closed instead of a GOAWAY", func(t *testing.T) {
+		theServer := startFaultyHTTPServer(t, "goaway:percent=100")
+
+		resp, body, err := post(http.DefaultClient, theServer.URL)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if !resp.Close || !strings.Contains(body, "BANANA") {
+			t.Fatalf("Expected response [%s] to close the connection, got %v", body, resp)
+		}
+	})
+}
@@ -0,0 +1,271 @@
+package replay
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	pb "github.com/buoyantio/bb/gen"
+	"github.com/buoyantio/bb/service"
+	"github.com/gogo/protobuf/jsonpb"
+)
+
+// maxLineSize is the longest line accepted in the files read
+const maxLineSize = 16 * 1024 * 1024
+
+// Record is a request to be replayed, optionally with when it was originally made. In a file, each record is either a
+// JSON object with timestamp and request fields, or the request on its own.
+type Record struct {
+	Timestamp *time.Time
+	Request   *pb.TheRequest
+}
+
+type jsonRecord struct {
+	Timestamp *time.Time      `json:"timestamp"`
+	Request   json.RawMessage `json:"request"`
+}
+
+// Result is the outcome of replaying a request, written as a line of JSON.
+type Result struct {
+	RequestUID      string          `json:"requestUID"`
+	Response        json.RawMessage `json:"response,omitempty"`
+	Error           string          `json:"error,omitempty"`
+	LatencyInMillis float64         `json:"latencyInMillis"`
+}
+
+// Options configures how requests are replayed.
+type Options struct {
+	// RequestsPerSecond is the fixed rate requests are replayed at. If zero, the timing of their timestamps is kept.
+	RequestsPerSecond float64
+
+	// Expected holds the results expected for each request UID, if they are to be compared with the actual ones
+	Expected map[string]*Result
+}
+
+// Summary counts the requests replayed, the ones that failed, and the ones whose result wasn't the expected one.
+type Summary struct {
+	Requests    int
+	Errors      int
+	Differences []string
+}
+
+// ParseRecord parses a line of a JSONL file into a Record
+func ParseRecord(line string) (*Record, error) {
+	var wrapped jsonRecord
+	if err := json.Unmarshal([]byte(line), &wrapped); err != nil {
+		return nil, err
+	}
+
+	requestJSON := line
+	if wrapped.Request != nil {
+		requestJSON = string(wrapped.Request)
+	}
+
+	// unknown fields are allowed so that requests can be read back from the wrapped format, or from results
+	unmarshaler := jsonpb.Unmarshaler{AllowUnknownFields: true}
+	var req pb.TheRequest
+	if err := unmarshaler.Unmarshal(strings.NewReader(requestJSON), &req); err != nil {
+		return nil, err
+	}
+
+	return &Record{Timestamp: wrapped.Timestamp, Request: &req}, nil
+}
+
+// ReadResults reads results written by a previous replay, keyed by request UID
+func ReadResults(in io.Reader) (map[string]*Result, error) {
+	results := map[string]*Result{}
+	err := forEachLine(in, func(lineNumber int, line string) error {
+		var result Result
+		if err := json.Unmarshal([]byte(line), &result); err != nil {
+			return fmt.Errorf("error parsing result on line [%d]: %v", lineNumber, err)
+		}
+		results[result.RequestUID] = &result
+		return nil
+	})
+	return results, err
+}
+
+func forEachLine(in io.Reader, do func(lineNumber int, line string) error) error {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if err := do(lineNumber, line); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// payloadOf returns the payload of a response written as JSON, ignoring fields such as hops that change every time
+func payloadOf(response json.RawMessage) string {
+	if response == nil {
+		return ""
+	}
+
+	var resp pb.TheResponse
+	unmarshaler := jsonpb.Unmarshaler{AllowUnknownFields: true}
+	if err := unmarshaler.Unmarshal(strings.NewReader(string(response)), &resp); err != nil {
+		return string(response)
+	}
+	return resp.Payload
+}
+
+// diff describes how the actual result differs from the expected one, or returns an empty string if it doesn't
+func diff(expected *Result, actual *Result) string {
+	if expected == nil {
+		return fmt.Sprintf("request UID [%s] wasn't expected", actual.RequestUID)
+	}
+
+	expectedPayload, actualPayload := payloadOf(expected.Response), payloadOf(actual.Response)
+	if expectedPayload != actualPayload || expected.Error != actual.Error {
+		return fmt.Sprintf("request UID [%s] expected payload [%s] error [%s], got payload [%s] error [%s]", actual.RequestUID, expectedPayload, expected.Error, actualPayload, actual.Error)
+	}
+	return ""
+}
+
+// schedule returns when a record should be replayed, relative to the start of the replay
+type schedule func(n int, record *Record) time.Duration
+
+func newSchedule(options *Options) schedule {
+	if options.RequestsPerSecond > 0 {
+		return func(n int, _ *Record) time.Duration {
+			return time.Duration(float64(n) / options.RequestsPerSecond * float64(time.Second))
+		}
+	}
+
+	var first *time.Time
+	return func(_ int, record *Record) time.Duration {
+		if record.Timestamp == nil {
+			return 0
+		}
+		if first == nil {
+			first = record.Timestamp
+		}
+		return record.Timestamp.Sub(*first)
+	}
+}
+
+func send(ctx context.Context, client service.Client, req *pb.TheRequest) *Result {
+	start := time.Now()
+	resp, err := client.Send(ctx, req)
+	result := &Result{
+		RequestUID:      req.RequestUID,
+		LatencyInMillis: float64(time.Since(start)) / float64(time.Millisecond),
+	}
+
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	marshaler := jsonpb.Marshaler{}
+	response, err := marshaler.MarshalToString(resp)
+	if err != nil {
+		result.Error = fmt.Sprintf("error marshalling the response: %v", err)
+		return result
+	}
+	result.Response = json.RawMessage(response)
+	return result
+}
+
+// Run replays the records read from in, sending them to each of the clients in turn, and writes their results to out
+// in the order the records were read. If ctx is done before every record is replayed, the summary of those replayed
+// so far is returned along with the context's error, and expected results are only compared with theirs.
+func Run(ctx context.Context, clients []service.Client, in io.Reader, out io.Writer, options *Options) (*Summary, error) {
+	if len(clients) == 0 {
+		return nil, fmt.Errorf("replay requires at least one target, but had clients [%v]", clients)
+	}
+
+	if options.RequestsPerSecond < 0 {
+		return nil, fmt.Errorf("requests per second can't be negative, but was [%f]", options.RequestsPerSecond)
+	}
+
+	// each request gets a channel for its result, queued in the order the requests were read
+	pending := make(chan chan *Result, 1024)
+	summary := &Summary{}
+	seen := map[string]bool{}
+	var writeErr error
+	var writing sync.WaitGroup
+	writing.Add(1)
+	go func() {
+		defer writing.Done()
+		encoder := json.NewEncoder(out)
+		for resultCh := range pending {
+			result := <-resultCh
+			summary.Requests++
+			if result.Error != "" {
+				summary.Errors++
+			}
+
+			if options.Expected != nil {
+				seen[result.RequestUID] = true
+				if difference := diff(options.Expected[result.RequestUID], result); difference != "" {
+					summary.Differences = append(summary.Differences, difference)
+				}
+			}
+
+			if err := encoder.Encode(result); err != nil && writeErr == nil {
+				writeErr = err
+			}
+		}
+	}()
+
+	start := time.Now()
+	when := newSchedule(options)
+	n := 0
+	readErr := forEachLine(in, func(lineNumber int, line string) error {
+		record, err := ParseRecord(line)
+		if err != nil {
+			return fmt.Errorf("error parsing request on line [%d]: %v", lineNumber, err)
+		}
+
+		if record.Request.RequestUID == "" {
+			record.Request.RequestUID = fmt.Sprintf("replay-%d", lineNumber)
+		}
+
+		timer := time.NewTimer(time.Until(start.Add(when(n, record))))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		resultCh := make(chan *Result, 1)
+		pending <- resultCh
+		client := clients[n%len(clients)]
+		go func(req *pb.TheRequest) {
+			resultCh <- send(ctx, client, req)
+		}(record.Request)
+		n++
+		return nil
+	})
+
+	close(pending)
+	writing.Wait()
+
+	if readErr != nil {
+		return summary, readErr
+	}
+
+	// requests are only missing if every record was read, rather than some left unreplayed when ctx was done
+	missing := make([]string, 0)
+	for requestUID := range options.Expected {
+		if !seen[requestUID] {
+			missing = append(missing, fmt.Sprintf("request UID [%s] was expected but not replayed", requestUID))
+		}
+	}
+	sort.Strings(missing)
+	summary.Differences = append(summary.Differences, missing...)
+	return summary, writeErr
+}
@@ -0,0 +1,222 @@
+package replay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/buoyantio/bb/gen"
+	"github.com/buoyantio/bb/service"
+)
+
+type echoClient struct {
+	mu       sync.Mutex
+	received []time.Time
+}
+
+func (c *echoClient) Close() error { return nil }
+
+func (c *echoClient) GetID() string { return "echo" }
+
+func (c *echoClient) Send(ctx context.Context, req *pb.TheRequest) (*pb.TheResponse, error) {
+	c.mu.Lock()
+	c.received = append(c.received, time.Now())
+	c.mu.Unlock()
+
+	if req.Payload == "fail" {
+		return nil, errors.New("expected")
+	}
+	return &pb.TheResponse{RequestUID: req.RequestUID, Payload: strings.ToUpper(req.Payload)}, nil
+}
+
+func readResults(t *testing.T, out *bytes.Buffer) []*Result {
+	results := make([]*Result, 0)
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var result Result
+		if err := json.Unmarshal([]byte(line), &result); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		results = append(results, &result)
+	}
+	return results
+}
+
+func TestParseRecord(t *testing.T) {
+	t.Run("parses bare and timestamped requests", func(t *testing.T) {
+		bare, err := ParseRecord(`{"requestUID":"1","payload":"banana"}`)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if bare.Timestamp != nil || bare.Request.RequestUID != "1" || bare.Request.Payload != "banana" {
+			t.Fatalf("Expected request UID [1] with payload [banana] and no timestamp, but got %+v", bare)
+		}
+
+		timestamped, err := ParseRecord(`{"timestamp":"2024-01-02T15:04:05.5Z","request":{"requestUID":"2"}}`)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		expectedTimestamp := time.Date(2024, 1, 2, 15, 4, 5, 500000000, time.UTC)
+		if timestamped.Timestamp == nil || !timestamped.Timestamp.Equal(expectedTimestamp) || timestamped.Request.RequestUID != "2" {
+			t.Fatalf("Expected request UID [2] with timestamp [%v], but got %+v", expectedTimestamp, timestamped)
+		}
+	})
+
+	t.Run("returns error if the line isn't a request", func(t *testing.T) {
+		for _, line := range []string{"not json", `{"requestUID":1}`} {
+			if _, err := ParseRecord(line); err == nil {
+				t.Fatalf("Expecting error for line [%s], got nothing", line)
+			}
+		}
+	})
+}
+
+func TestRun(t *testing.T) {
+	t.Run("writes the result of each request in the order they were read", func(t *testing.T) {
+		in := strings.NewReader(`{"requestUID":"1","payload":"banana"}
+
+{"requestUID":"2","payload":"fail"}
+{"payload":"apple"}
+`)
+		var out bytes.Buffer
+
+		summary, err := Run(context.TODO(), []service.Client{&echoClient{}}, in, &out, &Options{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if summary.Requests != 3 || summary.Errors != 1 {
+			t.Fatalf("Expected [3] requests with [1] error, but got %+v", summary)
+		}
+
+		results := readResults(t, &out)
+		if len(results) != 3 {
+			t.Fatalf("Expected [3] results, but got [%d]", len(results))
+		}
+
+		if results[0].RequestUID != "1" || payloadOf(results[0].Response) != "BANANA" {
+			t.Fatalf("Expected first result to be for request UID [1] with payload [BANANA], but got %+v", results[0])
+		}
+
+		if results[1].RequestUID != "2" || results[1].Error != "expected" || results[1].Response != nil {
+			t.Fatalf("Expected second result to be the error for request UID [2], but got %+v", results[1])
+		}
+
+		if results[2].RequestUID != "replay-4" {
+			t.Fatalf("Expected request without UID to be assigned one from its line number, but got %+v", results[2])
+		}
+	})
+
+	t.Run("keeps the timing of timestamped requests", func(t *testing.T) {
+		in := strings.NewReader(`{"timestamp":"2024-01-02T15:04:05Z","request":{"requestUID":"1"}}
+{"timestamp":"2024-01-02T15:04:05.2Z","request":{"requestUID":"2"}}
+`)
+		client := &echoClient{}
+
+		_, err := Run(context.TODO(), []service.Client{client}, in, &bytes.Buffer{}, &Options{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if gap := client.received[1].Sub(client.received[0]); gap < 150*time.Millisecond || gap > time.Second {
+			t.Fatalf("Expected requests to be [200ms] apart, but they were [%v] apart", gap)
+		}
+	})
+
+	t.Run("ignores timestamps when replaying at a fixed rate", func(t *testing.T) {
+		in := strings.NewReader(`{"timestamp":"2024-01-02T15:04:05Z","request":{"requestUID":"1"}}
+{"timestamp":"2024-01-02T16:04:05Z","request":{"requestUID":"2"}}
+`)
+		client := &echoClient{}
+
+		_, err := Run(context.TODO(), []service.Client{client}, in, &bytes.Buffer{}, &Options{RequestsPerSecond: 20})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if gap := client.received[1].Sub(client.received[0]); gap < 25*time.Millisecond || gap > time.Second {
+			t.Fatalf("Expected requests to be [50ms] apart, but they were [%v] apart", gap)
+		}
+	})
+
+	t.Run("reports results that differ from the expected ones", func(t *testing.T) {
+		expected, err := ReadResults(strings.NewReader(`{"requestUID":"1","response":{"requestUID":"1","payload":"BANANA","hops":[{"serviceID":"a"}]},"latencyInMillis":3}
+{"requestUID":"2","response":{"requestUID":"2","payload":"APPLE"}}
+{"requestUID":"3","error":"expected"}
+`))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		in := strings.NewReader(`{"requestUID":"1","payload":"banana"}
+{"requestUID":"2","payload":"pear"}
+`)
+		summary, err := Run(context.TODO(), []service.Client{&echoClient{}}, in, &bytes.Buffer{}, &Options{Expected: expected})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if len(summary.Differences) != 2 {
+			t.Fatalf("Expected [2] differences, but got %v", summary.Differences)
+		}
+
+		if !strings.Contains(summary.Differences[0], "[2]") || !strings.Contains(summary.Differences[1], "[3] was expected but not replayed") {
+			t.Fatalf("Expected request UIDs [2] and [3] to differ, but got %v", summary.Differences)
+		}
+	})
+
+	t.Run("returns the summary of the requests replayed before ctx is done", func(t *testing.T) {
+		in := strings.NewReader(`{"timestamp":"2024-01-02T15:04:05Z","request":{"requestUID":"1"}}
+{"timestamp":"2024-01-02T16:04:05Z","request":{"requestUID":"2"}}
+`)
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		summary, err := Run(ctx, []service.Client{&echoClient{}}, in, &bytes.Buffer{}, &Options{})
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("Expecting error [%v], got [%v]", context.DeadlineExceeded, err)
+		}
+
+		if summary == nil || summary.Requests != 1 {
+			t.Fatalf("Expected a summary of the [1] request replayed, but got %+v", summary)
+		}
+	})
+
+	t.Run("only compares the requests replayed before ctx is done with the expected ones", func(t *testing.T) {
+		expected, err := ReadResults(strings.NewReader(`{"requestUID":"1","response":{"requestUID":"1","payload":"BANANA"}}
+{"requestUID":"2","response":{"requestUID":"2","payload":"APPLE"}}
+`))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		in := strings.NewReader(`{"timestamp":"2024-01-02T15:04:05Z","request":{"requestUID":"1","payload":"banana"}}
+{"timestamp":"2024-01-02T16:04:05Z","request":{"requestUID":"2","payload":"apple"}}
+`)
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		summary, err := Run(ctx, []service.Client{&echoClient{}}, in, &bytes.Buffer{}, &Options{Expected: expected})
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("Expecting error [%v], got [%v]", context.DeadlineExceeded, err)
+		}
+
+		if summary.Requests != 1 || len(summary.Differences) != 0 {
+			t.Fatalf("Expected the [1] request replayed to match, and no differences for the one left, but got %+v", summary)
+		}
+	})
+
+	t.Run("returns error if a line can't be parsed", func(t *testing.T) {
+		in := strings.NewReader("{\"requestUID\":\"1\"}\nnot json\n")
+		_, err := Run(context.TODO(), []service.Client{&echoClient{}}, in, &bytes.Buffer{}, &Options{})
+		if err == nil || !strings.Contains(err.Error(), "line [2]") {
+			t.Fatalf("Expecting error about line [2], got [%v]", err)
+		}
+	})
+}
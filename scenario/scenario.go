@@ -0,0 +1,197 @@
+package scenario
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/buoyantio/bb/service"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// Scenario changes the faults of a service along a timeline of steps, declared in a YAML file
+type Scenario struct {
+	Loop  bool    `yaml:"loop,omitempty"`
+	Steps []*Step `yaml:"steps"`
+}
+
+// Step is a period of time during which a service has the faults set, and its own settings for those left unset.
+// Steps aren't cumulative, as each one replaces the faults of the previous one. A step that crashes the service ends
+// the scenario.
+type Step struct {
+	Name                  string        `yaml:"name,omitempty"`
+	Duration              time.Duration `yaml:"duration"`
+	PercentFailure        *int          `yaml:"percent-failure,omitempty"`
+	FailureTypes          []string      `yaml:"failure-types,omitempty"`
+	SleepInMillis         *int          `yaml:"sleep-in-millis,omitempty"`
+	CPUBurnInMillis       *int          `yaml:"cpu-burn-in-millis,omitempty"`
+	MemoryPerRequestMB    *int          `yaml:"memory-per-request-mb,omitempty"`
+	MemoryLeakMBPerMinute *int          `yaml:"memory-leak-mb-per-minute,omitempty"`
+	Latency               *string       `yaml:"latency,omitempty"`
+	TransportFaults       []string      `yaml:"transport-faults,omitempty"`
+	Unhealthy             *bool         `yaml:"unhealthy,omitempty"`
+	Crash                 bool          `yaml:"crash,omitempty"`
+}
+
+// Target is a service whose settings can be changed while it runs
+type Target interface {
+	RuntimeConfig() *service.RuntimeConfig
+	Reconfigure(changes *service.RuntimeConfig) error
+}
+
+// ReadFile reads and validates a scenario from a YAML file
+func ReadFile(path string) (*Scenario, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Parse(f)
+}
+
+// Parse reads and validates a scenario declared in YAML
+func Parse(r io.Reader) (*Scenario, error) {
+	decoder := yaml.NewDecoder(r)
+	decoder.KnownFields(true)
+
+	var s Scenario
+	if err := decoder.Decode(&s); err != nil {
+		return nil, fmt.Errorf("error parsing scenario: %v", err)
+	}
+
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Validate checks that the scenario has steps, that their settings are valid, and that every step lasts for some time,
+// except for the last one when the scenario doesn't loop, which then lasts until the service stops
+func (s *Scenario) Validate() error {
+	if len(s.Steps) == 0 {
+		return fmt.Errorf("scenario has no steps")
+	}
+
+	for i, step := range s.Steps {
+		last := i == len(s.Steps)-1
+		if step.Duration < 0 || (step.Duration == 0 && !step.Crash && (s.Loop || !last)) {
+			return fmt.Errorf("step [%s] must last for a positive duration", step.name(i))
+		}
+		if step.Crash && (s.Loop || !last) {
+			return fmt.Errorf("step [%s] crashes the service, so it must be the last step of a scenario that doesn't loop", step.name(i))
+		}
+
+		if _, err := step.changes(&service.RuntimeConfig{}).Apply(&service.Config{}); err != nil {
+			return fmt.Errorf("step [%s]: %v", step.name(i), err)
+		}
+	}
+	return nil
+}
+
+// Run goes through the steps of the scenario, changing the faults of target as each step starts, until the scenario
+// ends or ctx is done. Once the scenario ends, target gets its own faults back, unless a step crashed it, in which case
+// crash is called instead.
+func (s *Scenario) Run(ctx context.Context, target Target, crash func()) error {
+	own := target.RuntimeConfig()
+	for {
+		for i, step := range s.Steps {
+			if step.Crash {
+				log.Infof("Scenario step [%s] crashes the service", step.name(i))
+				crash()
+				return nil
+			}
+
+			log.Infof("Scenario step [%s] starts, lasting [%v]", step.name(i), step.Duration)
+			if err := target.Reconfigure(step.changes(own)); err != nil {
+				return fmt.Errorf("error applying step [%s]: %v", step.name(i), err)
+			}
+
+			if step.Duration == 0 {
+				<-ctx.Done()
+				return nil
+			}
+
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(step.Duration):
+			}
+		}
+
+		if !s.Loop {
+			log.Infof("Scenario ended, restoring the service's own settings")
+			return target.Reconfigure((&Step{}).changes(own))
+		}
+	}
+}
+
+// MarshalYAML writes the duration of the step as a string, such as 1m30s, which is how it is read back
+func (s *Step) MarshalYAML() (interface{}, error) {
+	type step Step
+	var node yaml.Node
+	if err := node.Encode((*step)(s)); err != nil {
+		return nil, err
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == "duration" {
+			node.Content[i+1].SetString(s.Duration.String())
+		}
+	}
+	return &node, nil
+}
+
+// changes returns the settings of the service during this step, which are its own settings for faults left unset
+func (s *Step) changes(own *service.RuntimeConfig) *service.RuntimeConfig {
+	changes := &service.RuntimeConfig{
+		PercentFailure:        own.PercentFailure,
+		FailureTypes:          own.FailureTypes,
+		SleepInMillis:         own.SleepInMillis,
+		CPUBurnInMillis:       own.CPUBurnInMillis,
+		MemoryPerRequestMB:    own.MemoryPerRequestMB,
+		MemoryLeakMBPerMinute: own.MemoryLeakMBPerMinute,
+		Latency:               own.Latency,
+		TransportFaults:       own.TransportFaults,
+		Unhealthy:             own.Unhealthy,
+	}
+
+	if s.PercentFailure != nil {
+		changes.PercentFailure = s.PercentFailure
+	}
+	if s.FailureTypes != nil {
+		changes.FailureTypes = s.FailureTypes
+	}
+	if s.SleepInMillis != nil {
+		changes.SleepInMillis = s.SleepInMillis
+	}
+	if s.CPUBurnInMillis != nil {
+		changes.CPUBurnInMillis = s.CPUBurnInMillis
+	}
+	if s.MemoryPerRequestMB != nil {
+		changes.MemoryPerRequestMB = s.MemoryPerRequestMB
+	}
+	if s.MemoryLeakMBPerMinute != nil {
+		changes.MemoryLeakMBPerMinute = s.MemoryLeakMBPerMinute
+	}
+	if s.Latency != nil {
+		changes.Latency = s.Latency
+	}
+	if s.TransportFaults != nil {
+		changes.TransportFaults = s.TransportFaults
+	}
+	if s.Unhealthy != nil {
+		changes.Unhealthy = s.Unhealthy
+	}
+	return changes
+}
+
+func (s *Step) name(i int) string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return fmt.Sprintf("#%d", i+1)
+}
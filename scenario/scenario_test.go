@@ -0,0 +1,243 @@
+package scenario
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/buoyantio/bb/service"
+	"gopkg.in/yaml.v3"
+)
+
+type recordingTarget struct {
+	mu      sync.Mutex
+	config  *service.Config
+	changes chan *service.RuntimeConfig
+}
+
+func newRecordingTarget() *recordingTarget {
+	return &recordingTarget{
+		config:  &service.Config{PercentageFailedRequests: 5, SleepInMillis: 10},
+		changes: make(chan *service.RuntimeConfig, 100),
+	}
+}
+
+func (r *recordingTarget) RuntimeConfig() *service.RuntimeConfig {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return service.NewRuntimeConfig(r.config)
+}
+
+func (r *recordingTarget) Reconfigure(changes *service.RuntimeConfig) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	config, err := changes.Apply(r.config)
+	if err != nil {
+		return err
+	}
+	r.config = config
+	r.changes <- changes
+	return nil
+}
+
+func (r *recordingTarget) next(t *testing.T) *service.RuntimeConfig {
+	select {
+	case changes := <-r.changes:
+		return changes
+	case <-time.After(time.Second * 5):
+		t.Fatalf("Expected the scenario to reconfigure the service, but it didn't")
+		return nil
+	}
+}
+
+func TestParse(t *testing.T) {
+	t.Run("reads steps and their settings", func(t *testing.T) {
+		s, err := Parse(strings.NewReader(`
+loop: true
+steps:
+- name: healthy
+  duration: 60s
+- name: errors
+  duration: 2m
+  percent-failure: 30
+  failure-types: ["http:status=503"]
+- duration: 1m
+  latency: uniform:min=400ms,max=600ms
+  unhealthy: true
+`))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if !s.Loop || len(s.Steps) != 3 {
+			t.Fatalf("Expected a looping scenario with 3 steps, got %+v", s)
+		}
+		if s.Steps[0].Duration != time.Minute || s.Steps[0].PercentFailure != nil {
+			t.Fatalf("Expected first step to last a minute without changing anything, got %+v", s.Steps[0])
+		}
+		if s.Steps[1].Duration != time.Minute*2 || *s.Steps[1].PercentFailure != 30 || s.Steps[1].FailureTypes[0] != "http:status=503" {
+			t.Fatalf("Expected second step to fail 30%% of requests with a 503 for 2 minutes, got %+v", s.Steps[1])
+		}
+		if *s.Steps[2].Latency != "uniform:min=400ms,max=600ms" || !*s.Steps[2].Unhealthy || s.Steps[2].name(2) != "#3" {
+			t.Fatalf("Expected an unnamed third step adding latency and failing health checks, got %+v", s.Steps[2])
+		}
+	})
+
+	t.Run("reads back the scenarios it writes", func(t *testing.T) {
+		s, err := Parse(strings.NewReader("steps:\n- duration: 1m30s\n  sleep-in-millis: 500\n- crash: true\n"))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		out, err := yaml.Marshal(s)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		read, err := Parse(strings.NewReader(string(out)))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if read.Steps[0].Duration != time.Second*90 || *read.Steps[0].SleepInMillis != 500 || !read.Steps[1].Crash {
+			t.Fatalf("Expected the same steps to be read back, got:\n%s", out)
+		}
+	})
+
+	t.Run("returns error for invalid scenarios", func(t *testing.T) {
+		invalid := map[string]string{
+			"no steps":                         "steps: []",
+			"unknown field":                    "steps:\n- duration: 1m\n  colour: red",
+			"no duration":                      "steps:\n- percent-failure: 10\n- duration: 1m",
+			"no duration when looping":         "loop: true\nsteps:\n- percent-failure: 10",
+			"negative duration":                "steps:\n- duration: -1m",
+			"crash before the last step":       "steps:\n- crash: true\n- duration: 1m",
+			"crash when looping":               "loop: true\nsteps:\n- duration: 1m\n- crash: true",
+			"invalid percent failure":          "steps:\n- duration: 1m\n  percent-failure: 101",
+			"invalid latency":                  "steps:\n- duration: 1m\n  latency: normal:mean=10ms",
+			"invalid failure type":             "steps:\n- duration: 1m\n  failure-types: [http:status=200]",
+			"invalid transport fault":          "steps:\n- duration: 1m\n  transport-faults: [drop:percent=5]",
+			"duration that isn't a duration":   "steps:\n- duration: soon",
+			"steps that aren't a list of maps": "steps: healthy",
+		}
+
+		for name, yaml := range invalid {
+			_, err := Parse(strings.NewReader(yaml))
+			if err == nil {
+				t.Fatalf("Expecting error for scenario with %s, got nothing", name)
+			}
+		}
+	})
+}
+
+func TestRun(t *testing.T) {
+	percent := func(p int) *int { return &p }
+	latency := "uniform:min=400ms,max=600ms"
+
+	t.Run("applies each step on top of the service's own settings rather than the previous step, and restores them at the end", func(t *testing.T) {
+		target := newRecordingTarget()
+		s := &Scenario{Steps: []*Step{
+			{Duration: time.Millisecond, PercentFailure: percent(30)},
+			{Duration: time.Millisecond, Latency: &latency},
+		}}
+
+		err := s.Run(context.Background(), target, func() { t.Fatalf("Expected the service not to crash") })
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		first := target.next(t)
+		if *first.PercentFailure != 30 || *first.SleepInMillis != 10 || *first.Latency != "" {
+			t.Fatalf("Expected first step to only fail 30%% of requests, got %+v", first)
+		}
+		second := target.next(t)
+		if *second.PercentFailure != 5 || *second.SleepInMillis != 10 || *second.Latency != latency {
+			t.Fatalf("Expected second step to only add latency, no longer failing requests, got %+v", second)
+		}
+		restored := target.next(t)
+		if *restored.PercentFailure != 5 || *restored.SleepInMillis != 10 || *restored.Latency != "" {
+			t.Fatalf("Expected the service's own settings to be restored, got %+v", restored)
+		}
+		if restored.GRPCDownstreamServers != nil || restored.Arguments != nil {
+			t.Fatalf("Expected only faults to be restored, got %+v", restored)
+		}
+	})
+
+	t.Run("starts over when looping, until ctx is done", func(t *testing.T) {
+		target := newRecordingTarget()
+		s := &Scenario{Loop: true, Steps: []*Step{
+			{Duration: time.Millisecond, PercentFailure: percent(30)},
+			{Duration: time.Millisecond, PercentFailure: percent(60)},
+		}}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() { done <- s.Run(ctx, target, func() {}) }()
+
+		for _, expected := range []int{30, 60, 30, 60} {
+			if changes := target.next(t); *changes.PercentFailure != expected {
+				t.Fatalf("Expected step to fail [%d]%% of requests, got [%d]", expected, *changes.PercentFailure)
+			}
+		}
+
+		cancel()
+		if err := <-done; err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("keeps the last step until ctx is done when it has no duration", func(t *testing.T) {
+		target := newRecordingTarget()
+		s := &Scenario{Steps: []*Step{{PercentFailure: percent(100)}}}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() { done <- s.Run(ctx, target, func() {}) }()
+
+		if changes := target.next(t); *changes.PercentFailure != 100 {
+			t.Fatalf("Expected step to fail all requests, got [%d]", *changes.PercentFailure)
+		}
+
+		select {
+		case <-done:
+			t.Fatalf("Expected the scenario to keep running until ctx is done")
+		case <-time.After(time.Millisecond * 50):
+		}
+
+		cancel()
+		if err := <-done; err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("crashes the service instead of restoring its settings", func(t *testing.T) {
+		target := newRecordingTarget()
+		s := &Scenario{Steps: []*Step{
+			{Duration: time.Millisecond, PercentFailure: percent(30)},
+			{Crash: true},
+		}}
+
+		crashed := false
+		err := s.Run(context.Background(), target, func() { crashed = true })
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if !crashed {
+			t.Fatalf("Expected the service to crash")
+		}
+		if target.next(t); len(target.changes) != 0 {
+			t.Fatalf("Expected only the first step to reconfigure the service, got %d more", len(target.changes))
+		}
+	})
+
+	t.Run("returns error when a step can't be applied", func(t *testing.T) {
+		target := newRecordingTarget()
+		s := &Scenario{Steps: []*Step{{Duration: time.Millisecond, PercentFailure: percent(200)}}}
+
+		if err := s.Run(context.Background(), target, func() {}); err == nil {
+			t.Fatalf("Expecting error, got nothing")
+		}
+	})
+}
@@ -0,0 +1,253 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	pb "github.com/buoyantio/bb/gen"
+	log "github.com/sirupsen/logrus"
+)
+
+// circuitBreakerBuckets is how many buckets the sliding window used to compute error rates is divided into
+const circuitBreakerBuckets = 10
+
+// ErrCircuitOpen is returned, without contacting the downstream service, by clients whose circuit breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+func (s circuitState) String() string {
+	switch s {
+	case circuitClosed:
+		return "closed"
+	case circuitOpen:
+		return "open"
+	default:
+		return "half-open"
+	}
+}
+
+// CircuitBreakerSettings configures when a circuit breaker trips and for how long it stays open.
+type CircuitBreakerSettings struct {
+	ConsecutiveFailures int
+	ErrorPercentage     float64
+	Window              time.Duration
+	MinRequests         int
+	Cooldown            time.Duration
+}
+
+// NewCircuitBreakerSettings creates CircuitBreakerSettings from the circuit breaker settings in the Config.
+func NewCircuitBreakerSettings(config *Config) (*CircuitBreakerSettings, error) {
+	if config.CircuitBreakerConsecutiveFailures < 0 || config.CircuitBreakerErrorPercentage < 0 || config.CircuitBreakerErrorPercentage > 100 {
+		return nil, fmt.Errorf("circuit breaker consecutive failures [%d] must be positive and error percentage [%f] must be between 0 and 100", config.CircuitBreakerConsecutiveFailures, config.CircuitBreakerErrorPercentage)
+	}
+
+	if config.CircuitBreakerErrorPercentage > 0 && config.CircuitBreakerWindow < circuitBreakerBuckets*time.Millisecond {
+		return nil, fmt.Errorf("circuit breaker window must be at least [%v], but was [%v]", circuitBreakerBuckets*time.Millisecond, config.CircuitBreakerWindow)
+	}
+
+	return &CircuitBreakerSettings{
+		ConsecutiveFailures: config.CircuitBreakerConsecutiveFailures,
+		ErrorPercentage:     config.CircuitBreakerErrorPercentage,
+		Window:              config.CircuitBreakerWindow,
+		MinRequests:         config.CircuitBreakerMinRequests,
+		Cooldown:            config.CircuitBreakerCooldown,
+	}, nil
+}
+
+type circuitBreakerBucket struct {
+	start    time.Time
+	requests int
+	failures int
+}
+
+type circuitBreakingClient struct {
+	underlyingClient    Client
+	settings            *CircuitBreakerSettings
+	mu                  sync.Mutex
+	state               circuitState
+	openedAt            time.Time
+	probeInFlight       bool
+	lastProbe           uint64
+	consecutiveFailures int
+	buckets             [circuitBreakerBuckets]circuitBreakerBucket
+	now                 func() time.Time
+}
+
+func (c *circuitBreakingClient) Close() error { return c.underlyingClient.Close() }
+
+func (c *circuitBreakingClient) GetID() string { return c.underlyingClient.GetID() }
+
+func (c *circuitBreakingClient) Send(ctx context.Context, req *pb.TheRequest) (*pb.TheResponse, error) {
+	allowed, probe := c.allowRequest()
+	if !allowed {
+		return nil, fmt.Errorf("%w for [%s], failing request UID [%s]", ErrCircuitOpen, c.GetID(), req.RequestUID)
+	}
+
+	resp, err := c.underlyingClient.Send(ctx, req)
+
+	// requests cancelled by the caller, such as hedged requests that lost the race, say nothing of the downstream service
+	if ctx.Err() != nil || errors.Is(err, context.Canceled) {
+		c.releaseProbe(probe)
+		return resp, err
+	}
+
+	c.recordResult(probe, err == nil)
+	return resp, err
+}
+
+// allowRequest returns true if the request can be sent downstream, moving an open circuit to half-open after the
+// cool-down, in which case only a single probe request is allowed through. Probes are numbered from 1, and the
+// number of the probe is returned along with it, or 0 for requests that aren't probes.
+func (c *circuitBreakingClient) allowRequest() (bool, uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch c.state {
+	case circuitOpen:
+		if c.now().Sub(c.openedAt) < c.settings.Cooldown {
+			return false, 0
+		}
+		c.transitionTo(circuitHalfOpen)
+		return true, c.startProbe()
+	case circuitHalfOpen:
+		if c.probeInFlight {
+			return false, 0
+		}
+		return true, c.startProbe()
+	default:
+		return true, 0
+	}
+}
+
+func (c *circuitBreakingClient) startProbe() uint64 {
+	c.probeInFlight = true
+	c.lastProbe++
+	return c.lastProbe
+}
+
+// releaseProbe lets another probe through if probe, whose outcome isn't known, was the one in flight
+func (c *circuitBreakingClient) releaseProbe(probe uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.state == circuitHalfOpen && probe == c.lastProbe {
+		c.probeInFlight = false
+	}
+}
+
+// recordResult records the outcome of a request, where probe is the number of the probe, or 0 if it wasn't one
+func (c *circuitBreakingClient) recordResult(probe uint64, success bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.state == circuitHalfOpen {
+		if probe != c.lastProbe {
+			// a request sent before the circuit opened has just completed, and says nothing of the probe's fate
+			return
+		}
+		c.probeInFlight = false
+		if success {
+			c.reset()
+			c.transitionTo(circuitClosed)
+		} else {
+			c.trip()
+		}
+		return
+	}
+
+	if c.state == circuitOpen {
+		// a request sent before the circuit opened has just completed
+		return
+	}
+
+	bucket := c.currentBucket()
+	bucket.requests++
+	if success {
+		c.consecutiveFailures = 0
+		return
+	}
+	bucket.failures++
+	c.consecutiveFailures++
+
+	if c.settings.ConsecutiveFailures > 0 && c.consecutiveFailures >= c.settings.ConsecutiveFailures {
+		log.Warnf("Client [%s] failed [%d] consecutive requests", c.GetID(), c.consecutiveFailures)
+		c.trip()
+		return
+	}
+
+	if c.settings.ErrorPercentage > 0 {
+		requests, failures := c.windowTotals()
+		errorPercentage := 100 * float64(failures) / float64(requests)
+		if requests >= c.settings.MinRequests && errorPercentage >= c.settings.ErrorPercentage {
+			log.Warnf("Client [%s] failed %.1f%% of [%d] requests over the last [%v]", c.GetID(), errorPercentage, requests, c.settings.Window)
+			c.trip()
+		}
+	}
+}
+
+func (c *circuitBreakingClient) trip() {
+	c.openedAt = c.now()
+	c.reset()
+	c.transitionTo(circuitOpen)
+}
+
+func (c *circuitBreakingClient) reset() {
+	c.consecutiveFailures = 0
+	c.buckets = [circuitBreakerBuckets]circuitBreakerBucket{}
+}
+
+func (c *circuitBreakingClient) transitionTo(newState circuitState) {
+	log.Warnf("Circuit breaker for client [%s] transitioned from [%s] to [%s]", c.GetID(), c.state, newState)
+	c.state = newState
+}
+
+func (c *circuitBreakingClient) bucketWidth() time.Duration {
+	return c.settings.Window / circuitBreakerBuckets
+}
+
+func (c *circuitBreakingClient) currentBucket() *circuitBreakerBucket {
+	if c.settings.Window <= 0 {
+		return &c.buckets[0]
+	}
+
+	width := c.bucketWidth()
+	start := c.now().Truncate(width)
+	bucket := &c.buckets[(start.UnixNano()/int64(width))%circuitBreakerBuckets]
+	if !bucket.start.Equal(start) {
+		*bucket = circuitBreakerBucket{start: start}
+	}
+	return bucket
+}
+
+func (c *circuitBreakingClient) windowTotals() (int, int) {
+	windowStart := c.now().Add(-c.settings.Window)
+	requests, failures := 0, 0
+	for _, bucket := range c.buckets {
+		if bucket.start.After(windowStart) {
+			requests += bucket.requests
+			failures += bucket.failures
+		}
+	}
+	return requests, failures
+}
+
+// MakeCircuitBreaking creates a new Client that stops sending requests to the downstream service for a while once it
+// fails too often, failing them straight away with ErrCircuitOpen.
+func MakeCircuitBreaking(client Client, settings *CircuitBreakerSettings) Client {
+	return &circuitBreakingClient{
+		underlyingClient: client,
+		settings:         settings,
+		state:            circuitClosed,
+		now:              time.Now,
+	}
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	pb "github.com/buoyantio/bb/gen"
+)
+
+func newTestCircuitBreakingClient(underlyingClient Client, settings *CircuitBreakerSettings, now *time.Time) *circuitBreakingClient {
+	client := MakeCircuitBreaking(underlyingClient, settings).(*circuitBreakingClient)
+	client.now = func() time.Time { return *now }
+	return client
+}
+
+func TestCircuitBreakingClient(t *testing.T) {
+	request := &pb.TheRequest{RequestUID: "123"}
+
+	t.Run("opens after consecutive failures and fails fast", func(t *testing.T) {
+		now := time.Unix(1000, 0)
+		attempts := 0
+		underlyingClient := &MockClient{
+			IDToReturn:         "downstream",
+			ErrorToReturn:      errors.New("expected"),
+			RequestInterceptor: func(req *pb.TheRequest) { attempts++ },
+		}
+		client := newTestCircuitBreakingClient(underlyingClient, &CircuitBreakerSettings{ConsecutiveFailures: 3, Cooldown: time.Second}, &now)
+
+		for i := 0; i < 3; i++ {
+			client.Send(context.Background(), request)
+		}
+
+		_, err := client.Send(context.Background(), request)
+		if !errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("Expected error to be [%v], but got [%v]", ErrCircuitOpen, err)
+		}
+
+		if attempts != 3 {
+			t.Fatalf("Expected open circuit not to contact the downstream service, but it had [%d] requests", attempts)
+		}
+	})
+
+	t.Run("lets a probe through after the cool-down and closes if it succeeds", func(t *testing.T) {
+		now := time.Unix(1000, 0)
+		underlyingClient := &MockClient{IDToReturn: "downstream", ErrorToReturn: errors.New("expected")}
+		client := newTestCircuitBreakingClient(underlyingClient, &CircuitBreakerSettings{ConsecutiveFailures: 1, Cooldown: time.Second}, &now)
+
+		client.Send(context.Background(), request)
+		if client.state != circuitOpen {
+			t.Fatalf("Expected circuit to be [%s], but was [%s]", circuitOpen, client.state)
+		}
+
+		now = now.Add(time.Second)
+		underlyingClient.ErrorToReturn = nil
+		underlyingClient.ResponseToReturn = &pb.TheResponse{Payload: "ok"}
+
+		allowed, probe := client.allowRequest()
+		if !allowed || probe == 0 {
+			t.Fatalf("Expected probe request to be allowed after cool-down")
+		}
+
+		if allowed, _ := client.allowRequest(); allowed {
+			t.Fatalf("Expected only a single probe request while half-open")
+		}
+
+		client.recordResult(probe, true)
+		if client.state != circuitClosed {
+			t.Fatalf("Expected circuit to be [%s], but was [%s]", circuitClosed, client.state)
+		}
+	})
+
+	t.Run("ignores the results of requests other than the probe while half-open", func(t *testing.T) {
+		now := time.Unix(1000, 0)
+		underlyingClient := &MockClient{IDToReturn: "downstream", ResponseToReturn: &pb.TheResponse{}}
+		client := newTestCircuitBreakingClient(underlyingClient, &CircuitBreakerSettings{ConsecutiveFailures: 1, Cooldown: time.Second}, &now)
+
+		_, stale := client.allowRequest()
+		client.recordResult(0, false)
+		now = now.Add(time.Second)
+
+		_, probe := client.allowRequest()
+		client.recordResult(stale, true)
+		if client.state != circuitHalfOpen {
+			t.Fatalf("Expected a request sent before the circuit opened not to close it, but circuit was [%s]", client.state)
+		}
+
+		client.recordResult(probe, false)
+		if client.state != circuitOpen {
+			t.Fatalf("Expected circuit to be [%s] after the probe failed, but was [%s]", circuitOpen, client.state)
+		}
+	})
+
+	t.Run("re-opens if the probe fails", func(t *testing.T) {
+		now := time.Unix(1000, 0)
+		underlyingClient := &MockClient{IDToReturn: "downstream", ErrorToReturn: errors.New("expected")}
+		client := newTestCircuitBreakingClient(underlyingClient, &CircuitBreakerSettings{ConsecutiveFailures: 1, Cooldown: time.Second}, &now)
+
+		client.Send(context.Background(), request)
+		now = now.Add(time.Second)
+		client.Send(context.Background(), request)
+
+		if client.state != circuitOpen {
+			t.Fatalf("Expected circuit to be [%s], but was [%s]", circuitOpen, client.state)
+		}
+	})
+
+	t.Run("doesn't count cancelled requests as failures", func(t *testing.T) {
+		now := time.Unix(1000, 0)
+		underlyingClient := &MockClient{IDToReturn: "downstream", ErrorToReturn: context.Canceled}
+		client := newTestCircuitBreakingClient(underlyingClient, &CircuitBreakerSettings{ConsecutiveFailures: 1, Cooldown: time.Second}, &now)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		client.Send(ctx, request)
+		if client.state != circuitClosed {
+			t.Fatalf("Expected circuit to stay [%s] after a cancelled request, but was [%s]", circuitClosed, client.state)
+		}
+
+		underlyingClient.ErrorToReturn = errors.New("expected")
+		client.Send(context.Background(), request)
+		now = now.Add(time.Second)
+
+		underlyingClient.ErrorToReturn = context.Canceled
+		client.Send(ctx, request)
+		if client.state != circuitHalfOpen {
+			t.Fatalf("Expected circuit to stay [%s] after a cancelled probe, but was [%s]", circuitHalfOpen, client.state)
+		}
+
+		if allowed, _ := client.allowRequest(); !allowed {
+			t.Fatalf("Expected another probe to be let through after the cancelled one")
+		}
+	})
+
+	t.Run("opens when the error rate over the window is too high", func(t *testing.T) {
+		now := time.Unix(1000, 0)
+		underlyingClient := &MockClient{IDToReturn: "downstream", ResponseToReturn: &pb.TheResponse{}}
+		settings := &CircuitBreakerSettings{ErrorPercentage: 50, Window: 10 * time.Second, MinRequests: 4, Cooldown: time.Second}
+		client := newTestCircuitBreakingClient(underlyingClient, settings, &now)
+
+		client.Send(context.Background(), request)
+		client.Send(context.Background(), request)
+
+		underlyingClient.ErrorToReturn = errors.New("expected")
+		client.Send(context.Background(), request)
+		if client.state != circuitClosed {
+			t.Fatalf("Expected circuit to stay [%s] before the minimum number of requests, but was [%s]", circuitClosed, client.state)
+		}
+
+		client.Send(context.Background(), request)
+		if client.state != circuitOpen {
+			t.Fatalf("Expected circuit to be [%s], but was [%s]", circuitOpen, client.state)
+		}
+	})
+
+	t.Run("forgets failures that are outside the window", func(t *testing.T) {
+		now := time.Unix(1000, 0)
+		underlyingClient := &MockClient{IDToReturn: "downstream", ErrorToReturn: errors.New("expected")}
+		settings := &CircuitBreakerSettings{ErrorPercentage: 50, Window: 10 * time.Second, MinRequests: 2, Cooldown: time.Second}
+		client := newTestCircuitBreakingClient(underlyingClient, settings, &now)
+
+		client.Send(context.Background(), request)
+		now = now.Add(20 * time.Second)
+		underlyingClient.ErrorToReturn = nil
+		client.Send(context.Background(), request)
+
+		if client.state != circuitClosed {
+			t.Fatalf("Expected circuit to be [%s], but was [%s]", circuitClosed, client.state)
+		}
+	})
+}
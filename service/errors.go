@@ -0,0 +1,79 @@
+package service
+
+import (
+	"fmt"
+
+	pb "github.com/buoyantio/bb/gen"
+	"google.golang.org/grpc/status"
+)
+
+// HTTPStatusError is returned by HTTP-backed clients when the downstream service replies with a non-2xx status.
+type HTTPStatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *HTTPStatusError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("downstream service returned HTTP status %d", e.StatusCode)
+	}
+	return e.Body
+}
+
+// HopError is the error of a request that failed while hops were recorded, along with the hop recorded for it, so
+// that failed requests can be traced too. Servers reply with the hop, which clients return as part of a HopError.
+type HopError struct {
+	Err error
+	Hop *pb.Hop
+}
+
+func (e *HopError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *HopError) Unwrap() error {
+	return e.Err
+}
+
+// GRPCStatus returns the gRPC status of the error, with the hop as one of its details. Like gRPC servers do, errors
+// without a status are converted to Unknown, or to the code of the context error they wrap.
+func (e *HopError) GRPCStatus() *status.Status {
+	st, ok := status.FromError(e.Err)
+	if !ok {
+		st = status.FromContextError(e.Err)
+	}
+	st = withoutHops(st)
+	if withHop, err := st.WithDetails(e.Hop); err == nil {
+		st = withHop
+	}
+	return st
+}
+
+// HopErrorFromStatus returns a HopError if the gRPC status of err carries a hop, or err otherwise
+func HopErrorFromStatus(err error) error {
+	st, ok := status.FromError(err)
+	if !ok || err == nil {
+		return err
+	}
+
+	for _, detail := range st.Details() {
+		if hop, ok := detail.(*pb.Hop); ok {
+			return &HopError{Err: withoutHops(st).Err(), Hop: hop}
+		}
+	}
+	return err
+}
+
+// withoutHops returns the status supplied without the hops among its details, so that each status carries at most
+// the hop of the service replying with it
+func withoutHops(st *status.Status) *status.Status {
+	p := st.Proto()
+	details := p.Details[:0]
+	for _, detail := range p.Details {
+		if !detail.MessageIs(&pb.Hop{}) {
+			details = append(details, detail)
+		}
+	}
+	p.Details = details
+	return status.FromProto(p)
+}
@@ -0,0 +1,214 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+const (
+	// GRPCFailure fails requests received via gRPC with a status code, e.g.
+	// grpc:code=RESOURCE_EXHAUSTED,retry-after=2s,trailer=x-quota:exceeded,weight=3
+	GRPCFailure = "grpc"
+
+	// HTTPFailure fails requests received via HTTP 1.1 with a status, e.g. http:status=503,retry-after=2s,weight=1
+	HTTPFailure = "http"
+
+	// grpcRetryPushbackTrailer tells gRPC clients how long to wait before retrying, c.f. gRFC A6
+	grpcRetryPushbackTrailer = "grpc-retry-pushback-ms"
+
+	injectedFailureReason = "INJECTED_FAILURE"
+	injectedFailureDomain = "bb.buoyant.io"
+)
+
+// InjectedFailure is the error of a request failed on purpose. Servers reply with its gRPC status, including error
+// details, or HTTP status, along with its metadata as gRPC trailers or HTTP headers.
+type InjectedFailure struct {
+	Protocol   string
+	GRPCCode   codes.Code
+	HTTPStatus int
+	RetryAfter time.Duration
+	Message    string
+	Metadata   map[string]string
+	ServiceID  string
+}
+
+func (e *InjectedFailure) Error() string {
+	return e.Message
+}
+
+// GRPCStatus returns the gRPC status of this failure, which is Unknown unless it is a gRPC failure
+func (e *InjectedFailure) GRPCStatus() *status.Status {
+	code := codes.Unknown
+	if e.Protocol == GRPCFailure {
+		code = e.GRPCCode
+	}
+
+	details := []protoadapt.MessageV1{&errdetails.ErrorInfo{
+		Reason:   injectedFailureReason,
+		Domain:   injectedFailureDomain,
+		Metadata: map[string]string{"service": e.ServiceID},
+	}}
+	if e.RetryAfter > 0 {
+		details = append(details, &errdetails.RetryInfo{RetryDelay: durationpb.New(e.RetryAfter)})
+	}
+
+	st := status.New(code, e.Message)
+	if withDetails, err := st.WithDetails(details...); err == nil {
+		st = withDetails
+	}
+	return st
+}
+
+// Headers returns the HTTP headers or gRPC trailers to reply with, including those telling clients when to retry
+func (e *InjectedFailure) Headers() map[string]string {
+	headers := map[string]string{}
+	for name, value := range e.Metadata {
+		headers[name] = value
+	}
+
+	if e.RetryAfter > 0 {
+		switch e.Protocol {
+		case GRPCFailure:
+			headers[grpcRetryPushbackTrailer] = strconv.FormatInt(e.RetryAfter.Milliseconds(), 10)
+		case HTTPFailure:
+			headers["Retry-After"] = strconv.Itoa(int(math.Ceil(e.RetryAfter.Seconds())))
+		}
+	}
+	return headers
+}
+
+type failureType struct {
+	failure InjectedFailure
+	weight  int
+}
+
+// FailureTypes picks which failure requests failed on purpose fail with, among those of the protocol they were received
+// over, in proportion to their weights
+type FailureTypes struct {
+	byProtocol map[string][]*failureType
+}
+
+// NewFailureTypes parses failure types such as http:status=503,retry-after=2s,weight=3. Every failure type takes an
+// optional weight, retry-after and message. gRPC failures take a code and trailers as trailer=name:value, and HTTP
+// failures take a status and headers as header=name:value, both of which can be repeated.
+func NewFailureTypes(specs []string) (*FailureTypes, error) {
+	failureTypes := &FailureTypes{byProtocol: map[string][]*failureType{}}
+	for _, spec := range specs {
+		failureType, err := newFailureType(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid failure type [%s]: %v", spec, err)
+		}
+		failureTypes.byProtocol[failureType.failure.Protocol] = append(failureTypes.byProtocol[failureType.failure.Protocol], failureType)
+	}
+	return failureTypes, nil
+}
+
+func newFailureType(spec string) (*failureType, error) {
+	protocol, parameters, _ := strings.Cut(strings.TrimSpace(spec), ":")
+	failureType := &failureType{
+		failure: InjectedFailure{Protocol: protocol, Metadata: map[string]string{}},
+		weight:  1,
+	}
+
+	var metadataKey string
+	switch protocol {
+	case GRPCFailure:
+		metadataKey = "trailer"
+	case HTTPFailure:
+		metadataKey = "header"
+	default:
+		return nil, fmt.Errorf("protocol must be [%s] or [%s]", GRPCFailure, HTTPFailure)
+	}
+
+	for _, parameter := range strings.Split(parameters, ",") {
+		if strings.TrimSpace(parameter) == "" {
+			continue
+		}
+		key, value, found := strings.Cut(parameter, "=")
+		if !found {
+			return nil, fmt.Errorf("parameter [%s] must be in the format name=value", parameter)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+
+		var err error
+		switch {
+		case key == "code" && protocol == GRPCFailure:
+			failureType.failure.GRPCCode, err = ParseGRPCCode(value)
+			if err == nil && failureType.failure.GRPCCode == codes.OK {
+				err = fmt.Errorf("code can't be OK")
+			}
+		case key == "status" && protocol == HTTPFailure:
+			failureType.failure.HTTPStatus, err = strconv.Atoi(value)
+			if err == nil && (failureType.failure.HTTPStatus < 400 || failureType.failure.HTTPStatus > 599) {
+				err = fmt.Errorf("status must be between 400 and 599, but was [%d]", failureType.failure.HTTPStatus)
+			}
+		case key == "weight":
+			failureType.weight, err = strconv.Atoi(value)
+			if err == nil && failureType.weight < 1 {
+				err = fmt.Errorf("weight must be positive, but was [%d]", failureType.weight)
+			}
+		case key == "retry-after":
+			failureType.failure.RetryAfter, err = time.ParseDuration(value)
+		case key == "message":
+			failureType.failure.Message = value
+		case key == metadataKey:
+			name, metadataValue, found := strings.Cut(value, ":")
+			if !found || strings.TrimSpace(name) == "" {
+				err = fmt.Errorf("%s [%s] must be in the format name:value", key, value)
+			}
+			failureType.failure.Metadata[strings.TrimSpace(name)] = strings.TrimSpace(metadataValue)
+		default:
+			err = fmt.Errorf("unknown parameter [%s]", key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if protocol == GRPCFailure && failureType.failure.GRPCCode == codes.OK {
+		return nil, fmt.Errorf("parameter [code] is required")
+	}
+	if protocol == HTTPFailure && failureType.failure.HTTPStatus == 0 {
+		return nil, fmt.Errorf("parameter [status] is required")
+	}
+	return failureType, nil
+}
+
+// Pick returns the failure to fail a request received over protocol with, which is a HTTP 500 or gRPC Unknown error
+// when no failure types were set for that protocol
+func (f *FailureTypes) Pick(protocol string, serviceID string) *InjectedFailure {
+	failure := InjectedFailure{Protocol: protocol, GRPCCode: codes.Unknown, HTTPStatus: http.StatusInternalServerError}
+	if f != nil && len(f.byProtocol[protocol]) > 0 {
+		failureTypes := f.byProtocol[protocol]
+		totalWeight := 0
+		for _, failureType := range failureTypes {
+			totalWeight += failureType.weight
+		}
+
+		picked := rand.Intn(totalWeight)
+		for _, failureType := range failureTypes {
+			if picked < failureType.weight {
+				failure = failureType.failure
+				break
+			}
+			picked -= failureType.weight
+		}
+	}
+
+	failure.ServiceID = serviceID
+	if failure.Message == "" {
+		failure.Message = fmt.Sprintf("this error was injected by [%s]", serviceID)
+	}
+	return &failure
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+)
+
+func TestFailureTypes(t *testing.T) {
+	t.Run("picks failure types of the protocol in proportion to their weights", func(t *testing.T) {
+		failureTypes, err := NewFailureTypes([]string{"grpc:code=UNAVAILABLE,weight=3", "grpc:code=deadline-exceeded", "http:status=429"})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		picked := map[codes.Code]int{}
+		for i := 0; i < 4000; i++ {
+			picked[failureTypes.Pick(GRPCFailure, "bb").GRPCCode]++
+		}
+		if len(picked) != 2 || picked[codes.Unavailable] < 2700 || picked[codes.Unavailable] > 3300 {
+			t.Fatalf("Expected around 3000 out of 4000 failures to be UNAVAILABLE, and the others DEADLINE_EXCEEDED, got %v", picked)
+		}
+
+		if failure := failureTypes.Pick(HTTPFailure, "bb"); failure.HTTPStatus != http.StatusTooManyRequests {
+			t.Fatalf("Expected HTTP failures to have status [%d], got [%d]", http.StatusTooManyRequests, failure.HTTPStatus)
+		}
+	})
+
+	t.Run("fails with Unknown or 500 without failure types for the protocol", func(t *testing.T) {
+		failureTypes, err := NewFailureTypes([]string{"grpc:code=UNAVAILABLE"})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		failure := failureTypes.Pick(HTTPFailure, "bb")
+		if failure.HTTPStatus != http.StatusInternalServerError || failure.Error() != "this error was injected by [bb]" {
+			t.Fatalf("Expected a HTTP 500 injected by [bb], got %+v", failure)
+		}
+
+		var noFailureTypes *FailureTypes
+		if code := noFailureTypes.Pick(GRPCFailure, "bb").GRPCStatus().Code(); code != codes.Unknown {
+			t.Fatalf("Expected code [%s], got [%s]", codes.Unknown, code)
+		}
+	})
+
+	t.Run("tells clients when to retry", func(t *testing.T) {
+		failureTypes, err := NewFailureTypes([]string{"grpc:code=RESOURCE_EXHAUSTED,retry-after=1500ms,trailer=x-quota:exceeded", "http:status=503,retry-after=1500ms,header=X-Quota:exceeded"})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		grpcFailure := failureTypes.Pick(GRPCFailure, "bb")
+		expectedTrailers := map[string]string{"x-quota": "exceeded", "grpc-retry-pushback-ms": "1500"}
+		for name, value := range expectedTrailers {
+			if grpcFailure.Headers()[name] != value {
+				t.Fatalf("Expected trailer [%s] to be [%s], got %v", name, value, grpcFailure.Headers())
+			}
+		}
+
+		var retryDelay time.Duration
+		for _, detail := range grpcFailure.GRPCStatus().Details() {
+			if retryInfo, ok := detail.(*errdetails.RetryInfo); ok {
+				retryDelay = retryInfo.RetryDelay.AsDuration()
+			}
+		}
+		if retryDelay != 1500*time.Millisecond {
+			t.Fatalf("Expected status to have retry info with a delay of [1.5s], got %v", grpcFailure.GRPCStatus().Details())
+		}
+
+		httpHeaders := failureTypes.Pick(HTTPFailure, "bb").Headers()
+		if httpHeaders["Retry-After"] != "2" || httpHeaders["X-Quota"] != "exceeded" {
+			t.Fatalf("Expected headers to retry after [2] seconds, got %v", httpHeaders)
+		}
+	})
+
+	t.Run("returns error for invalid failure types", func(t *testing.T) {
+		for _, spec := range []string{
+			"h2:status=503",
+			"grpc:status=503",
+			"grpc:code=OK",
+			"grpc:code=NOT_A_CODE",
+			"http:status=200",
+			"http:status=503,weight=0",
+			"http:status=503,trailer=x:y",
+			"http:status=503,header=x",
+			"http:retry-after=1s",
+		} {
+			_, err := NewFailureTypes([]string{spec})
+			if err == nil {
+				t.Fatalf("Expecting error for [%s], got nothing", spec)
+			}
+		}
+	})
+}
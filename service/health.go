@@ -0,0 +1,96 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// HealthzPath is where HTTP servers report whether this service is alive
+	HealthzPath = "/healthz"
+
+	// ReadyzPath is where HTTP servers report whether this service is ready to receive requests
+	ReadyzPath = "/readyz"
+
+	// terminateAfterUnreadyRatio is the share of terminate-after requests handled after which this service stops being
+	// ready, so that it is taken out of rotation before it actually stops
+	terminateAfterUnreadyRatio = 0.9
+)
+
+// Connectable is implemented by clients that hold a connection to their downstream service
+type Connectable interface {
+	Connected() bool
+}
+
+// Health tracks whether a service is alive and whether it is ready to receive requests
+type Health struct {
+	handler     *RequestHandler
+	draining    atomic.Bool
+	mu          sync.RWMutex
+	downstreams []Client
+}
+
+// SetDownstreams sets the clients whose connection to their downstream service is required to be ready
+func (h *Health) SetDownstreams(clients []Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.downstreams = clients
+}
+
+// Drain makes this service unready, as it is about to stop
+func (h *Health) Drain() {
+	h.draining.Store(true)
+}
+
+// Live returns an error explaining why this service isn't alive, if it isn't
+func (h *Health) Live() error {
+	if h == nil {
+		return nil
+	}
+
+	config := h.handler.Config()
+	if config.Unhealthy {
+		return fmt.Errorf("[%s] was set to be unhealthy", config.ID)
+	}
+	return nil
+}
+
+// Ready returns an error explaining why this service isn't ready to receive requests, if it isn't
+func (h *Health) Ready() error {
+	if h == nil {
+		return nil
+	}
+
+	if err := h.Live(); err != nil {
+		return err
+	}
+
+	config := h.handler.Config()
+	if h.draining.Load() {
+		return fmt.Errorf("[%s] is shutting down", config.ID)
+	}
+
+	select {
+	case <-h.handler.Stopping():
+		return fmt.Errorf("[%s] is stopping", config.ID)
+	default:
+	}
+
+	if config.TerminateAfter != 0 {
+		unreadyAfter := int64(math.Ceil(float64(config.TerminateAfter) * terminateAfterUnreadyRatio))
+		if count := h.handler.requestCount.Load(); count >= unreadyAfter {
+			return fmt.Errorf("[%s] handled %d requests and terminates after %d", config.ID, count, config.TerminateAfter)
+		}
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for _, client := range h.downstreams {
+		if connectable, ok := client.(Connectable); ok && !connectable.Connected() {
+			return fmt.Errorf("[%s] isn't connected to downstream service [%s]", config.ID, client.GetID())
+		}
+	}
+	return nil
+}
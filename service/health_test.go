@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	pb "github.com/buoyantio/bb/gen"
+)
+
+type stubConnectableClient struct {
+	MockClient
+	connected bool
+}
+
+func (c *stubConnectableClient) Connected() bool { return c.connected }
+
+func TestHealth(t *testing.T) {
+	t.Run("is alive and ready by default", func(t *testing.T) {
+		handler := NewRequestHandler(&Config{})
+		handler.Health().SetDownstreams([]Client{&MockClient{}, &stubConnectableClient{connected: true}})
+
+		if err := handler.Health().Live(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if err := handler.Health().Ready(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("is neither alive nor ready when set to be unhealthy", func(t *testing.T) {
+		handler := NewRequestHandler(&Config{})
+		unhealthy := true
+		config, err := (&RuntimeConfig{Unhealthy: &unhealthy}).Apply(handler.Config())
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		handler.Reconfigure(config, handler.CurrentStrategy())
+
+		if handler.Health().Live() == nil || handler.Health().Ready() == nil {
+			t.Fatalf("Expected service to be neither alive nor ready")
+		}
+	})
+
+	t.Run("isn't ready while draining or stopping", func(t *testing.T) {
+		draining := NewRequestHandler(&Config{})
+		draining.Health().Drain()
+
+		if draining.Health().Ready() == nil {
+			t.Fatalf("Expected draining service not to be ready")
+		}
+
+		if err := draining.Health().Live(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		stopping := NewRequestHandler(&Config{TerminateAfter: 1})
+		stopping.Strategy = &MockStrategy{ResponseToReturn: &pb.TheResponse{}}
+		stopping.Handle(context.TODO(), &pb.TheRequest{})
+
+		if stopping.Health().Ready() == nil {
+			t.Fatalf("Expected stopping service not to be ready")
+		}
+	})
+
+	t.Run("isn't ready once terminate-after is approaching", func(t *testing.T) {
+		handler := NewRequestHandler(&Config{TerminateAfter: 10})
+		handler.Strategy = &MockStrategy{ResponseToReturn: &pb.TheResponse{}}
+
+		for i := 0; i < 8; i++ {
+			handler.Handle(context.TODO(), &pb.TheRequest{})
+		}
+		if err := handler.Health().Ready(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		handler.Handle(context.TODO(), &pb.TheRequest{})
+		if handler.Health().Ready() == nil {
+			t.Fatalf("Expected service not to be ready after 9 out of 10 requests")
+		}
+	})
+
+	t.Run("isn't ready while a downstream service isn't connected", func(t *testing.T) {
+		handler := NewRequestHandler(&Config{})
+		handler.Health().SetDownstreams([]Client{&stubConnectableClient{connected: false}})
+
+		if handler.Health().Ready() == nil {
+			t.Fatalf("Expected service not to be ready")
+		}
+	})
+
+	t.Run("is alive and ready when not tracked", func(t *testing.T) {
+		var health *Health
+		if health.Live() != nil || health.Ready() != nil {
+			t.Fatalf("Expected untracked service to be alive and ready")
+		}
+	})
+}
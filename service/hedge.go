@@ -0,0 +1,230 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	pb "github.com/buoyantio/bb/gen"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// hedgeLatencySamples is how many of the most recent latencies are used to learn the hedging delay
+	hedgeLatencySamples = 1000
+
+	// hedgeMinLatencySamples is how many latencies must be observed before the learned hedging delay is used
+	hedgeMinLatencySamples = 100
+)
+
+// HedgePolicy configures when a hedged Client sends duplicates of a request to other downstream services.
+type HedgePolicy struct {
+	// Delay is how long to wait for a response before sending a duplicate request. When Percentile is set, it is only
+	// used until enough latencies have been observed.
+	Delay time.Duration
+
+	// Percentile, if greater than zero, makes the delay be learned as this percentile of the observed latencies.
+	Percentile float64
+
+	// MaxAttempts is the maximum number of downstream services a single request is sent to.
+	MaxAttempts int
+}
+
+// Hedging reports whether requests to downstream services are hedged
+func (c *Config) Hedging() bool {
+	return c.HedgeDelay > 0 || c.HedgePercentile > 0
+}
+
+// NewHedgePolicy creates a HedgePolicy from the hedging settings in the Config.
+func NewHedgePolicy(config *Config) (*HedgePolicy, error) {
+	if config.HedgeDelay < 0 || config.HedgePercentile < 0 || config.HedgePercentile >= 100 {
+		return nil, fmt.Errorf("hedge delay [%v] must be positive and hedge percentile [%f] must be between 0 and 100", config.HedgeDelay, config.HedgePercentile)
+	}
+
+	// without a delay to start with, every request would be hedged straight away until the percentile is learned
+	if config.HedgePercentile > 0 && config.HedgeDelay == 0 {
+		return nil, fmt.Errorf("hedge percentile [%f] requires a hedge delay to use until enough latencies were observed", config.HedgePercentile)
+	}
+
+	if config.HedgeMaxAttempts < 2 {
+		return nil, fmt.Errorf("hedging requires at least 2 attempts per request, but was configured with [%d]", config.HedgeMaxAttempts)
+	}
+
+	return &HedgePolicy{
+		Delay:       config.HedgeDelay,
+		Percentile:  config.HedgePercentile,
+		MaxAttempts: config.HedgeMaxAttempts,
+	}, nil
+}
+
+// latencyTracker keeps the most recent latencies observed, so that percentiles can be computed over them.
+type latencyTracker struct {
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int
+	total   int
+}
+
+func (l *latencyTracker) observe(latency time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.samples) < hedgeLatencySamples {
+		l.samples = append(l.samples, latency)
+	} else {
+		l.samples[l.next] = latency
+	}
+	l.next = (l.next + 1) % hedgeLatencySamples
+	l.total++
+}
+
+// percentile returns the requested percentile of the observed latencies, and false if not enough were observed yet.
+func (l *latencyTracker) percentile(p float64) (time.Duration, bool) {
+	l.mu.Lock()
+	if l.total < hedgeMinLatencySamples {
+		l.mu.Unlock()
+		return 0, false
+	}
+	sorted := make([]time.Duration, len(l.samples))
+	copy(sorted, l.samples)
+	l.mu.Unlock()
+
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	index := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if index < 0 {
+		index = 0
+	}
+	return sorted[index], true
+}
+
+type hedgeResult struct {
+	client  Client
+	primary bool
+	latency time.Duration
+	resp    *pb.TheResponse
+	err     error
+}
+
+type hedgedClient struct {
+	clients   []Client
+	policy    *HedgePolicy
+	latencies *latencyTracker
+	next      uint64
+}
+
+func (h *hedgedClient) Close() error {
+	errors := make([]string, 0)
+	for _, c := range h.clients {
+		if err := c.Close(); err != nil {
+			errors = append(errors, err.Error())
+		}
+	}
+
+	if len(errors) > 0 {
+		return fmt.Errorf("errors found closing hedged clients: %s", strings.Join(errors, ","))
+	}
+	return nil
+}
+
+func (h *hedgedClient) GetID() string {
+	ids := make([]string, 0)
+	for _, c := range h.clients {
+		ids = append(ids, c.GetID())
+	}
+	return fmt.Sprintf("hedged(%s)", strings.Join(ids, ","))
+}
+
+func (h *hedgedClient) hedgeDelay() time.Duration {
+	if h.policy.Percentile > 0 {
+		if learned, ok := h.latencies.percentile(h.policy.Percentile); ok {
+			return learned
+		}
+	}
+	return h.policy.Delay
+}
+
+func (h *hedgedClient) Send(ctx context.Context, req *pb.TheRequest) (*pb.TheResponse, error) {
+	// cancelling the context once Send returns cancels any requests still in flight
+	hedgeCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	start := time.Now()
+	first := atomic.AddUint64(&h.next, 1) - 1
+	results := make(chan hedgeResult, h.policy.MaxAttempts)
+	attemptsMade := 0
+	send := func() {
+		c := h.clients[(first+uint64(attemptsMade))%uint64(len(h.clients))]
+		primary := attemptsMade == 0
+		attemptsMade++
+		go func() {
+			attemptStart := time.Now()
+			resp, err := c.Send(hedgeCtx, req)
+			results <- hedgeResult{client: c, primary: primary, latency: time.Since(attemptStart), resp: resp, err: err}
+		}()
+	}
+
+	// the delay is learned from the latencies of primary attempts alone, as those of the requests hedged would pull
+	// it down, making more requests hedged. Primary attempts losing the race are recorded as taking as long as it
+	// took to lose it, which is never less than the delay.
+	primaryDone := false
+	defer func() {
+		if !primaryDone {
+			h.latencies.observe(time.Since(start))
+		}
+	}()
+
+	send()
+	delay := h.hedgeDelay()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var lastErr error
+	pending := 1
+	for {
+		select {
+		case <-timer.C:
+			if attemptsMade < h.policy.MaxAttempts {
+				log.Infof("No response for request UID [%s] after [%v], sending hedged request (attempt %d of %d)", req.RequestUID, delay, attemptsMade+1, h.policy.MaxAttempts)
+				send()
+				pending++
+				timer.Reset(delay)
+			}
+		case result := <-results:
+			pending--
+			if result.primary {
+				primaryDone = true
+				if result.err == nil {
+					h.latencies.observe(result.latency)
+				}
+			}
+			if result.err == nil {
+				log.Debugf("Hedged request UID [%s] answered by [%s] after [%d] attempts", req.RequestUID, result.client.GetID(), attemptsMade)
+				return result.resp, nil
+			}
+
+			log.Infof("Hedged request UID [%s] to [%s] failed: %v", req.RequestUID, result.client.GetID(), result.err)
+			lastErr = result.err
+			if attemptsMade < h.policy.MaxAttempts && ctx.Err() == nil {
+				send()
+				pending++
+			} else if pending == 0 {
+				return nil, lastErr
+			}
+		}
+	}
+}
+
+// MakeHedged creates a new Client that sends each request to one of the clients supplied and, if it hasn't responded
+// within the policy's delay, sends duplicates to the others, returning the first successful response.
+func MakeHedged(clients []Client, policy *HedgePolicy) Client {
+	return &hedgedClient{
+		clients:   clients,
+		policy:    policy,
+		latencies: &latencyTracker{},
+	}
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	pb "github.com/buoyantio/bb/gen"
+)
+
+func TestHedgedClient(t *testing.T) {
+	t.Run("sends a duplicate request when the first one is slow and returns the first response", func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+
+		slowClient := &MockClient{
+			IDToReturn:         "slow",
+			ResponseToReturn:   &pb.TheResponse{Payload: "slow"},
+			RequestInterceptor: func(req *pb.TheRequest) { <-release },
+		}
+		fastClient := &MockClient{IDToReturn: "fast", ResponseToReturn: &pb.TheResponse{Payload: "fast"}}
+
+		client := MakeHedged([]Client{slowClient, fastClient}, &HedgePolicy{Delay: 10 * time.Millisecond, MaxAttempts: 2})
+		resp, err := client.Send(context.Background(), &pb.TheRequest{RequestUID: "123"})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if resp.Payload != "fast" {
+			t.Fatalf("Expected response from [fast], but got [%s]", resp.Payload)
+		}
+	})
+
+	t.Run("learns from the latency of the first attempt, even when it loses the race", func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+
+		slowClient := &MockClient{
+			IDToReturn:         "slow",
+			ResponseToReturn:   &pb.TheResponse{Payload: "slow"},
+			RequestInterceptor: func(req *pb.TheRequest) { <-release },
+		}
+		fastClient := &MockClient{IDToReturn: "fast", ResponseToReturn: &pb.TheResponse{Payload: "fast"}}
+
+		client := MakeHedged([]Client{slowClient, fastClient}, &HedgePolicy{Delay: 20 * time.Millisecond, MaxAttempts: 2}).(*hedgedClient)
+		if _, err := client.Send(context.Background(), &pb.TheRequest{RequestUID: "123"}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		// the second request goes to [fast] first, which answers straight away
+		if _, err := client.Send(context.Background(), &pb.TheRequest{RequestUID: "456"}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		samples := client.latencies.samples
+		if len(samples) != 2 || samples[0] < 20*time.Millisecond || samples[1] >= 20*time.Millisecond {
+			t.Fatalf("Expected the first attempt losing the race to be recorded as taking at least the delay, and the next one as fast, got %v", samples)
+		}
+	})
+
+	t.Run("does not send duplicates when the first response arrives in time", func(t *testing.T) {
+		firstClient := &MockClient{IDToReturn: "first", ResponseToReturn: &pb.TheResponse{Payload: "first"}}
+		secondClient := &MockClient{IDToReturn: "second", ResponseToReturn: &pb.TheResponse{Payload: "second"}}
+
+		client := MakeHedged([]Client{firstClient, secondClient}, &HedgePolicy{Delay: time.Minute, MaxAttempts: 2})
+		resp, err := client.Send(context.Background(), &pb.TheRequest{RequestUID: "123"})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if resp.Payload != "first" {
+			t.Fatalf("Expected response from [first], but got [%s]", resp.Payload)
+		}
+
+		if secondClient.RequestReceived != nil {
+			t.Fatalf("Expected no request to be sent to [second], but got [%v]", secondClient.RequestReceived)
+		}
+	})
+
+	t.Run("returns an error when all attempts fail", func(t *testing.T) {
+		expectedError := errors.New("expected")
+		client1 := &MockClient{IDToReturn: "1", ErrorToReturn: expectedError}
+		client2 := &MockClient{IDToReturn: "2", ErrorToReturn: expectedError}
+
+		client := MakeHedged([]Client{client1, client2}, &HedgePolicy{Delay: time.Minute, MaxAttempts: 2})
+		_, err := client.Send(context.Background(), &pb.TheRequest{RequestUID: "123"})
+		if err != expectedError {
+			t.Fatalf("Expected error [%v], but got [%v]", expectedError, err)
+		}
+
+		if client1.RequestReceived == nil || client2.RequestReceived == nil {
+			t.Fatalf("Expected both clients to be tried after failures")
+		}
+	})
+
+	t.Run("requires a delay to start with when learning it from a percentile", func(t *testing.T) {
+		_, err := NewHedgePolicy(&Config{HedgePercentile: 95, HedgeMaxAttempts: 2})
+		if err == nil {
+			t.Fatalf("Expecting error, got nothing")
+		}
+
+		policy, err := NewHedgePolicy(&Config{HedgeDelay: time.Millisecond * 50, HedgePercentile: 95, HedgeMaxAttempts: 2})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if policy.Delay != time.Millisecond*50 || policy.Percentile != 95 {
+			t.Fatalf("Expected policy to start with the delay and learn the percentile, got %+v", policy)
+		}
+	})
+
+	t.Run("learns the hedging delay from observed latencies", func(t *testing.T) {
+		tracker := &latencyTracker{}
+		if _, ok := tracker.percentile(99); ok {
+			t.Fatalf("Expected no percentile before enough latencies were observed")
+		}
+
+		for i := 1; i <= hedgeMinLatencySamples; i++ {
+			tracker.observe(time.Duration(i) * time.Millisecond)
+		}
+
+		p90, ok := tracker.percentile(90)
+		if !ok {
+			t.Fatalf("Expected percentile once enough latencies were observed")
+		}
+
+		if p90 != 90*time.Millisecond {
+			t.Fatalf("Expected p90 to be [90ms], but got [%v]", p90)
+		}
+	})
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	pb "github.com/buoyantio/bb/gen"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type hopRecorderKey struct{}
+
+// hopRecorder collects the faults injected into a request, and the hops of the downstream requests made while
+// handling it.
+type hopRecorder struct {
+	mu             sync.Mutex
+	injectedFaults []string
+	downstreamHops []*pb.Hop
+}
+
+func (r *hopRecorder) recordFault(fault string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.injectedFaults = append(r.injectedFaults, fault)
+}
+
+func (r *hopRecorder) recordDownstream(hops ...*pb.Hop) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.downstreamHops = append(r.downstreamHops, hops...)
+}
+
+// newHop returns the hop of this service, as recorded so far
+func (r *hopRecorder) newHop(ctx context.Context, config *Config, h *RequestHandler, start time.Time) *pb.Hop {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	hop := &pb.Hop{
+		ServiceID:       config.ID,
+		Strategy:        h.StrategyName,
+		LatencyInMillis: millisSince(start),
+		InjectedFaults:  append([]string{}, r.injectedFaults...),
+		DownstreamHops:  append([]*pb.Hop{}, r.downstreamHops...),
+	}
+	if inbound, ok := InboundRequestFromContext(ctx); ok {
+		hop.Protocol = inbound.Protocol
+	}
+	return hop
+}
+
+func hopRecorderFromContext(ctx context.Context) (*hopRecorder, bool) {
+	recorder, ok := ctx.Value(hopRecorderKey{}).(*hopRecorder)
+	return recorder, ok
+}
+
+// recordInjectedFault adds the fault injected into the request being handled to its span and hop
+func recordInjectedFault(ctx context.Context, fault string) {
+	trace.SpanFromContext(ctx).AddEvent("injected " + fault)
+	if recorder, ok := hopRecorderFromContext(ctx); ok {
+		recorder.recordFault(fault)
+	}
+}
+
+func millisSince(start time.Time) float64 {
+	return float64(time.Since(start)) / float64(time.Millisecond)
+}
+
+type hopRecordingClient struct {
+	underlyingClient Client
+}
+
+func (c *hopRecordingClient) Close() error { return c.underlyingClient.Close() }
+
+func (c *hopRecordingClient) GetID() string { return c.underlyingClient.GetID() }
+
+func (c *hopRecordingClient) Send(ctx context.Context, req *pb.TheRequest) (*pb.TheResponse, error) {
+	start := time.Now()
+	resp, err := c.underlyingClient.Send(ctx, req)
+
+	recorder, ok := hopRecorderFromContext(ctx)
+	if !ok {
+		return resp, err
+	}
+
+	var hopErr *HopError
+	switch {
+	case errors.As(err, &hopErr):
+		// the downstream service failed the request, but still replied with its hop
+		hopErr.Hop.ClientID = c.GetID()
+		hopErr.Hop.Error = err.Error()
+		recorder.recordDownstream(hopErr.Hop)
+		return resp, hopErr.Err
+	case err != nil:
+		recorder.recordDownstream(&pb.Hop{ClientID: c.GetID(), LatencyInMillis: millisSince(start), Error: err.Error()})
+	case len(resp.GetHops()) == 0:
+		// the downstream service doesn't record hops, so all that is known is how long it took to respond
+		recorder.recordDownstream(&pb.Hop{ClientID: c.GetID(), LatencyInMillis: millisSince(start)})
+	default:
+		for _, hop := range resp.Hops {
+			hop.ClientID = c.GetID()
+		}
+		recorder.recordDownstream(resp.Hops...)
+	}
+
+	return resp, err
+}
+
+// MakeHopRecording creates a new Client that records the hops of the requests it sends, as part of the hop of the
+// request being handled.
+func MakeHopRecording(client Client) Client {
+	return &hopRecordingClient{underlyingClient: client}
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	pb "github.com/buoyantio/bb/gen"
+)
+
+type fanOutStrategy struct {
+	clients []Client
+}
+
+func (s *fanOutStrategy) Do(ctx context.Context, req *pb.TheRequest) (*pb.TheResponse, error) {
+	for _, c := range s.clients {
+		c.Send(ctx, req)
+	}
+	return &pb.TheResponse{Payload: "fan-out"}, nil
+}
+
+// relayStrategy sends requests to a single client, failing them as the client does
+type relayStrategy struct {
+	client    Client
+	errorSeen error
+}
+
+func (s *relayStrategy) Do(ctx context.Context, req *pb.TheRequest) (*pb.TheResponse, error) {
+	resp, err := s.client.Send(ctx, req)
+	s.errorSeen = err
+	return resp, err
+}
+
+func TestHopRecording(t *testing.T) {
+	t.Run("adds the hop of this service, nesting the hops of downstream requests", func(t *testing.T) {
+		downstreamHop := &pb.Hop{ServiceID: "terminus", Strategy: "terminus", Protocol: "grpc", LatencyInMillis: 1}
+		recording := &MockClient{IDToReturn: "recording:9090", ResponseToReturn: &pb.TheResponse{Hops: []*pb.Hop{downstreamHop}}}
+		notRecording := &MockClient{IDToReturn: "not-recording:9091", ResponseToReturn: &pb.TheResponse{}}
+		failing := &MockClient{IDToReturn: "failing:9092", ErrorToReturn: errors.New("expected")}
+
+		handler := RequestHandler{
+			config:       &Config{ID: "expected-id", SleepInMillis: 1, RecordHops: true},
+			StrategyName: "expected-strategy",
+			Strategy: &fanOutStrategy{clients: []Client{
+				MakeHopRecording(recording),
+				MakeHopRecording(notRecording),
+				MakeHopRecording(failing),
+			}},
+		}
+
+		inbound := NewInboundRequest("http", "GET", "/", nil)
+		resp, err := handler.Handle(WithInboundRequest(context.TODO(), inbound), &pb.TheRequest{RequestUID: "123"})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if len(resp.Hops) != 1 {
+			t.Fatalf("Expected response to carry a single hop, but got %v", resp.Hops)
+		}
+
+		hop := resp.Hops[0]
+		if hop.ServiceID != "expected-id" || hop.Strategy != "expected-strategy" || hop.Protocol != "http" || hop.LatencyInMillis < 1 {
+			t.Fatalf("Expected hop to describe this service, but got %v", hop)
+		}
+
+		if len(hop.InjectedFaults) != 1 || hop.InjectedFaults[0] != "latency=1ms" {
+			t.Fatalf("Expected hop to record injected latency, but got %v", hop.InjectedFaults)
+		}
+
+		if len(hop.DownstreamHops) != 3 {
+			t.Fatalf("Expected [3] downstream hops, but got %v", hop.DownstreamHops)
+		}
+
+		if hop.DownstreamHops[0] != downstreamHop || downstreamHop.ClientID != "recording:9090" {
+			t.Fatalf("Expected downstream hop to be nested and reached via [recording:9090], but got %v", hop.DownstreamHops[0])
+		}
+
+		if hop.DownstreamHops[1].ClientID != "not-recording:9091" || hop.DownstreamHops[1].ServiceID != "" {
+			t.Fatalf("Expected a hop for [not-recording:9091] with only the client ID, but got %v", hop.DownstreamHops[1])
+		}
+
+		if hop.DownstreamHops[2].ClientID != "failing:9092" || hop.DownstreamHops[2].Error != "expected" {
+			t.Fatalf("Expected a hop for [failing:9092] with its error, but got %v", hop.DownstreamHops[2])
+		}
+	})
+
+	t.Run("returns the hop of a failed request along with its error", func(t *testing.T) {
+		downstreamHop := &pb.Hop{ServiceID: "terminus", InjectedFaults: []string{"failure"}}
+		failing := &MockClient{IDToReturn: "failing:9090", ErrorToReturn: &HopError{Err: errors.New("expected"), Hop: downstreamHop}}
+
+		strategy := &relayStrategy{client: MakeHopRecording(failing)}
+		handler := RequestHandler{
+			config:       &Config{ID: "expected-id", RecordHops: true},
+			StrategyName: "expected-strategy",
+			Strategy:     strategy,
+		}
+
+		_, err := handler.Handle(context.TODO(), &pb.TheRequest{RequestUID: "123"})
+		var hopErr *HopError
+		if !errors.As(err, &hopErr) || err.Error() != "expected" {
+			t.Fatalf("Expected error carrying the hop of this service, but got %v", err)
+		}
+
+		if _, isHopErr := strategy.errorSeen.(*HopError); isHopErr {
+			t.Fatalf("Expected the strategy to see the downstream error without its hop, but got %#v", strategy.errorSeen)
+		}
+
+		hop := hopErr.Hop
+		if hop.ServiceID != "expected-id" || hop.Strategy != "expected-strategy" || len(hop.DownstreamHops) != 1 {
+			t.Fatalf("Expected hop to describe this service and its downstream request, but got %v", hop)
+		}
+
+		if hop.DownstreamHops[0] != downstreamHop || downstreamHop.ClientID != "failing:9090" || downstreamHop.Error != "expected" {
+			t.Fatalf("Expected the hop of the failed downstream request to be nested, with its error, but got %v", hop.DownstreamHops[0])
+		}
+	})
+
+	t.Run("adds no hops unless configured to", func(t *testing.T) {
+		client := &MockClient{IDToReturn: "recording:9090", ResponseToReturn: &pb.TheResponse{}}
+		handler := RequestHandler{
+			config:   &Config{},
+			Strategy: &fanOutStrategy{clients: []Client{MakeHopRecording(client)}},
+		}
+
+		resp, err := handler.Handle(context.TODO(), &pb.TheRequest{RequestUID: "123"})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if len(resp.Hops) != 0 {
+			t.Fatalf("Expected no hops, but got %v", resp.Hops)
+		}
+	})
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type inboundRequestKey struct{}
+
+// InboundRequest describes the request received by a Server, so that strategies and clients can act on attributes
+// that aren't part of TheRequest.
+type InboundRequest struct {
+	// Protocol is the protocol the request was received over, either http or grpc
+	Protocol string
+
+	// Method is the HTTP method, or the full gRPC method name
+	Method string
+
+	// Path is the HTTP path, or the full gRPC method name
+	Path string
+
+	// Headers holds the HTTP headers or gRPC metadata, keyed by their lower-case names
+	Headers map[string][]string
+}
+
+// Header returns all values of the header or metadata key supplied, regardless of its case
+func (r *InboundRequest) Header(name string) []string {
+	return r.Headers[strings.ToLower(name)]
+}
+
+// NewInboundRequest creates an InboundRequest, normalising header names to lower-case
+func NewInboundRequest(protocol string, method string, path string, headers map[string][]string) *InboundRequest {
+	normalisedHeaders := map[string][]string{}
+	for name, values := range headers {
+		key := strings.ToLower(name)
+		normalisedHeaders[key] = append(normalisedHeaders[key], values...)
+	}
+
+	return &InboundRequest{
+		Protocol: protocol,
+		Method:   method,
+		Path:     path,
+		Headers:  normalisedHeaders,
+	}
+}
+
+// WithInboundRequest returns a copy of the context carrying the InboundRequest
+func WithInboundRequest(ctx context.Context, req *InboundRequest) context.Context {
+	return context.WithValue(ctx, inboundRequestKey{}, req)
+}
+
+// InboundRequestFromContext returns the InboundRequest carried by the context, if any
+func InboundRequestFromContext(ctx context.Context) (*InboundRequest, bool) {
+	req, ok := ctx.Value(inboundRequestKey{}).(*InboundRequest)
+	return req, ok
+}
+
+// headersNeverPropagated lists headers describing a single connection or message, which protocol clients set
+// themselves and must never be copied from the inbound request.
+var headersNeverPropagated = map[string]bool{
+	"accept-encoding":   true,
+	"connection":        true,
+	"content-length":    true,
+	"content-type":      true,
+	"host":              true,
+	"keep-alive":        true,
+	"proxy-connection":  true,
+	"te":                true,
+	"trailer":           true,
+	"transfer-encoding": true,
+	"upgrade":           true,
+	"user-agent":        true,
+}
+
+// HeaderPropagation decides which headers and metadata of the inbound request are copied onto downstream requests.
+type HeaderPropagation struct {
+	allowList map[string]bool
+	all       bool
+}
+
+// NewHeaderPropagation creates a HeaderPropagation from the propagation settings in the Config, or nil if nothing
+// should be propagated.
+func NewHeaderPropagation(config *Config) *HeaderPropagation {
+	if !config.PropagateAllHeaders && len(config.PropagateHeaders) == 0 {
+		return nil
+	}
+
+	allowList := map[string]bool{}
+	for _, name := range config.PropagateHeaders {
+		allowList[strings.ToLower(strings.TrimSpace(name))] = true
+	}
+
+	return &HeaderPropagation{
+		allowList: allowList,
+		all:       config.PropagateAllHeaders,
+	}
+}
+
+func (p *HeaderPropagation) shouldPropagate(name string) bool {
+	if headersNeverPropagated[name] || strings.HasPrefix(name, ":") || strings.HasPrefix(name, "grpc-") {
+		return false
+	}
+	return p.all || p.allowList[name]
+}
+
+// HeadersFromContext returns the headers of the InboundRequest carried by the context that should be propagated to a
+// downstream service using the protocol supplied, keyed by their lower-case names. Binary gRPC metadata, whose keys
+// end in -bin, is base64-encoded when sent over HTTP and decoded when received over HTTP and sent over gRPC.
+func (p *HeaderPropagation) HeadersFromContext(ctx context.Context, protocol string) map[string][]string {
+	inbound, ok := InboundRequestFromContext(ctx)
+	if p == nil || !ok {
+		return nil
+	}
+
+	headers := map[string][]string{}
+	for name, values := range inbound.Headers {
+		if !p.shouldPropagate(name) {
+			continue
+		}
+
+		isBinary := strings.HasSuffix(name, "-bin")
+		for _, value := range values {
+			switch {
+			case isBinary && inbound.Protocol == "grpc" && protocol == "http":
+				value = base64.StdEncoding.EncodeToString([]byte(value))
+			case isBinary && inbound.Protocol == "http" && protocol == "grpc":
+				decoded, err := base64.StdEncoding.DecodeString(value)
+				if err != nil {
+					log.Warnf("Not propagating binary header [%s], its value isn't base64-encoded: %v", name, err)
+					continue
+				}
+				value = string(decoded)
+			}
+			headers[name] = append(headers[name], value)
+		}
+	}
+
+	return headers
+}
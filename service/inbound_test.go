@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"reflect"
+	"testing"
+)
+
+func TestHeaderPropagation(t *testing.T) {
+	inboundHeaders := map[string][]string{
+		"Traceparent":       {"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"},
+		"L5d-Ctx-Deadline":  {"123"},
+		"X-Tenant":          {"gold", "silver"},
+		"Content-Type":      {"application/json"},
+		"Content-Length":    {"42"},
+		"Connection":        {"keep-alive"},
+		"Accept-Encoding":   {"gzip"},
+		":authority":        {"localhost:9090"},
+		"grpc-timeout":      {"1S"},
+		"Transfer-Encoding": {"chunked"},
+	}
+
+	t.Run("propagates nothing unless configured to", func(t *testing.T) {
+		propagation := NewHeaderPropagation(&Config{})
+		if propagation != nil {
+			t.Fatalf("Expected no header propagation, but got %+v", propagation)
+		}
+
+		ctx := WithInboundRequest(context.TODO(), NewInboundRequest("http", "POST", "/", inboundHeaders))
+		if headers := propagation.HeadersFromContext(ctx, "http"); len(headers) != 0 {
+			t.Fatalf("Expected no headers to be propagated, but got %v", headers)
+		}
+	})
+
+	t.Run("propagates only headers in the allow-list", func(t *testing.T) {
+		propagation := NewHeaderPropagation(&Config{PropagateHeaders: []string{"traceparent", "X-Tenant", "content-type"}})
+		ctx := WithInboundRequest(context.TODO(), NewInboundRequest("http", "POST", "/", inboundHeaders))
+
+		expected := map[string][]string{
+			"traceparent": {"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"},
+			"x-tenant":    {"gold", "silver"},
+		}
+		if headers := propagation.HeadersFromContext(ctx, "grpc"); !reflect.DeepEqual(expected, headers) {
+			t.Fatalf("Expected headers %v to be propagated, but got %v", expected, headers)
+		}
+	})
+
+	t.Run("propagates all headers except those describing the connection", func(t *testing.T) {
+		propagation := NewHeaderPropagation(&Config{PropagateAllHeaders: true})
+		ctx := WithInboundRequest(context.TODO(), NewInboundRequest("grpc", "/m", "/m", inboundHeaders))
+
+		expected := map[string][]string{
+			"traceparent":      {"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"},
+			"l5d-ctx-deadline": {"123"},
+			"x-tenant":         {"gold", "silver"},
+		}
+		if headers := propagation.HeadersFromContext(ctx, "http"); !reflect.DeepEqual(expected, headers) {
+			t.Fatalf("Expected headers %v to be propagated, but got %v", expected, headers)
+		}
+	})
+
+	t.Run("propagates nothing if there is no inbound request", func(t *testing.T) {
+		propagation := NewHeaderPropagation(&Config{PropagateAllHeaders: true})
+		if headers := propagation.HeadersFromContext(context.TODO(), "http"); len(headers) != 0 {
+			t.Fatalf("Expected no headers to be propagated, but got %v", headers)
+		}
+	})
+
+	t.Run("converts binary metadata between protocols", func(t *testing.T) {
+		propagation := NewHeaderPropagation(&Config{PropagateHeaders: []string{"x-token-bin"}})
+		raw := string([]byte{0, 1, 2, 255})
+		encoded := base64.StdEncoding.EncodeToString([]byte(raw))
+
+		fromGrpc := WithInboundRequest(context.TODO(), NewInboundRequest("grpc", "/m", "/m", map[string][]string{"x-token-bin": {raw}}))
+		if headers := propagation.HeadersFromContext(fromGrpc, "http"); headers["x-token-bin"][0] != encoded {
+			t.Fatalf("Expected binary metadata to be base64-encoded as [%s] when sent over HTTP, but got %v", encoded, headers)
+		}
+		if headers := propagation.HeadersFromContext(fromGrpc, "grpc"); headers["x-token-bin"][0] != raw {
+			t.Fatalf("Expected binary metadata to be sent as-is over gRPC, but got %v", headers)
+		}
+
+		fromHTTP := WithInboundRequest(context.TODO(), NewInboundRequest("http", "POST", "/", map[string][]string{"X-Token-Bin": {encoded}}))
+		if headers := propagation.HeadersFromContext(fromHTTP, "grpc"); headers["x-token-bin"][0] != raw {
+			t.Fatalf("Expected binary header to be base64-decoded when sent over gRPC, but got %v", headers)
+		}
+
+		invalid := WithInboundRequest(context.TODO(), NewInboundRequest("http", "POST", "/", map[string][]string{"X-Token-Bin": {"not base64!"}}))
+		if headers := propagation.HeadersFromContext(invalid, "grpc"); len(headers["x-token-bin"]) != 0 {
+			t.Fatalf("Expected invalid binary header not to be propagated, but got %v", headers)
+		}
+	})
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// UniformLatency takes any latency between min and max with the same probability, e.g. uniform:min=10ms,max=50ms
+	UniformLatency = "uniform"
+
+	// NormalLatency takes latencies around a mean, e.g. normal:mean=50ms,stddev=10ms
+	NormalLatency = "normal"
+
+	// LogNormalLatency takes latencies around a median with a tail to the right, e.g. lognormal:median=20ms,sigma=0.5
+	LogNormalLatency = "lognormal"
+
+	// ExponentialLatency takes mostly short latencies, e.g. exponential:mean=20ms
+	ExponentialLatency = "exponential"
+
+	// ParetoLatency takes latencies of at least min with a long tail, the lower alpha the longer, e.g.
+	// pareto:min=10ms,alpha=1.5
+	ParetoLatency = "pareto"
+
+	// BimodalLatency takes the slow latency for a percentage of requests, and the fast one for all others, e.g.
+	// bimodal:fast=10ms,slow=500ms,percent=5
+	BimodalLatency = "bimodal"
+)
+
+// LatencyDistributions lists all supported latency distributions
+var LatencyDistributions = []string{UniformLatency, NormalLatency, LogNormalLatency, ExponentialLatency, ParetoLatency, BimodalLatency}
+
+// LatencyDistribution picks how long each request is delayed for
+type LatencyDistribution struct {
+	spec   string
+	sample func(r *rand.Rand) float64
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// NewLatencyDistribution parses a latency distribution such as normal:mean=50ms,stddev=10ms. Latencies are picked
+// using seed, so that the same seed gives the same latencies in the same order, unless it is 0, in which case a
+// random seed is used. An empty spec returns no distribution.
+func NewLatencyDistribution(spec string, seed int64) (*LatencyDistribution, error) {
+	if strings.TrimSpace(spec) == "" {
+		return nil, nil
+	}
+
+	name, parameters, err := parseSpec(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid latency distribution [%s]: %v", spec, err)
+	}
+
+	sample, err := newLatencySampler(name, parameters)
+	if err != nil {
+		return nil, fmt.Errorf("invalid latency distribution [%s]: %v", spec, err)
+	}
+	if err := parameters.checkAllUsed(); err != nil {
+		return nil, fmt.Errorf("invalid latency distribution [%s]: %v", spec, err)
+	}
+
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &LatencyDistribution{
+		spec:   spec,
+		sample: sample,
+		rand:   rand.New(rand.NewSource(seed)),
+	}, nil
+}
+
+func newLatencySampler(name string, parameters *specParameters) (func(r *rand.Rand) float64, error) {
+	switch name {
+	case UniformLatency:
+		min, max := parameters.duration("min"), parameters.duration("max")
+		if parameters.err != nil {
+			return nil, parameters.err
+		}
+		if max < min {
+			return nil, fmt.Errorf("max [%v] can't be lower than min [%v]", time.Duration(max), time.Duration(min))
+		}
+		return func(r *rand.Rand) float64 { return min + r.Float64()*(max-min) }, nil
+	case NormalLatency:
+		mean, stddev := parameters.duration("mean"), parameters.duration("stddev")
+		if parameters.err != nil {
+			return nil, parameters.err
+		}
+		return func(r *rand.Rand) float64 { return mean + r.NormFloat64()*stddev }, nil
+	case LogNormalLatency:
+		median, sigma := parameters.duration("median"), parameters.number("sigma")
+		if parameters.err != nil {
+			return nil, parameters.err
+		}
+		return func(r *rand.Rand) float64 { return median * math.Exp(r.NormFloat64()*sigma) }, nil
+	case ExponentialLatency:
+		mean := parameters.duration("mean")
+		if parameters.err != nil {
+			return nil, parameters.err
+		}
+		return func(r *rand.Rand) float64 { return r.ExpFloat64() * mean }, nil
+	case ParetoLatency:
+		min, alpha := parameters.duration("min"), parameters.number("alpha")
+		if parameters.err != nil {
+			return nil, parameters.err
+		}
+		if alpha <= 0 {
+			return nil, fmt.Errorf("alpha must be positive, but was [%v]", alpha)
+		}
+		// 1-Float64() is never 0, which would make the latency infinite
+		return func(r *rand.Rand) float64 { return min / math.Pow(1-r.Float64(), 1/alpha) }, nil
+	case BimodalLatency:
+		fast, slow, percent := parameters.duration("fast"), parameters.duration("slow"), parameters.number("percent")
+		if parameters.err != nil {
+			return nil, parameters.err
+		}
+		if percent < 0 || percent > 100 {
+			return nil, fmt.Errorf("percent must be between 0 and 100, but was [%v]", percent)
+		}
+		return func(r *rand.Rand) float64 {
+			if r.Float64()*100 < percent {
+				return slow
+			}
+			return fast
+		}, nil
+	default:
+		return nil, fmt.Errorf("distribution must be one of: %s", strings.Join(LatencyDistributions, ", "))
+	}
+}
+
+// Sample returns how long to delay the next request for, which is never negative
+func (d *LatencyDistribution) Sample() time.Duration {
+	if d == nil {
+		return 0
+	}
+
+	d.mu.Lock()
+	latency := d.sample(d.rand)
+	d.mu.Unlock()
+
+	if latency < 0 {
+		return 0
+	}
+	if latency > math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return time.Duration(latency)
+}
+
+func (d *LatencyDistribution) String() string {
+	return d.spec
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLatencyDistribution(t *testing.T) {
+	t.Run("picks latencies within the bounds of each distribution", func(t *testing.T) {
+		bounds := map[string][2]time.Duration{
+			"uniform:min=10ms,max=20ms":          {10 * time.Millisecond, 20 * time.Millisecond},
+			"normal:mean=50ms,stddev=10ms":       {0, time.Hour},
+			"lognormal:median=20ms,sigma=0.5":    {0, time.Hour},
+			"exponential:mean=20ms":              {0, time.Hour},
+			"pareto:min=10ms,alpha=1.5":          {10 * time.Millisecond, time.Duration(1<<63 - 1)},
+			"bimodal:fast=1ms,slow=1s,percent=5": {time.Millisecond, time.Second},
+		}
+
+		for spec, bound := range bounds {
+			latency, err := NewLatencyDistribution(spec, 1)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			for i := 0; i < 1000; i++ {
+				if sample := latency.Sample(); sample < bound[0] || sample > bound[1] {
+					t.Fatalf("Expected latencies of [%s] to be between [%v] and [%v], got [%v]", spec, bound[0], bound[1], sample)
+				}
+			}
+		}
+	})
+
+	t.Run("picks the same latencies for the same seed", func(t *testing.T) {
+		first, err := NewLatencyDistribution("exponential:mean=20ms", 42)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		second, err := NewLatencyDistribution("exponential:mean=20ms", 42)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		for i := 0; i < 100; i++ {
+			if a, b := first.Sample(), second.Sample(); a != b {
+				t.Fatalf("Expected latency [%d] to be the same for both distributions, got [%v] and [%v]", i, a, b)
+			}
+		}
+	})
+
+	t.Run("takes the slow latency for the percentage of requests configured", func(t *testing.T) {
+		latency, err := NewLatencyDistribution("bimodal:fast=1ms,slow=1s,percent=10", 7)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		slow := 0
+		for i := 0; i < 10000; i++ {
+			if latency.Sample() == time.Second {
+				slow++
+			}
+		}
+
+		if slow < 900 || slow > 1100 {
+			t.Fatalf("Expected around 1000 slow requests out of 10000, got [%d]", slow)
+		}
+	})
+
+	t.Run("returns nothing without a distribution", func(t *testing.T) {
+		latency, err := NewLatencyDistribution("", 0)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if sample := latency.Sample(); sample != 0 {
+			t.Fatalf("Expected no latency, got [%v]", sample)
+		}
+	})
+
+	t.Run("returns error for invalid distributions", func(t *testing.T) {
+		for _, spec := range []string{
+			"gaussian:mean=10ms",
+			"normal:mean=10ms",
+			"normal:mean=10ms,stddev=1ms,max=1s",
+			"uniform:min=20ms,max=10ms",
+			"exponential:mean=fast",
+			"pareto:min=10ms,alpha=0",
+			"bimodal:fast=1ms,slow=1s,percent=101",
+			"exponential:20ms",
+		} {
+			_, err := NewLatencyDistribution(spec, 0)
+			if err == nil {
+				t.Fatalf("Expecting error for [%s], got nothing", spec)
+			}
+		}
+	})
+}
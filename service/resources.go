@@ -0,0 +1,128 @@
+package service
+
+import (
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/buoyantio/bb/metrics"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	megabyte = 1024 * 1024
+
+	// defaultMemoryLeakInterval is how often memory is leaked, in chunks as big as the rate asks for
+	defaultMemoryLeakInterval = time.Second
+)
+
+// pageSize is the size of the memory pages written to when touching memory, so that the operating system backs them
+// with physical memory rather than leaving them untouched
+var pageSize = os.Getpagesize()
+
+// burnCPU keeps the CPU busy for burn, unlike sleeping, so that the service actually consumes CPU time
+func burnCPU(burn time.Duration) {
+	if burn <= 0 {
+		return
+	}
+
+	deadline := time.Now().Add(burn)
+	x := uint64(1)
+	for time.Now().Before(deadline) {
+		for i := 0; i < 1000; i++ {
+			x = x*6364136223846793005 + 1442695040888963407
+		}
+	}
+	metrics.RecordBurnedCPU(burn)
+}
+
+// allocateMemory allocates and touches bytes of memory, so that they count towards the resident memory of the service
+// for as long as the returned slice is kept
+func allocateMemory(bytes int) []byte {
+	if bytes <= 0 {
+		return nil
+	}
+
+	memory := make([]byte, bytes)
+	for i := 0; i < len(memory); i += pageSize {
+		memory[i] = 1
+	}
+	return memory
+}
+
+// holdMemory keeps memory allocated for hold, after which it can be garbage collected
+func holdMemory(memory []byte, hold time.Duration) {
+	if memory == nil || hold <= 0 {
+		return
+	}
+
+	time.AfterFunc(hold, func() { runtime.KeepAlive(memory) })
+}
+
+// MemoryLeak allocates memory in the background, at a rate in megabytes per minute, and never releases it. Leaking
+// stops when the rate is set back to 0, but memory leaked until then stays allocated.
+type MemoryLeak struct {
+	mu       sync.Mutex
+	rate     int
+	running  bool
+	interval time.Duration
+	leaked   [][]byte
+}
+
+// NewMemoryLeak returns a memory leak that doesn't leak anything until a rate is set
+func NewMemoryLeak() *MemoryLeak {
+	return &MemoryLeak{interval: defaultMemoryLeakInterval}
+}
+
+// SetRate changes how many megabytes are leaked every minute, starting to leak if it wasn't already
+func (l *MemoryLeak) SetRate(megabytesPerMinute int) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.rate != megabytesPerMinute {
+		log.Infof("Leaking [%d] MB of memory per minute", megabytesPerMinute)
+	}
+	l.rate = megabytesPerMinute
+	if l.rate > 0 && !l.running {
+		l.running = true
+		go l.run()
+	}
+}
+
+// Leaked returns how many bytes were leaked so far
+func (l *MemoryLeak) Leaked() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	leaked := 0
+	for _, chunk := range l.leaked {
+		leaked += len(chunk)
+	}
+	return leaked
+}
+
+func (l *MemoryLeak) run() {
+	ticker := time.NewTicker(l.interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		l.mu.Lock()
+		if l.rate <= 0 {
+			l.running = false
+			l.mu.Unlock()
+			return
+		}
+
+		chunk := int(int64(l.rate) * megabyte * int64(l.interval) / int64(time.Minute))
+		if memory := allocateMemory(chunk); memory != nil {
+			l.leaked = append(l.leaked, memory)
+			metrics.RecordLeakedMemory(chunk)
+		}
+		l.mu.Unlock()
+	}
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/buoyantio/bb/gen"
+)
+
+func TestBurnCPU(t *testing.T) {
+	t.Run("keeps busy for the time supplied", func(t *testing.T) {
+		start := time.Now()
+		burnCPU(time.Millisecond * 50)
+
+		if elapsed := time.Since(start); elapsed < time.Millisecond*50 {
+			t.Fatalf("Expected to burn CPU for at least [50ms], but took [%v]", elapsed)
+		}
+	})
+}
+
+func TestAllocateMemory(t *testing.T) {
+	t.Run("allocates and touches every page", func(t *testing.T) {
+		memory := allocateMemory(megabyte)
+		if len(memory) != megabyte {
+			t.Fatalf("Expected [%d] bytes to be allocated, got [%d]", megabyte, len(memory))
+		}
+
+		for i := 0; i < len(memory); i += pageSize {
+			if memory[i] == 0 {
+				t.Fatalf("Expected page at [%d] to be touched", i)
+			}
+		}
+	})
+
+	t.Run("allocates nothing without a size", func(t *testing.T) {
+		if memory := allocateMemory(0); memory != nil {
+			t.Fatalf("Expected nothing to be allocated, got [%d] bytes", len(memory))
+		}
+	})
+}
+
+func TestMemoryLeak(t *testing.T) {
+	t.Run("leaks memory at the rate set until set back to 0", func(t *testing.T) {
+		leak := &MemoryLeak{interval: time.Millisecond * 10}
+		leak.SetRate(60)
+
+		for leak.Leaked() < megabyte/100 {
+			time.Sleep(time.Millisecond * 10)
+		}
+
+		leak.SetRate(0)
+		for {
+			leak.mu.Lock()
+			running := leak.running
+			leak.mu.Unlock()
+			if !running {
+				break
+			}
+			time.Sleep(time.Millisecond * 10)
+		}
+
+		// 60 MB per minute are leaked as 1/100th of a MB every 10ms
+		leaked := leak.Leaked()
+		if leaked%(megabyte/100) != 0 {
+			t.Fatalf("Expected memory to be leaked in chunks of [%d] bytes, got [%d] bytes", megabyte/100, leaked)
+		}
+
+		time.Sleep(time.Millisecond * 50)
+		if leak.Leaked() != leaked {
+			t.Fatalf("Expected no more memory to be leaked, but [%d] bytes were leaked after [%d]", leak.Leaked(), leaked)
+		}
+	})
+}
+
+func TestRequestHandlerResources(t *testing.T) {
+	t.Run("burns CPU and allocates memory for each request", func(t *testing.T) {
+		handler := NewRequestHandler(&Config{ID: "hungry", CPUBurnInMillis: 20, MemoryPerRequestMB: 1, RecordHops: true})
+		handler.Strategy = &MockStrategy{ResponseToReturn: &pb.TheResponse{}}
+
+		start := time.Now()
+		resp, err := handler.Handle(context.TODO(), &pb.TheRequest{RequestUID: t.Name()})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if elapsed := time.Since(start); elapsed < time.Millisecond*20 {
+			t.Fatalf("Expected request to take at least [20ms], but took [%v]", elapsed)
+		}
+
+		faults := resp.Hops[0].InjectedFaults
+		if len(faults) != 2 || faults[0] != "cpu=20ms" || faults[1] != "memory=1MB" {
+			t.Fatalf("Expected hop to record CPU burn and memory allocation, but got %v", faults)
+		}
+	})
+
+	t.Run("starts and stops leaking memory as reconfigured", func(t *testing.T) {
+		handler := NewRequestHandler(&Config{ID: "leaky"})
+
+		handler.Reconfigure(&Config{ID: "leaky", MemoryLeakMBPerMinute: 10}, nil)
+		if rate := handler.MemoryLeak().rate; rate != 10 {
+			t.Fatalf("Expected to leak [10] MB per minute, got [%d]", rate)
+		}
+
+		handler.Reconfigure(&Config{ID: "leaky"}, nil)
+		if rate := handler.MemoryLeak().rate; rate != 0 {
+			t.Fatalf("Expected to stop leaking, got [%d] MB per minute", rate)
+		}
+	})
+}
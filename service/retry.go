@@ -0,0 +1,230 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	pb "github.com/buoyantio/bb/gen"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// RetryOnHTTP5xx makes requests that failed with a HTTP 5xx status retryable
+	RetryOnHTTP5xx = "5xx"
+
+	// RetryOnConnectionError makes requests that failed because the downstream service couldn't be reached, or reset the
+	// connection, retryable. Requests that timed out after connecting aren't, as they may have been handled.
+	RetryOnConnectionError = "connection-error"
+
+	// retryBudgetWindowInSeconds is how far back the retry budget looks when comparing retries to original requests
+	retryBudgetWindowInSeconds = 10
+)
+
+// RetryPolicy configures when and how often a retrying Client retries failed requests.
+type RetryPolicy struct {
+	MaxAttempts int
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+	Budget      *RetryBudget
+
+	retryOnHTTP5xx         bool
+	retryOnConnectionError bool
+	retryOnGRPCCodes       map[codes.Code]bool
+}
+
+// NewRetryPolicy creates a RetryPolicy from the retry settings in the Config. All clients using the same policy share
+// its retry budget.
+func NewRetryPolicy(config *Config) (*RetryPolicy, error) {
+	if config.RetryBackoffBase < 0 || config.RetryBackoffMax < config.RetryBackoffBase {
+		return nil, fmt.Errorf("retry backoff must be positive and its base [%v] can't be larger than its max [%v]", config.RetryBackoffBase, config.RetryBackoffMax)
+	}
+
+	if config.RetryBudgetRatio < 0 || config.RetryBudgetMinPerSecond < 0 {
+		return nil, fmt.Errorf("retry budget ratio [%f] and minimum retries per second [%d] can't be negative", config.RetryBudgetRatio, config.RetryBudgetMinPerSecond)
+	}
+
+	policy := &RetryPolicy{
+		MaxAttempts:      config.RetryMaxAttempts,
+		BackoffBase:      config.RetryBackoffBase,
+		BackoffMax:       config.RetryBackoffMax,
+		Budget:           NewRetryBudget(config.RetryBudgetRatio, config.RetryBudgetMinPerSecond),
+		retryOnGRPCCodes: map[codes.Code]bool{},
+	}
+
+	for _, retryOn := range config.RetryOn {
+		switch retryOn {
+		case RetryOnHTTP5xx:
+			policy.retryOnHTTP5xx = true
+		case RetryOnConnectionError:
+			policy.retryOnConnectionError = true
+		default:
+			code, err := ParseGRPCCode(retryOn)
+			if err != nil {
+				return nil, fmt.Errorf("can't retry on [%s], must be [%s], [%s] or a gRPC status code: %v", retryOn, RetryOnHTTP5xx, RetryOnConnectionError, err)
+			}
+			policy.retryOnGRPCCodes[code] = true
+		}
+	}
+
+	return policy, nil
+}
+
+// ParseGRPCCode parses a gRPC status code name, such as UNAVAILABLE or deadline-exceeded.
+func ParseGRPCCode(name string) (codes.Code, error) {
+	var code codes.Code
+	quotedName := fmt.Sprintf("%q", strings.ToUpper(strings.ReplaceAll(name, "-", "_")))
+	err := code.UnmarshalJSON([]byte(quotedName))
+	return code, err
+}
+
+func (p *RetryPolicy) isRetryable(err error) bool {
+	var httpErr *HTTPStatusError
+	if errors.As(err, &httpErr) {
+		return p.retryOnHTTP5xx && httpErr.StatusCode >= 500
+	}
+
+	if grpcStatus, isGRPC := status.FromError(err); isGRPC {
+		if p.retryOnGRPCCodes[grpcStatus.Code()] {
+			return true
+		}
+		// gRPC reports downstream services it can't connect to as unavailable
+		return p.retryOnConnectionError && grpcStatus.Code() == codes.Unavailable
+	}
+
+	// requests that timed out may have been handled by the downstream service, unless they timed out connecting to it
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return p.retryOnConnectionError
+	}
+	var netErr net.Error
+	return p.retryOnConnectionError && errors.As(err, &netErr) && !netErr.Timeout()
+}
+
+// backoff returns how long to wait before making the attempt following attemptsMade, using exponential backoff with
+// full jitter.
+func (p *RetryPolicy) backoff(attemptsMade int) time.Duration {
+	ceiling := p.BackoffBase
+	for i := 1; i < attemptsMade && ceiling < p.BackoffMax; i++ {
+		ceiling *= 2
+	}
+	if ceiling > p.BackoffMax {
+		ceiling = p.BackoffMax
+	}
+
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
+
+type retryBudgetBucket struct {
+	second   int64
+	requests int
+	retries  int
+}
+
+// RetryBudget limits retries to a ratio of the original requests made over the last few seconds, plus a minimum
+// number of retries per second, so that retries can't snowball into a retry storm.
+type RetryBudget struct {
+	ratio        float64
+	minPerSecond int
+	mu           sync.Mutex
+	buckets      [retryBudgetWindowInSeconds]retryBudgetBucket
+	now          func() time.Time
+}
+
+// NewRetryBudget creates a new RetryBudget
+func NewRetryBudget(ratio float64, minPerSecond int) *RetryBudget {
+	return &RetryBudget{
+		ratio:        ratio,
+		minPerSecond: minPerSecond,
+		now:          time.Now,
+	}
+}
+
+func (b *RetryBudget) currentBucket() *retryBudgetBucket {
+	second := b.now().Unix()
+	bucket := &b.buckets[second%retryBudgetWindowInSeconds]
+	if bucket.second != second {
+		*bucket = retryBudgetBucket{second: second}
+	}
+	return bucket
+}
+
+func (b *RetryBudget) recordRequest() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.currentBucket().requests++
+}
+
+// tryWithdraw returns true, and accounts for the retry, if the budget allows for one more retry.
+func (b *RetryBudget) tryWithdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	current := b.currentBucket()
+	requests, retries := 0, 0
+	for _, bucket := range b.buckets {
+		if current.second-bucket.second < retryBudgetWindowInSeconds {
+			requests += bucket.requests
+			retries += bucket.retries
+		}
+	}
+
+	allowed := float64(b.minPerSecond*retryBudgetWindowInSeconds) + b.ratio*float64(requests)
+	if float64(retries) >= allowed {
+		return false
+	}
+
+	current.retries++
+	return true
+}
+
+type retryingClient struct {
+	underlyingClient Client
+	policy           *RetryPolicy
+}
+
+func (r *retryingClient) Close() error { return r.underlyingClient.Close() }
+
+func (r *retryingClient) GetID() string { return r.underlyingClient.GetID() }
+
+func (r *retryingClient) Send(ctx context.Context, req *pb.TheRequest) (*pb.TheResponse, error) {
+	r.policy.Budget.recordRequest()
+
+	for attempt := 1; ; attempt++ {
+		resp, err := r.underlyingClient.Send(ctx, req)
+		if err == nil || attempt >= r.policy.MaxAttempts || ctx.Err() != nil || !r.policy.isRetryable(err) {
+			return resp, err
+		}
+
+		if !r.policy.Budget.tryWithdraw() {
+			log.Warnf("Retry budget exhausted, not retrying request UID [%s] to [%s] after error: %v", req.RequestUID, r.GetID(), err)
+			return resp, err
+		}
+
+		backoff := r.policy.backoff(attempt)
+		log.Infof("Retrying request UID [%s] to [%s] in [%v] (attempt %d of %d) after error: %v", req.RequestUID, r.GetID(), backoff, attempt+1, r.policy.MaxAttempts, err)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return resp, err
+		}
+	}
+}
+
+// MakeRetrying creates a new Client that retries failed requests as per the RetryPolicy.
+func MakeRetrying(client Client, policy *RetryPolicy) Client {
+	return &retryingClient{underlyingClient: client, policy: policy}
+}
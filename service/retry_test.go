@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	pb "github.com/buoyantio/bb/gen"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func newTestRetryPolicy(t *testing.T, maxAttempts int, retryOn ...string) *RetryPolicy {
+	policy, err := NewRetryPolicy(&Config{
+		RetryMaxAttempts:        maxAttempts,
+		RetryBudgetRatio:        0.2,
+		RetryBudgetMinPerSecond: 10,
+		RetryOn:                 retryOn,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return policy
+}
+
+func TestRetryingClient(t *testing.T) {
+	t.Run("retries retryable errors until the request succeeds", func(t *testing.T) {
+		expectedResponse := &pb.TheResponse{Payload: "expected"}
+		attempts := 0
+		underlyingClient := &MockClient{
+			ErrorToReturn:    status.Error(codes.Unavailable, "unavailable"),
+			ResponseToReturn: expectedResponse,
+		}
+		underlyingClient.RequestInterceptor = func(req *pb.TheRequest) {
+			attempts++
+			if attempts == 3 {
+				underlyingClient.ErrorToReturn = nil
+			}
+		}
+
+		client := MakeRetrying(underlyingClient, newTestRetryPolicy(t, 5, "unavailable"))
+		actualResponse, err := client.Send(context.Background(), &pb.TheRequest{RequestUID: "123"})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if actualResponse != expectedResponse {
+			t.Fatalf("Expected response [%v], but got [%v]", expectedResponse, actualResponse)
+		}
+
+		if attempts != 3 {
+			t.Fatalf("Expected [3] attempts, but got [%d]", attempts)
+		}
+	})
+
+	t.Run("gives up after the maximum number of attempts", func(t *testing.T) {
+		attempts := 0
+		underlyingClient := &MockClient{
+			ErrorToReturn:      &HTTPStatusError{StatusCode: 503},
+			RequestInterceptor: func(req *pb.TheRequest) { attempts++ },
+		}
+
+		client := MakeRetrying(underlyingClient, newTestRetryPolicy(t, 3, RetryOnHTTP5xx))
+		_, err := client.Send(context.Background(), &pb.TheRequest{RequestUID: "123"})
+		if err == nil {
+			t.Fatalf("Expecting error, got nothing")
+		}
+
+		if attempts != 3 {
+			t.Fatalf("Expected [3] attempts, but got [%d]", attempts)
+		}
+	})
+
+	t.Run("does not retry errors that aren't retryable", func(t *testing.T) {
+		attempts := 0
+		underlyingClient := &MockClient{
+			ErrorToReturn:      status.Error(codes.InvalidArgument, "invalid"),
+			RequestInterceptor: func(req *pb.TheRequest) { attempts++ },
+		}
+
+		client := MakeRetrying(underlyingClient, newTestRetryPolicy(t, 3, RetryOnHTTP5xx, RetryOnConnectionError, "unavailable"))
+		_, err := client.Send(context.Background(), &pb.TheRequest{RequestUID: "123"})
+		if err == nil {
+			t.Fatalf("Expecting error, got nothing")
+		}
+
+		if attempts != 1 {
+			t.Fatalf("Expected [1] attempt, but got [%d]", attempts)
+		}
+	})
+
+	t.Run("classifies errors as per the policy", func(t *testing.T) {
+		connectionError := &net.OpError{Op: "dial", Err: errors.New("connection refused")}
+		dialTimeout := &url.Error{Op: "Post", Err: &net.OpError{Op: "dial", Err: os.ErrDeadlineExceeded}}
+		resetError := &net.OpError{Op: "read", Err: syscall.ECONNRESET}
+		readTimeout := &url.Error{Op: "Post", Err: &net.OpError{Op: "read", Err: os.ErrDeadlineExceeded}}
+		policy := newTestRetryPolicy(t, 2, RetryOnConnectionError, "resource-exhausted")
+
+		retryable := []error{connectionError, dialTimeout, resetError, status.Error(codes.Unavailable, ""), status.Error(codes.ResourceExhausted, "")}
+		for _, err := range retryable {
+			if !policy.isRetryable(err) {
+				t.Fatalf("Expected [%v] to be retryable", err)
+			}
+		}
+
+		notRetryable := []error{&HTTPStatusError{StatusCode: 500}, status.Error(codes.Internal, ""), errors.New("other"), readTimeout, context.DeadlineExceeded}
+		for _, err := range notRetryable {
+			if policy.isRetryable(err) {
+				t.Fatalf("Expected [%v] not to be retryable", err)
+			}
+		}
+	})
+
+	t.Run("rejects unknown retryable errors", func(t *testing.T) {
+		_, err := NewRetryPolicy(&Config{RetryOn: []string{"not-a-code"}})
+		if err == nil {
+			t.Fatalf("Expecting error, got nothing")
+		}
+	})
+}
+
+func TestRetryBudget(t *testing.T) {
+	t.Run("allows retries proportionally to requests made", func(t *testing.T) {
+		now := time.Unix(1000, 0)
+		budget := NewRetryBudget(0.5, 0)
+		budget.now = func() time.Time { return now }
+
+		for i := 0; i < 4; i++ {
+			budget.recordRequest()
+		}
+
+		for i := 0; i < 2; i++ {
+			if !budget.tryWithdraw() {
+				t.Fatalf("Expected retry #%d to be allowed", i)
+			}
+		}
+
+		if budget.tryWithdraw() {
+			t.Fatalf("Expected retry budget to be exhausted")
+		}
+
+		now = now.Add(retryBudgetWindowInSeconds * time.Second)
+		for i := 0; i < 2; i++ {
+			budget.recordRequest()
+		}
+
+		if !budget.tryWithdraw() {
+			t.Fatalf("Expected old requests and retries to be forgotten")
+		}
+	})
+}
+
+func TestRetryBackoff(t *testing.T) {
+	t.Run("never waits longer than the maximum backoff", func(t *testing.T) {
+		policy := &RetryPolicy{BackoffBase: time.Millisecond, BackoffMax: 10 * time.Millisecond}
+		for attempt := 1; attempt < 100; attempt++ {
+			if backoff := policy.backoff(attempt); backoff > policy.BackoffMax {
+				t.Fatalf("Expected backoff to be at most [%v], but got [%v]", policy.BackoffMax, backoff)
+			}
+		}
+	})
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"fmt"
+)
+
+// RuntimeConfig holds the settings that can be changed while the service runs. When applied to a Config, settings
+// left nil are unchanged, and arguments are merged into the strategy arguments, an empty value removing the argument.
+type RuntimeConfig struct {
+	PercentFailure        *int              `json:"percentFailure"`
+	FailureTypes          []string          `json:"failureTypes"`
+	TransportFaults       []string          `json:"transportFaults"`
+	SleepInMillis         *int              `json:"sleepInMillis"`
+	CPUBurnInMillis       *int              `json:"cpuBurnInMillis"`
+	MemoryPerRequestMB    *int              `json:"memoryPerRequestMB"`
+	MemoryLeakMBPerMinute *int              `json:"memoryLeakMBPerMinute"`
+	Latency               *string           `json:"latency"`
+	TerminateAfter        *int              `json:"terminateAfter"`
+	Unhealthy             *bool             `json:"unhealthy"`
+	GRPCDownstreamServers []string          `json:"grpcDownstreamServers"`
+	H1DownstreamServers   []string          `json:"h1DownstreamServers"`
+	Arguments             map[string]string `json:"arguments"`
+}
+
+// NewRuntimeConfig returns the settings of config that can be changed while the service runs
+func NewRuntimeConfig(config *Config) *RuntimeConfig {
+	percentFailure := config.PercentageFailedRequests
+	sleepInMillis := config.SleepInMillis
+	cpuBurnInMillis := config.CPUBurnInMillis
+	memoryPerRequestMB := config.MemoryPerRequestMB
+	memoryLeakMBPerMinute := config.MemoryLeakMBPerMinute
+	latency := config.Latency
+	terminateAfter := config.TerminateAfter
+	unhealthy := config.Unhealthy
+	runtimeConfig := &RuntimeConfig{
+		PercentFailure:        &percentFailure,
+		FailureTypes:          append([]string{}, config.FailureTypes...),
+		TransportFaults:       append([]string{}, config.TransportFaults...),
+		SleepInMillis:         &sleepInMillis,
+		CPUBurnInMillis:       &cpuBurnInMillis,
+		MemoryPerRequestMB:    &memoryPerRequestMB,
+		MemoryLeakMBPerMinute: &memoryLeakMBPerMinute,
+		Latency:               &latency,
+		TerminateAfter:        &terminateAfter,
+		Unhealthy:             &unhealthy,
+		GRPCDownstreamServers: append([]string{}, config.GRPCDownstreamServers...),
+		H1DownstreamServers:   append([]string{}, config.H1DownstreamServers...),
+		Arguments:             map[string]string{},
+	}
+	for name, value := range config.ExtraArguments {
+		runtimeConfig.Arguments[name] = value
+	}
+	return runtimeConfig
+}
+
+// ChangesDownstreams reports whether applying these settings changes the downstream services
+func (r *RuntimeConfig) ChangesDownstreams() bool {
+	return r.GRPCDownstreamServers != nil || r.H1DownstreamServers != nil
+}
+
+// ChangesStrategy reports whether applying these settings requires a new strategy, as strategies are built from the
+// downstream services and arguments
+func (r *RuntimeConfig) ChangesStrategy() bool {
+	return r.ChangesDownstreams() || len(r.Arguments) > 0
+}
+
+// Apply returns a copy of config with these settings applied, leaving config itself untouched
+func (r *RuntimeConfig) Apply(config *Config) (*Config, error) {
+	if r.PercentFailure != nil && (*r.PercentFailure < 0 || *r.PercentFailure > 100) {
+		return nil, fmt.Errorf("percentage of failed requests must be between 0 and 100, but was [%d]", *r.PercentFailure)
+	}
+	if r.SleepInMillis != nil && *r.SleepInMillis < 0 {
+		return nil, fmt.Errorf("sleep in millis must not be negative, but was [%d]", *r.SleepInMillis)
+	}
+	if r.CPUBurnInMillis != nil && *r.CPUBurnInMillis < 0 {
+		return nil, fmt.Errorf("CPU burn in millis must not be negative, but was [%d]", *r.CPUBurnInMillis)
+	}
+	if r.MemoryPerRequestMB != nil && *r.MemoryPerRequestMB < 0 {
+		return nil, fmt.Errorf("memory per request must not be negative, but was [%d] MB", *r.MemoryPerRequestMB)
+	}
+	if r.MemoryLeakMBPerMinute != nil && *r.MemoryLeakMBPerMinute < 0 {
+		return nil, fmt.Errorf("memory leak must not be negative, but was [%d] MB per minute", *r.MemoryLeakMBPerMinute)
+	}
+	if _, err := NewFailureTypes(r.FailureTypes); err != nil {
+		return nil, err
+	}
+	if _, err := NewTransportFaults(r.TransportFaults); err != nil {
+		return nil, err
+	}
+	if r.Latency != nil {
+		if _, err := NewLatencyDistribution(*r.Latency, 0); err != nil {
+			return nil, err
+		}
+	}
+	if r.TerminateAfter != nil && *r.TerminateAfter < 0 {
+		return nil, fmt.Errorf("terminate after must not be negative, but was [%d]", *r.TerminateAfter)
+	}
+
+	updated := *config
+	if r.PercentFailure != nil {
+		updated.PercentageFailedRequests = *r.PercentFailure
+	}
+	if r.SleepInMillis != nil {
+		updated.SleepInMillis = *r.SleepInMillis
+	}
+	if r.CPUBurnInMillis != nil {
+		updated.CPUBurnInMillis = *r.CPUBurnInMillis
+	}
+	if r.MemoryPerRequestMB != nil {
+		updated.MemoryPerRequestMB = *r.MemoryPerRequestMB
+	}
+	if r.MemoryLeakMBPerMinute != nil {
+		updated.MemoryLeakMBPerMinute = *r.MemoryLeakMBPerMinute
+	}
+	if r.FailureTypes != nil {
+		updated.FailureTypes = append([]string{}, r.FailureTypes...)
+	}
+	if r.TransportFaults != nil {
+		updated.TransportFaults = append([]string{}, r.TransportFaults...)
+	}
+	if r.Latency != nil {
+		updated.Latency = *r.Latency
+	}
+	if r.TerminateAfter != nil {
+		updated.TerminateAfter = *r.TerminateAfter
+	}
+	if r.Unhealthy != nil {
+		updated.Unhealthy = *r.Unhealthy
+	}
+	if r.GRPCDownstreamServers != nil {
+		updated.GRPCDownstreamServers = append([]string{}, r.GRPCDownstreamServers...)
+	}
+	if r.H1DownstreamServers != nil {
+		updated.H1DownstreamServers = append([]string{}, r.H1DownstreamServers...)
+	}
+
+	updated.ExtraArguments = map[string]string{}
+	for name, value := range config.ExtraArguments {
+		updated.ExtraArguments[name] = value
+	}
+	for name, value := range r.Arguments {
+		if value == "" {
+			delete(updated.ExtraArguments, name)
+		} else {
+			updated.ExtraArguments[name] = value
+		}
+	}
+	return &updated, nil
+}
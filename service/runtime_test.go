@@ -0,0 +1,82 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRuntimeConfig(t *testing.T) {
+	config := &Config{
+		ID:                       "original",
+		PercentageFailedRequests: 10,
+		SleepInMillis:            5,
+		GRPCDownstreamServers:    []string{"localhost:9090"},
+		H1DownstreamServers:      []string{"http://localhost:8080"},
+		ExtraArguments:           map[string]string{"response-text": "BANANA", "load-balancer": "random"},
+	}
+
+	t.Run("applies only the settings that are set", func(t *testing.T) {
+		percentFailure := 50
+		changes := &RuntimeConfig{
+			PercentFailure:      &percentFailure,
+			H1DownstreamServers: []string{},
+			Arguments:           map[string]string{"response-text": "APPLE", "load-balancer": ""},
+		}
+
+		updated, err := changes.Apply(config)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if updated.ID != "original" || updated.PercentageFailedRequests != 50 || updated.SleepInMillis != 5 {
+			t.Fatalf("Expected only the failure percentage to change, got %+v", updated)
+		}
+
+		if !reflect.DeepEqual(updated.GRPCDownstreamServers, []string{"localhost:9090"}) || len(updated.H1DownstreamServers) != 0 {
+			t.Fatalf("Expected only the HTTP 1.1 downstream servers to be cleared, got %+v", updated)
+		}
+
+		if !reflect.DeepEqual(updated.ExtraArguments, map[string]string{"response-text": "APPLE"}) {
+			t.Fatalf("Expected arguments to be merged, got %v", updated.ExtraArguments)
+		}
+
+		if config.PercentageFailedRequests != 10 || config.ExtraArguments["response-text"] != "BANANA" || len(config.H1DownstreamServers) != 1 {
+			t.Fatalf("Expected original config to be left untouched, got %+v", config)
+		}
+
+		if !changes.ChangesDownstreams() || !changes.ChangesStrategy() {
+			t.Fatalf("Expected changes to require new downstreams and a new strategy")
+		}
+	})
+
+	t.Run("doesn't need a new strategy to change faults", func(t *testing.T) {
+		sleepInMillis := 100
+		changes := &RuntimeConfig{SleepInMillis: &sleepInMillis}
+
+		if changes.ChangesDownstreams() || changes.ChangesStrategy() {
+			t.Fatalf("Expected changes not to require new downstreams nor a new strategy")
+		}
+	})
+
+	t.Run("returns error for invalid settings", func(t *testing.T) {
+		tooMuch, negative, unknownLatency := 101, -1, "gaussian:mean=10ms"
+		for _, changes := range []*RuntimeConfig{{PercentFailure: &tooMuch}, {SleepInMillis: &negative}, {CPUBurnInMillis: &negative}, {MemoryPerRequestMB: &negative}, {MemoryLeakMBPerMinute: &negative}, {TerminateAfter: &negative}, {Latency: &unknownLatency}, {FailureTypes: []string{"http:status=200"}}, {TransportFaults: []string{"reset"}}} {
+			_, err := changes.Apply(config)
+			if err == nil {
+				t.Fatalf("Expecting error for %+v, got nothing", changes)
+			}
+		}
+	})
+
+	t.Run("reads the settings that can be changed", func(t *testing.T) {
+		runtimeConfig := NewRuntimeConfig(config)
+		if *runtimeConfig.PercentFailure != 10 || *runtimeConfig.SleepInMillis != 5 || *runtimeConfig.TerminateAfter != 0 {
+			t.Fatalf("Unexpected runtime config: %+v", runtimeConfig)
+		}
+
+		runtimeConfig.Arguments["response-text"] = "changed"
+		if config.ExtraArguments["response-text"] != "BANANA" {
+			t.Fatalf("Expected runtime config not to share arguments with the config")
+		}
+	})
+}
@@ -4,26 +4,75 @@ import (
 	"context"
 	"fmt"
 	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	pb "github.com/buoyantio/bb/gen"
+	"github.com/buoyantio/bb/metrics"
 	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Config holds the ,ain configuration for this service.
 type Config struct {
-	ID                       string
-	GRPCServerPort           int
-	H1ServerPort             int
-	GRPCDownstreamServers    []string
-	GRPCProxy                string
-	H1DownstreamServers      []string
-	PercentageFailedRequests int
-	SleepInMillis            int
-	TerminateAfter           int
-	FireAndForget            bool
-	DownstreamTimeout        time.Duration
-	ExtraArguments           map[string]string
+	ID                                string
+	GRPCServerPort                    int
+	H1ServerPort                      int
+	AdminPort                         int
+	BindHost                          string
+	GRPCDownstreamServers             []string
+	GRPCProxy                         string
+	H1DownstreamServers               []string
+	PercentageFailedRequests          int
+	FailureTypes                      []string
+	TransportFaults                   []string
+	SleepInMillis                     int
+	CPUBurnInMillis                   int
+	MemoryPerRequestMB                int
+	MemoryHoldFor                     time.Duration
+	MemoryLeakMBPerMinute             int
+	Latency                           string
+	LatencySeed                       int64
+	TerminateAfter                    int
+	FireAndForget                     bool
+	DownstreamTimeout                 time.Duration
+	RetryMaxAttempts                  int
+	RetryBackoffBase                  time.Duration
+	RetryBackoffMax                   time.Duration
+	RetryBudgetRatio                  float64
+	RetryBudgetMinPerSecond           int
+	RetryOn                           []string
+	HedgeDelay                        time.Duration
+	HedgePercentile                   float64
+	HedgeMaxAttempts                  int
+	CircuitBreakerConsecutiveFailures int
+	CircuitBreakerErrorPercentage     float64
+	CircuitBreakerWindow              time.Duration
+	CircuitBreakerMinRequests         int
+	CircuitBreakerCooldown            time.Duration
+	MetricsPort                       int
+	PropagateHeaders                  []string
+	PropagateAllHeaders               bool
+	TracingExporter                   string
+	TracingOTLPEndpoint               string
+	TracingFile                       string
+	TracingPropagators                []string
+	TracingSampleRatio                float64
+	TracerProvider                    trace.TracerProvider
+	RecordHops                        bool
+	Unhealthy                         bool
+	PreStopDelay                      time.Duration
+	ShutdownGracePeriod               time.Duration
+	ExtraArguments                    map[string]string
+}
+
+// BindAddress returns the address servers listen on for port, which is on every interface unless a bind host is set
+func (c *Config) BindAddress(port int) string {
+	return net.JoinHostPort(c.BindHost, strconv.Itoa(port))
 }
 
 // Client is an abstraction representing a client connection to each downstream service.
@@ -59,10 +108,12 @@ func MakeFireAndForget(client Client) Client {
 	return &fireAndForgetClient{underlyingClient: client}
 }
 
-// Server is an abstraction representing each server made available to receive inbound connections.
+// Server is an abstraction representing each server made available to receive inbound connections. Shutdown stops
+// accepting connections and waits for requests in flight to complete, until ctx is done, after which it closes all
+// connections.
 type Server interface {
 	GetID() string
-	Shutdown() error
+	Shutdown(ctx context.Context) error
 }
 
 // Strategy is the algorithm applied by this service when it receives requests (c.f. http://wiki.c2.com/?StrategyPattern)
@@ -76,75 +127,224 @@ type Strategy interface {
 
 // RequestHandler is a protocol-independent request/response handler interface
 type RequestHandler struct {
-	Strategy Strategy // public due to circular dependency between server and strategy
+	Strategy     Strategy // public due to circular dependency between server and strategy
+	StrategyName string
 
 	config       *Config
+	initial      *handlerState
+	reconfigured atomic.Pointer[handlerState]
 	stopCh       chan struct{}
-	requestCount int
-	counterCh    chan struct{}
+	stopOnce     sync.Once
+	requestCount atomic.Int64
+	health       *Health
+	memoryLeak   *MemoryLeak
 }
 
-// requestCounter approximates an atomic read/write counter via channels
-func (h *RequestHandler) requestCounter() {
-	for range h.counterCh {
-		h.requestCount++
-		if h.requestCount == h.config.TerminateAfter {
-			log.Infof("TerminateAfter limit hit (%d), stopping [%s]", h.config.TerminateAfter, h.config.ID)
-			h.stopCh <- struct{}{}
-		}
+// handlerState is the configuration, along with the latency distribution, failure types and transport faults parsed
+// from it, and strategy new requests are handled with
+type handlerState struct {
+	config          *Config
+	latency         *LatencyDistribution
+	failures        *FailureTypes
+	transportFaults *TransportFaults
+	strategy        Strategy
+}
+
+// newHandlerState parses the settings of config, unless they didn't change since the previous state, so that e.g.
+// the latency distribution carries on picking from the same sequence. Invalid settings are ignored, as they are
+// expected to be validated before.
+func newHandlerState(config *Config, strategy Strategy, previous *handlerState) *handlerState {
+	state := &handlerState{config: config, strategy: strategy}
+	var err error
+
+	if previous != nil && config.Latency == previous.config.Latency && config.LatencySeed == previous.config.LatencySeed {
+		state.latency = previous.latency
+	} else if state.latency, err = NewLatencyDistribution(config.Latency, config.LatencySeed); err != nil {
+		log.Errorf("Ignoring latency distribution of [%s]: %v", config.ID, err)
 	}
+
+	if previous != nil && strings.Join(config.FailureTypes, "\n") == strings.Join(previous.config.FailureTypes, "\n") {
+		state.failures = previous.failures
+	} else if state.failures, err = NewFailureTypes(config.FailureTypes); err != nil {
+		log.Errorf("Ignoring failure types of [%s]: %v", config.ID, err)
+	}
+
+	if previous != nil && strings.Join(config.TransportFaults, "\n") == strings.Join(previous.config.TransportFaults, "\n") {
+		state.transportFaults = previous.transportFaults
+	} else if state.transportFaults, err = NewTransportFaults(config.TransportFaults); err != nil {
+		log.Errorf("Ignoring transport faults of [%s]: %v", config.ID, err)
+	}
+	return state
 }
 
 func NewRequestHandler(config *Config) *RequestHandler {
 	h := &RequestHandler{
-		config:       config,
-		stopCh:       make(chan struct{}),
-		requestCount: 0,
-		counterCh:    make(chan struct{}),
+		config:  config,
+		initial: newHandlerState(config, nil, nil),
+		stopCh:  make(chan struct{}),
 	}
+	h.health = &Health{handler: h}
+	h.memoryLeak = NewMemoryLeak()
+	h.memoryLeak.SetRate(config.MemoryLeakMBPerMinute)
+	return h
+}
 
-	if h.config.TerminateAfter != 0 {
-		go h.requestCounter()
+// Health returns whether the service handling requests is alive and ready
+func (h *RequestHandler) Health() *Health {
+	return h.health
+}
+
+// MemoryLeak returns the memory this service leaks in the background, if set to
+func (h *RequestHandler) MemoryLeak() *MemoryLeak {
+	return h.memoryLeak
+}
+
+// current returns the configuration and strategy new requests are handled with
+func (h *RequestHandler) current() *handlerState {
+	if state := h.reconfigured.Load(); state != nil {
+		return state
+	}
+	if h.initial == nil {
+		return &handlerState{config: h.config, strategy: h.Strategy}
 	}
+	state := *h.initial
+	state.strategy = h.Strategy
+	return &state
+}
 
-	return h
+// Config returns the configuration new requests are handled with
+func (h *RequestHandler) Config() *Config {
+	return h.current().config
+}
+
+// CurrentStrategy returns the strategy new requests are handled with
+func (h *RequestHandler) CurrentStrategy() Strategy {
+	return h.current().strategy
+}
+
+// Reconfigure replaces the configuration and strategy used to handle new requests, requests already being handled
+// carry on with those they started with. Both are replaced at once, so no request sees one without the other.
+func (h *RequestHandler) Reconfigure(config *Config, strategy Strategy) {
+	h.reconfigured.Store(newHandlerState(config, strategy, h.current()))
+	h.memoryLeak.SetRate(config.MemoryLeakMBPerMinute)
+	h.stopIfTerminateAfterHit(config, h.requestCount.Load())
+}
+
+func (h *RequestHandler) stopIfTerminateAfterHit(config *Config, requestCount int64) {
+	if config.TerminateAfter == 0 || requestCount < int64(config.TerminateAfter) {
+		return
+	}
+
+	h.stopOnce.Do(func() {
+		log.Infof("TerminateAfter limit hit (%d), stopping [%s]", config.TerminateAfter, config.ID)
+		close(h.stopCh)
+	})
 }
 
 func (h *RequestHandler) ConfigID() string {
-	return h.config.ID
+	return h.Config().ID
 }
 
+// PickTransportFault returns the transport fault to inject into the connection of a request just handled, if any
+func (h *RequestHandler) PickTransportFault() *TransportFault {
+	state := h.current()
+	fault := state.transportFaults.Pick()
+	if fault != nil {
+		metrics.RecordInjectedTransportFault(state.config.ID)
+		log.Infof("Injecting transport fault [%s] into a request to [%s]", fault, state.config.ID)
+	}
+	return fault
+}
+
+// Stopping returns a channel that is closed once this handler wants the service to stop
 func (h *RequestHandler) Stopping() <-chan struct{} {
 	return h.stopCh
 }
 
 // Handle takes in a request, processes it accordingly to its Strategy, an returns the response.
 func (h *RequestHandler) Handle(ctx context.Context, req *pb.TheRequest) (*pb.TheResponse, error) {
-	sleepForConfiguredTime(h)
+	start := time.Now()
+	state := h.current()
+	config := state.config
+	ctx, span := h.startHandleSpan(ctx, config, req)
+
+	var recorder *hopRecorder
+	if config.RecordHops {
+		recorder = &hopRecorder{}
+		ctx = context.WithValue(ctx, hopRecorderKey{}, recorder)
+	}
+
+	resp, err := h.handle(ctx, state, req)
+	if recorder != nil {
+		hop := recorder.newHop(ctx, config, h, start)
+		if err != nil {
+			err = &HopError{Err: err, Hop: hop}
+		} else if resp != nil {
+			resp.Hops = []*pb.Hop{hop}
+		}
+	}
+
+	EndSpan(span, err)
+	return resp, err
+}
+
+func (h *RequestHandler) handle(ctx context.Context, state *handlerState, req *pb.TheRequest) (*pb.TheResponse, error) {
+	config := state.config
+	sleep := time.Duration(int64(config.SleepInMillis))*time.Millisecond + state.latency.Sample()
+	if sleep > 0 {
+		recordInjectedFault(ctx, fmt.Sprintf("latency=%dms", sleep.Milliseconds()))
+	}
+	sleepFor(config.ID, sleep)
 
-	if shouldFailThisRequest(h) {
-		return nil, fmt.Errorf("this error was injected by [%s]", h.config.ID)
+	if config.CPUBurnInMillis > 0 {
+		recordInjectedFault(ctx, fmt.Sprintf("cpu=%dms", config.CPUBurnInMillis))
+		burnCPU(time.Duration(config.CPUBurnInMillis) * time.Millisecond)
 	}
 
-	if h.config.TerminateAfter != 0 {
-		h.counterCh <- struct{}{}
+	// memory is kept while the request is handled, and for the hold time after
+	memory := allocateMemory(config.MemoryPerRequestMB * megabyte)
+	if memory != nil {
+		recordInjectedFault(ctx, fmt.Sprintf("memory=%dMB", config.MemoryPerRequestMB))
+		metrics.RecordAllocatedMemory(len(memory))
+		defer holdMemory(memory, config.MemoryHoldFor)
 	}
 
+	if shouldFailThisRequest(config) {
+		protocol := ""
+		if inboundReq, ok := InboundRequestFromContext(ctx); ok {
+			protocol = inboundReq.Protocol
+		}
+		failure := state.failures.Pick(protocol, config.ID)
+		metrics.RecordInjectedFailure(config.ID)
+		recordInjectedFault(ctx, "failure")
+		return nil, failure
+	}
+
+	// requests are counted even without a limit, as one can be set at runtime
+	h.stopIfTerminateAfterHit(config, h.requestCount.Add(1))
+
 	reqID := req.RequestUID
 
-	resp, err := h.Strategy.Do(ctx, req)
+	recordOutcome := metrics.Strategies.Start(config.ID, h.StrategyName)
+	strategyCtx, strategySpan := h.startStrategySpan(ctx, config)
+	resp, err := state.strategy.Do(strategyCtx, req)
+	EndSpan(strategySpan, err)
+	recordOutcome(err)
 	if resp != nil {
 		resp.RequestUID = reqID
 	}
 	return resp, err
 }
 
-func sleepForConfiguredTime(h *RequestHandler) {
-	time.Sleep(time.Duration(int64(h.config.SleepInMillis)) * time.Millisecond)
+func sleepFor(serviceID string, sleep time.Duration) {
+	if sleep > 0 {
+		metrics.RecordInjectedLatency(serviceID, sleep)
+	}
+	time.Sleep(sleep)
 }
 
-func shouldFailThisRequest(h *RequestHandler) bool {
-	perc := h.config.PercentageFailedRequests
+func shouldFailThisRequest(config *Config) bool {
+	perc := config.PercentageFailedRequests
 	rnd := rand.Intn(100)
 	return rnd < perc
 }
@@ -136,6 +136,67 @@ func TestRequestHandler(t *testing.T) {
 	})
 }
 
+func TestRequestHandlerReconfigure(t *testing.T) {
+	t.Run("handles new requests with the configuration and strategy set at runtime", func(t *testing.T) {
+		handler := NewRequestHandler(&Config{ID: "before", PercentageFailedRequests: 100})
+		handler.Strategy = &MockStrategy{ResponseToReturn: &pb.TheResponse{Payload: "before"}}
+
+		_, err := handler.Handle(context.TODO(), &pb.TheRequest{RequestUID: "1"})
+		if err == nil {
+			t.Fatalf("Expecting error, got nothing")
+		}
+
+		handler.Reconfigure(&Config{ID: "after"}, &MockStrategy{ResponseToReturn: &pb.TheResponse{Payload: "after"}})
+
+		resp, err := handler.Handle(context.TODO(), &pb.TheRequest{RequestUID: "2"})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if resp.Payload != "after" || handler.ConfigID() != "after" {
+			t.Fatalf("Expected request to be handled as reconfigured, got [%s] from [%s]", resp.Payload, handler.ConfigID())
+		}
+	})
+
+	t.Run("stops straight away when terminate-after is set below the requests already handled", func(t *testing.T) {
+		handler := NewRequestHandler(&Config{})
+		handler.Strategy = &MockStrategy{ResponseToReturn: &pb.TheResponse{}}
+
+		for i := 0; i < 3; i++ {
+			_, err := handler.Handle(context.TODO(), &pb.TheRequest{})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		}
+
+		select {
+		case <-handler.Stopping():
+			t.Fatalf("RequestHandler terminated without a terminate-after limit")
+		default:
+		}
+
+		handler.Reconfigure(&Config{TerminateAfter: 2}, handler.CurrentStrategy())
+
+		// this will timeout the test if it fails
+		<-handler.Stopping()
+	})
+	t.Run("keeps picking latencies from the same distribution unless it changed", func(t *testing.T) {
+		handler := NewRequestHandler(&Config{Latency: "uniform:min=1ms,max=2ms", LatencySeed: 1})
+		handler.Strategy = &MockStrategy{ResponseToReturn: &pb.TheResponse{}}
+		latency := handler.current().latency
+
+		handler.Reconfigure(&Config{Latency: "uniform:min=1ms,max=2ms", LatencySeed: 1, PercentageFailedRequests: 50}, handler.CurrentStrategy())
+		if handler.current().latency != latency {
+			t.Fatalf("Expected latency distribution to be kept when it didn't change")
+		}
+
+		handler.Reconfigure(&Config{Latency: "exponential:mean=1ms", LatencySeed: 1}, handler.CurrentStrategy())
+		if handler.current().latency == latency || handler.current().latency.String() != "exponential:mean=1ms" {
+			t.Fatalf("Expected latency distribution to be replaced, got [%v]", handler.current().latency)
+		}
+	})
+}
+
 func TestFireAndForgetClient(t *testing.T) {
 	t.Run("calls underlying client and returns stub response", func(t *testing.T) {
 		barrier := make(chan bool)
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseSpec splits a spec such as normal:mean=50ms,stddev=10ms into its name and parameters
+func parseSpec(spec string) (string, *specParameters, error) {
+	name, parameterList, _ := strings.Cut(strings.TrimSpace(spec), ":")
+	parameters := &specParameters{values: map[string]string{}}
+	for _, parameter := range strings.Split(parameterList, ",") {
+		if strings.TrimSpace(parameter) == "" {
+			continue
+		}
+		key, value, found := strings.Cut(parameter, "=")
+		if !found {
+			return "", nil, fmt.Errorf("parameter [%s] must be in the format name=value", parameter)
+		}
+		parameters.values[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return name, parameters, nil
+}
+
+// specParameters are the parameters of a spec such as normal:mean=50ms,stddev=10ms, each of which is removed once
+// read, keeping the first error found while reading them
+type specParameters struct {
+	values map[string]string
+	err    error
+}
+
+func (p *specParameters) read(name string) (string, bool) {
+	value, ok := p.values[name]
+	if !ok {
+		p.fail(fmt.Errorf("parameter [%s] is required", name))
+		return "", false
+	}
+	delete(p.values, name)
+	return value, true
+}
+
+func (p *specParameters) duration(name string) float64 {
+	value, ok := p.read(name)
+	if !ok {
+		return 0
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		p.fail(fmt.Errorf("parameter [%s] must be a positive duration, such as 10ms, but was [%s]", name, value))
+	}
+	return float64(duration)
+}
+
+// has reports whether an optional parameter is set
+func (p *specParameters) has(name string) bool {
+	_, ok := p.values[name]
+	return ok
+}
+
+func (p *specParameters) integer(name string) int {
+	value, ok := p.read(name)
+	if !ok {
+		return 0
+	}
+	integer, err := strconv.Atoi(value)
+	if err != nil || integer < 0 {
+		p.fail(fmt.Errorf("parameter [%s] must be a positive integer, but was [%s]", name, value))
+	}
+	return integer
+}
+
+func (p *specParameters) number(name string) float64 {
+	value, ok := p.read(name)
+	if !ok {
+		return 0
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || number < 0 {
+		p.fail(fmt.Errorf("parameter [%s] must be a positive number, but was [%s]", name, value))
+	}
+	return number
+}
+
+func (p *specParameters) fail(err error) {
+	if p.err == nil {
+		p.err = err
+	}
+}
+
+func (p *specParameters) checkAllUsed() error {
+	if len(p.values) == 0 {
+		return nil
+	}
+	unknown := make([]string, 0, len(p.values))
+	for name := range p.values {
+		unknown = append(unknown, name)
+	}
+	sort.Strings(unknown)
+	return fmt.Errorf("unknown parameters: %s", strings.Join(unknown, ", "))
+}
@@ -36,7 +36,7 @@ type MockServer struct {
 
 func (m MockServer) GetID() string { return m.IDToReturn }
 
-func (m MockServer) Shutdown() error { return nil }
+func (m MockServer) Shutdown(ctx context.Context) error { return nil }
 
 type MockStrategy struct {
 	ContextReceived  context.Context
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	pb "github.com/buoyantio/bb/gen"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// tracerName identifies the spans created by bb
+const tracerName = "github.com/buoyantio/bb"
+
+// noopTracer is used by services not recording spans
+var noopTracer = noop.NewTracerProvider().Tracer(tracerName)
+
+// inboundHeadersCarrier lets OpenTelemetry propagators read the trace context from the headers of an InboundRequest
+type inboundHeadersCarrier map[string][]string
+
+func (c inboundHeadersCarrier) Get(key string) string {
+	values := c[strings.ToLower(key)]
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func (c inboundHeadersCarrier) Set(key string, value string) {
+	c[strings.ToLower(key)] = []string{value}
+}
+
+func (c inboundHeadersCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// Tracer returns the tracer recording the spans of this service, which records nothing unless a TracerProvider is set
+func (c *Config) Tracer() trace.Tracer {
+	if c.TracerProvider == nil {
+		return noopTracer
+	}
+	return c.TracerProvider.Tracer(tracerName)
+}
+
+// startHandleSpan continues the trace carried by the inbound request, if any, starting a span for the request handled.
+func (h *RequestHandler) startHandleSpan(ctx context.Context, config *Config, req *pb.TheRequest) (context.Context, trace.Span) {
+	spanName := "handle"
+	attributes := []attribute.KeyValue{
+		attribute.String("bb.id", config.ID),
+		attribute.String("bb.request_uid", req.RequestUID),
+	}
+
+	if inbound, ok := InboundRequestFromContext(ctx); ok {
+		ctx = otel.GetTextMapPropagator().Extract(ctx, inboundHeadersCarrier(inbound.Headers))
+		spanName = inbound.Method
+		if inbound.Protocol == "http" {
+			spanName = fmt.Sprintf("%s %s", inbound.Method, inbound.Path)
+		}
+		attributes = append(attributes, attribute.String("bb.protocol", inbound.Protocol))
+	}
+
+	return config.Tracer().Start(ctx, spanName, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attributes...))
+}
+
+// startStrategySpan starts a span for the strategy processing a request.
+func (h *RequestHandler) startStrategySpan(ctx context.Context, config *Config) (context.Context, trace.Span) {
+	return config.Tracer().Start(ctx, "strategy "+h.StrategyName, trace.WithAttributes(attribute.String("bb.strategy", h.StrategyName)))
+}
+
+// StartClientSpan starts a span, using the tracer supplied, for a request sent to a downstream service by the client.
+// Without a tracer, the span records nothing.
+func StartClientSpan(ctx context.Context, tracer trace.Tracer, clientID string, req *pb.TheRequest) (context.Context, trace.Span) {
+	if tracer == nil {
+		tracer = noopTracer
+	}
+	return tracer.Start(ctx, "send "+clientID, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("bb.client", clientID),
+		attribute.String("bb.request_uid", req.RequestUID),
+	))
+}
+
+// EndSpan records the error, if any, of the operation traced by the span and ends it.
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	pb "github.com/buoyantio/bb/gen"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+
+	t.Run("continues the trace of the inbound request with spans for the handler and strategy", func(t *testing.T) {
+		strategy := &MockStrategy{ResponseToReturn: &pb.TheResponse{}}
+		handler := RequestHandler{
+			config:       &Config{ID: "expected-id", TracerProvider: tracerProvider},
+			Strategy:     strategy,
+			StrategyName: "expected-strategy",
+		}
+
+		inbound := NewInboundRequest("http", "POST", "/", map[string][]string{
+			"Traceparent": {"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"},
+		})
+		_, err := handler.Handle(WithInboundRequest(context.TODO(), inbound), &pb.TheRequest{RequestUID: "123"})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		strategySpan := trace.SpanContextFromContext(strategy.ContextReceived)
+		if strategySpan.TraceID().String() != "0af7651916cd43dd8448eb211c80319c" {
+			t.Fatalf("Expected strategy to be called within the inbound trace, but got trace [%s]", strategySpan.TraceID())
+		}
+
+		spans := recorder.Ended()
+		if len(spans) < 2 {
+			t.Fatalf("Expected spans for the handler and strategy, but got %v", spans)
+		}
+
+		strategyRecorded, handleRecorded := spans[len(spans)-2], spans[len(spans)-1]
+		if handleRecorded.Name() != "POST /" || handleRecorded.SpanKind() != trace.SpanKindServer {
+			t.Fatalf("Expected server span named [POST /], but got [%s] of kind [%s]", handleRecorded.Name(), handleRecorded.SpanKind())
+		}
+
+		if handleRecorded.Parent().SpanID().String() != "b7ad6b7169203331" {
+			t.Fatalf("Expected server span to be a child of the inbound span, but its parent was [%s]", handleRecorded.Parent().SpanID())
+		}
+
+		if strategyRecorded.Name() != "strategy expected-strategy" || strategyRecorded.Parent().SpanID() != handleRecorded.SpanContext().SpanID() {
+			t.Fatalf("Expected strategy span to be a child of the server span, but got [%s] with parent [%s]", strategyRecorded.Name(), strategyRecorded.Parent().SpanID())
+		}
+	})
+
+	t.Run("records errors on the spans", func(t *testing.T) {
+		strategy := &MockStrategy{ErrorToReturn: errors.New("expected")}
+		handler := RequestHandler{
+			config:   &Config{TracerProvider: tracerProvider},
+			Strategy: strategy,
+		}
+
+		_, err := handler.Handle(context.TODO(), &pb.TheRequest{RequestUID: "123"})
+		if err == nil {
+			t.Fatalf("Expecting error, got nothing")
+		}
+
+		spans := recorder.Ended()
+		handleRecorded := spans[len(spans)-1]
+		if handleRecorded.Status().Code != codes.Error || handleRecorded.Status().Description != "expected" {
+			t.Fatalf("Expected server span to record the error, but its status was %+v", handleRecorded.Status())
+		}
+	})
+
+	t.Run("starts client spans as children of the current span", func(t *testing.T) {
+		tracer := (&Config{TracerProvider: tracerProvider}).Tracer()
+		ctx, parent := tracer.Start(context.TODO(), "parent")
+		_, span := StartClientSpan(ctx, tracer, "downstream:9090", &pb.TheRequest{RequestUID: "123"})
+		EndSpan(span, nil)
+		parent.End()
+
+		spans := recorder.Ended()
+		clientRecorded := spans[len(spans)-2]
+		if clientRecorded.Name() != "send downstream:9090" || clientRecorded.SpanKind() != trace.SpanKindClient {
+			t.Fatalf("Expected client span named [send downstream:9090], but got [%s] of kind [%s]", clientRecorded.Name(), clientRecorded.SpanKind())
+		}
+
+		if clientRecorded.Parent().SpanID() != parent.SpanContext().SpanID() {
+			t.Fatalf("Expected client span to be a child of [%s], but its parent was [%s]", parent.SpanContext().SpanID(), clientRecorded.Parent().SpanID())
+		}
+	})
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+const (
+	// ResetFault resets the connection, without any response
+	ResetFault = "reset"
+
+	// HangFault never responds, until the client gives up
+	HangFault = "hang"
+
+	// StallBodyFault sends the response headers, but never the body
+	StallBodyFault = "stall-body"
+
+	// TrickleFault sends the response one byte at a time, waiting interval between bytes, which defaults to 10ms
+	TrickleFault = "trickle"
+
+	// CloseAfterFault resets the connection once a number of bytes of the response were sent
+	CloseAfterFault = "close-after"
+
+	// GoAwayFault sends a HTTP/2 GOAWAY frame along with the response, so that the client opens a new connection for
+	// further requests. HTTP 1.1 responses ask for the connection to be closed instead.
+	GoAwayFault = "goaway"
+
+	defaultTrickleInterval = 10 * time.Millisecond
+)
+
+// TransportFaultKinds lists all supported transport faults
+var TransportFaultKinds = []string{ResetFault, HangFault, StallBodyFault, TrickleFault, CloseAfterFault, GoAwayFault}
+
+// TransportFault is a fault injected into the connection a request was received over, rather than into its response
+type TransportFault struct {
+	Kind     string
+	Percent  float64
+	Bytes    int
+	Interval time.Duration
+}
+
+// TransportFaults picks which transport fault, if any, is injected for each request
+type TransportFaults struct {
+	faults []*TransportFault
+}
+
+// NewTransportFaults parses transport faults such as reset:percent=5 or close-after:percent=5,bytes=100. Every fault
+// takes the percentage of requests it is injected into, close-after takes the number of bytes sent before the
+// connection is reset, and trickle takes an optional interval between bytes.
+func NewTransportFaults(specs []string) (*TransportFaults, error) {
+	transportFaults := &TransportFaults{}
+	totalPercent := 0.0
+	for _, spec := range specs {
+		fault, err := newTransportFault(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid transport fault [%s]: %v", spec, err)
+		}
+		totalPercent += fault.Percent
+		transportFaults.faults = append(transportFaults.faults, fault)
+	}
+
+	if totalPercent > 100 {
+		return nil, fmt.Errorf("transport faults can be injected into at most 100%% of requests, but add up to [%v]", totalPercent)
+	}
+	return transportFaults, nil
+}
+
+func newTransportFault(spec string) (*TransportFault, error) {
+	kind, parameters, err := parseSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	fault := &TransportFault{Kind: kind, Percent: parameters.number("percent")}
+	switch kind {
+	case ResetFault, HangFault, StallBodyFault, GoAwayFault:
+	case TrickleFault:
+		fault.Interval = defaultTrickleInterval
+		if parameters.has("interval") {
+			fault.Interval = time.Duration(parameters.duration("interval"))
+		}
+	case CloseAfterFault:
+		fault.Bytes = parameters.integer("bytes")
+	default:
+		return nil, fmt.Errorf("fault must be one of: %s", strings.Join(TransportFaultKinds, ", "))
+	}
+
+	if parameters.err != nil {
+		return nil, parameters.err
+	}
+	if err := parameters.checkAllUsed(); err != nil {
+		return nil, err
+	}
+	if fault.Percent < 0 || fault.Percent > 100 {
+		return nil, fmt.Errorf("percent must be between 0 and 100, but was [%v]", fault.Percent)
+	}
+	return fault, nil
+}
+
+// Pick returns the transport fault to inject into a request, or nil if none
+func (f *TransportFaults) Pick() *TransportFault {
+	if f == nil || len(f.faults) == 0 {
+		return nil
+	}
+
+	picked := rand.Float64() * 100
+	for _, fault := range f.faults {
+		if picked < fault.Percent {
+			return fault
+		}
+		picked -= fault.Percent
+	}
+	return nil
+}
+
+func (f *TransportFault) String() string {
+	switch f.Kind {
+	case TrickleFault:
+		return fmt.Sprintf("%s:interval=%v", f.Kind, f.Interval)
+	case CloseAfterFault:
+		return fmt.Sprintf("%s:bytes=%d", f.Kind, f.Bytes)
+	default:
+		return f.Kind
+	}
+}
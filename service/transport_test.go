@@ -0,0 +1,70 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTransportFaults(t *testing.T) {
+	t.Run("picks faults for the percentage of requests configured", func(t *testing.T) {
+		transportFaults, err := NewTransportFaults([]string{"reset:percent=10", "trickle:percent=20,interval=1ms"})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		picked := map[string]int{}
+		for i := 0; i < 10000; i++ {
+			if fault := transportFaults.Pick(); fault != nil {
+				picked[fault.Kind]++
+			}
+		}
+
+		if picked[ResetFault] < 800 || picked[ResetFault] > 1200 || picked[TrickleFault] < 1800 || picked[TrickleFault] > 2200 {
+			t.Fatalf("Expected around 1000 resets and 2000 trickles out of 10000 requests, got %v", picked)
+		}
+	})
+
+	t.Run("reads the parameters of each fault", func(t *testing.T) {
+		transportFaults, err := NewTransportFaults([]string{"close-after:percent=100,bytes=42"})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if fault := transportFaults.Pick(); fault.Kind != CloseAfterFault || fault.Bytes != 42 {
+			t.Fatalf("Expected to close after [42] bytes, got %+v", fault)
+		}
+
+		transportFaults, err = NewTransportFaults([]string{"trickle:percent=100"})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if fault := transportFaults.Pick(); fault.Interval != 10*time.Millisecond {
+			t.Fatalf("Expected to trickle every [10ms] by default, got %+v", fault)
+		}
+	})
+
+	t.Run("picks nothing without faults", func(t *testing.T) {
+		var transportFaults *TransportFaults
+		if fault := transportFaults.Pick(); fault != nil {
+			t.Fatalf("Expected no fault, got %+v", fault)
+		}
+	})
+
+	t.Run("returns error for invalid faults", func(t *testing.T) {
+		for _, specs := range [][]string{
+			{"drop:percent=5"},
+			{"reset"},
+			{"reset:percent=101"},
+			{"reset:percent=-5"},
+			{"close-after:percent=5"},
+			{"close-after:percent=5,bytes=-1"},
+			{"trickle:percent=5,interval=slow"},
+			{"hang:percent=5,bytes=3"},
+			{"reset:percent=60", "hang:percent=50"},
+		} {
+			_, err := NewTransportFaults(specs)
+			if err == nil {
+				t.Fatalf("Expecting error for %v, got nothing", specs)
+			}
+		}
+	})
+}
@@ -4,62 +4,104 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
-	"sync"
 
 	pb "github.com/buoyantio/bb/gen"
 	"github.com/buoyantio/bb/service"
 	log "github.com/sirupsen/logrus"
 )
 
-// BroadcastChannelStrategyName is the user-friendly name of this strategy
-const BroadcastChannelStrategyName = "broadcast-channel"
+const (
+	// BroadcastChannelStrategyName is the user-friendly name of this strategy
+	BroadcastChannelStrategyName = "broadcast-channel"
+
+	// BroadcastChannelCompletionModeArgName is the parameter used to supply when a broadcast is considered complete
+	BroadcastChannelCompletionModeArgName = "completion-mode"
+
+	// BroadcastAllCompletionMode waits for all downstream services, failing if any of them failed
+	BroadcastAllCompletionMode = "all"
+
+	// BroadcastFirstSuccessCompletionMode returns as soon as any downstream service succeeds
+	BroadcastFirstSuccessCompletionMode = "first-success"
+
+	// BroadcastQuorumCompletionMode returns as soon as N downstream services succeed, supplied as quorum=N
+	BroadcastQuorumCompletionMode = "quorum"
+
+	// BroadcastBestEffortCompletionMode waits for all downstream services, reporting any errors in the payload
+	// instead of failing
+	BroadcastBestEffortCompletionMode = "best-effort"
+)
 
 // BroadcastChannelStrategy is a strategy that will take in a request and broadcast it to all downstream services.
 type BroadcastChannelStrategy struct {
-	clients []service.Client
+	clients        []service.Client
+	completionMode string
+	quorum         int
+}
+
+type broadcastResult struct {
+	client service.Client
+	resp   *pb.TheResponse
+	err    error
 }
 
 // Do executes the request
 func (s *BroadcastChannelStrategy) Do(ctx context.Context, req *pb.TheRequest) (*pb.TheResponse, error) {
+	// cancelling the context once Do returns cancels any requests still in flight
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
 	numberOfRequestsToMake := len(s.clients)
-	log.Infof("Starting broadcast to [%d] downstream services", numberOfRequestsToMake)
-	var wg sync.WaitGroup
-	wg.Add(numberOfRequestsToMake)
+	log.Infof("Starting broadcast to [%d] downstream services, completing on [%s]", numberOfRequestsToMake, s.completionMode)
 
-	allResults := make(chan interface{}, numberOfRequestsToMake)
+	allResults := make(chan broadcastResult, numberOfRequestsToMake)
 	for _, client := range s.clients {
 		go func(c service.Client) {
 			log.Infof("Making request to [%s]", c.GetID())
-			defer wg.Done()
 			clientResp, err := c.Send(ctx, req)
-			if err != nil {
-				log.Errorf("Error when broadcasting request [%v] to client [%s]: %v", req, c.GetID(), err)
-				allResults <- fmt.Errorf("downstream server [%s] returned error: %v", c.GetID(), err)
-			} else {
-				log.Debugf("Received response from [%s]: %+v", c.GetID(), clientResp)
-				allResults <- clientResp
-			}
+			allResults <- broadcastResult{client: c, resp: clientResp, err: err}
 		}(client)
 	}
-	wg.Wait()
-	close(allResults)
-	log.Info("Finished broadcast")
 
 	allErrorMessages := make([]string, 0)
 	allResponsePayloads := make([]string, 0)
-	for result := range allResults {
-		if err, isErr := result.(error); isErr {
-			allErrorMessages = append(allErrorMessages, err.Error())
+	for i := 0; i < numberOfRequestsToMake; i++ {
+		result := <-allResults
+		if result.err != nil {
+			log.Errorf("Error when broadcasting request [%v] to client [%s]: %v", req, result.client.GetID(), result.err)
+			allErrorMessages = append(allErrorMessages, fmt.Sprintf("downstream server [%s] returned error: %v", result.client.GetID(), result.err))
 		} else {
-			resp := result.(*pb.TheResponse)
-			allResponsePayloads = append(allResponsePayloads, resp.Payload)
+			log.Debugf("Received response from [%s]: %+v", result.client.GetID(), result.resp)
+			allResponsePayloads = append(allResponsePayloads, result.resp.Payload)
+		}
+
+		if s.completionMode == BroadcastAllCompletionMode || s.completionMode == BroadcastBestEffortCompletionMode {
+			// these wait for every response, as all of them are reported back
+			continue
+		}
+
+		if len(allResponsePayloads) >= s.quorum {
+			log.Infof("Broadcast reached [%d] successful responses, cancelling [%d] requests still in flight", len(allResponsePayloads), numberOfRequestsToMake-i-1)
+			break
+		}
+
+		if numberOfRequestsToMake-len(allErrorMessages) < s.quorum {
+			log.Infof("Broadcast can no longer reach [%d] successful responses, cancelling [%d] requests still in flight", s.quorum, numberOfRequestsToMake-i-1)
+			break
 		}
 	}
+	log.Info("Finished broadcast")
+
+	if s.completionMode == BroadcastBestEffortCompletionMode {
+		return &pb.TheResponse{
+			Payload: strings.Join(append(allResponsePayloads, allErrorMessages...), ","),
+		}, nil
+	}
 
 	var aggregatedResp *pb.TheResponse
 	var aggregatedErrors error
-	if len(allErrorMessages) > 0 {
+	if len(allResponsePayloads) < s.quorum {
 		aggregatedErrors = errors.New(strings.Join(allErrorMessages, ","))
 	} else {
 		aggregatedResp = &pb.TheResponse{
@@ -70,6 +112,29 @@ func (s *BroadcastChannelStrategy) Do(ctx context.Context, req *pb.TheRequest) (
 	return aggregatedResp, aggregatedErrors
 }
 
+// parseCompletionMode returns the completion mode and how many successful responses it requires.
+func parseCompletionMode(completionMode string, numberOfClients int) (string, int, error) {
+	switch completionMode {
+	case BroadcastAllCompletionMode, "":
+		return BroadcastAllCompletionMode, numberOfClients, nil
+	case BroadcastFirstSuccessCompletionMode:
+		return completionMode, 1, nil
+	case BroadcastBestEffortCompletionMode:
+		return completionMode, 0, nil
+	}
+
+	quorumPrefix := BroadcastQuorumCompletionMode + "="
+	if strings.HasPrefix(completionMode, quorumPrefix) {
+		quorum, err := strconv.Atoi(strings.TrimPrefix(completionMode, quorumPrefix))
+		if err != nil || quorum < 1 || quorum > numberOfClients {
+			return "", 0, fmt.Errorf("quorum in completion mode [%s] must be a number between 1 and the number of downstream services [%d]", completionMode, numberOfClients)
+		}
+		return BroadcastQuorumCompletionMode, quorum, nil
+	}
+
+	return "", 0, fmt.Errorf("completion mode [%s] isn't supported, must be one of: %s, %s, %s=N, %s", completionMode, BroadcastAllCompletionMode, BroadcastFirstSuccessCompletionMode, BroadcastQuorumCompletionMode, BroadcastBestEffortCompletionMode)
+}
+
 // NewBroadcastChannel creates a new BroadcastChannelStrategy
 func NewBroadcastChannel(config *service.Config, servers []service.Server, clients []service.Client) (service.Strategy, error) {
 	if len(clients) < 2 || len(servers) != 1 {
@@ -86,7 +151,14 @@ func NewBroadcastChannel(config *service.Config, servers []service.Server, clien
 		return nil, fmt.Errorf("strategy [%s] requires exactly one server and more than one downstream services, but had clients [%s] servers [%s] and configured as: %+v", BroadcastChannelStrategyName, clientNames, serverNames, config)
 	}
 
+	completionMode, quorum, err := parseCompletionMode(config.ExtraArguments[BroadcastChannelCompletionModeArgName], len(clients))
+	if err != nil {
+		return nil, err
+	}
+
 	return &BroadcastChannelStrategy{
-		clients: clients,
+		clients:        clients,
+		completionMode: completionMode,
+		quorum:         quorum,
 	}, nil
 }
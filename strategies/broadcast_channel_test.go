@@ -91,3 +91,101 @@ func TestScatherGatherChannelStrategy(t *testing.T) {
 		}
 	})
 }
+
+func TestBroadcastChannelCompletionModes(t *testing.T) {
+	allServers := []service.Server{service.MockServer{}}
+	expectedRequest := &pb.TheRequest{RequestUID: "expected-req"}
+
+	newStrategy := func(t *testing.T, completionMode string, clients ...service.Client) service.Strategy {
+		config := &service.Config{ExtraArguments: map[string]string{BroadcastChannelCompletionModeArgName: completionMode}}
+		strategy, err := NewBroadcastChannel(config, allServers, clients)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return strategy
+	}
+
+	t.Run("first-success returns without waiting for slower downstream services", func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+
+		slowClient := &service.MockClient{
+			IDToReturn:         "slow",
+			ResponseToReturn:   &pb.TheResponse{Payload: "slow"},
+			RequestInterceptor: func(req *pb.TheRequest) { <-release },
+		}
+		failingClient := &service.MockClient{IDToReturn: "failing", ErrorToReturn: errors.New("failing")}
+		fastClient := &service.MockClient{IDToReturn: "fast", ResponseToReturn: &pb.TheResponse{Payload: "fast"}}
+
+		strategy := newStrategy(t, BroadcastFirstSuccessCompletionMode, slowClient, failingClient, fastClient)
+		response, err := strategy.Do(context.TODO(), expectedRequest)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if response.Payload != "fast" {
+			t.Fatalf("Expected response [fast], but got [%s]", response.Payload)
+		}
+	})
+
+	t.Run("quorum returns once enough downstream services succeed", func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+
+		slowClient := &service.MockClient{
+			IDToReturn:         "slow",
+			ResponseToReturn:   &pb.TheResponse{Payload: "slow"},
+			RequestInterceptor: func(req *pb.TheRequest) { <-release },
+		}
+		client1 := &service.MockClient{IDToReturn: "1", ResponseToReturn: &pb.TheResponse{Payload: "1"}}
+		client2 := &service.MockClient{IDToReturn: "2", ResponseToReturn: &pb.TheResponse{Payload: "2"}}
+
+		strategy := newStrategy(t, "quorum=2", slowClient, client1, client2)
+		response, err := strategy.Do(context.TODO(), expectedRequest)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if !strings.Contains(response.Payload, "1") || !strings.Contains(response.Payload, "2") {
+			t.Fatalf("Expected response to contain payloads [1] and [2], but got [%s]", response.Payload)
+		}
+	})
+
+	t.Run("quorum fails once it can no longer be reached", func(t *testing.T) {
+		client1 := &service.MockClient{IDToReturn: "1", ResponseToReturn: &pb.TheResponse{Payload: "1"}}
+		client2 := &service.MockClient{IDToReturn: "2", ErrorToReturn: errors.New("2")}
+		client3 := &service.MockClient{IDToReturn: "3", ErrorToReturn: errors.New("3")}
+
+		strategy := newStrategy(t, "quorum=2", client1, client2, client3)
+		response, err := strategy.Do(context.TODO(), expectedRequest)
+		if err == nil {
+			t.Fatalf("Expecting error, got response [%v]", response)
+		}
+	})
+
+	t.Run("best-effort reports errors in the payload and succeeds", func(t *testing.T) {
+		client1 := &service.MockClient{IDToReturn: "1", ResponseToReturn: &pb.TheResponse{Payload: "1"}}
+		client2 := &service.MockClient{IDToReturn: "2", ErrorToReturn: errors.New("expected error")}
+
+		strategy := newStrategy(t, BroadcastBestEffortCompletionMode, client1, client2)
+		response, err := strategy.Do(context.TODO(), expectedRequest)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if !strings.Contains(response.Payload, "1") || !strings.Contains(response.Payload, "expected error") {
+			t.Fatalf("Expected response to contain payload [1] and error [expected error], but got [%s]", response.Payload)
+		}
+	})
+
+	t.Run("rejects unknown completion modes and impossible quorums", func(t *testing.T) {
+		clients := []service.Client{&service.MockClient{}, &service.MockClient{}}
+		for _, completionMode := range []string{"unknown", "quorum=0", "quorum=3", "quorum=a"} {
+			config := &service.Config{ExtraArguments: map[string]string{BroadcastChannelCompletionModeArgName: completionMode}}
+			_, err := NewBroadcastChannel(config, allServers, clients)
+			if err == nil {
+				t.Fatalf("Expecting error for completion mode [%s], got nothing", completionMode)
+			}
+		}
+	})
+}
@@ -0,0 +1,216 @@
+package strategies
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	pb "github.com/buoyantio/bb/gen"
+	"github.com/buoyantio/bb/service"
+)
+
+const (
+	// RoundRobinLoadBalancer sends requests to each downstream service in turn
+	RoundRobinLoadBalancer = "round-robin"
+
+	// RandomLoadBalancer sends each request to a downstream service picked at random
+	RandomLoadBalancer = "random"
+
+	// WeightedLoadBalancer sends each request to a downstream service picked at random, proportionally to its weight
+	WeightedLoadBalancer = "weighted"
+
+	// LeastOutstandingRequestsLoadBalancer sends each request to the downstream service with fewer requests in flight
+	LeastOutstandingRequestsLoadBalancer = "least-outstanding-requests"
+
+	// PowerOfTwoChoicesLoadBalancer picks two downstream services at random and sends the request to the one with
+	// fewer requests in flight
+	PowerOfTwoChoicesLoadBalancer = "power-of-two-choices"
+)
+
+// LoadBalancers lists all supported load balancing algorithms
+var LoadBalancers = []string{
+	RoundRobinLoadBalancer,
+	RandomLoadBalancer,
+	WeightedLoadBalancer,
+	LeastOutstandingRequestsLoadBalancer,
+	PowerOfTwoChoicesLoadBalancer,
+}
+
+// balancedClient decorates a Client, keeping track of how many requests are in flight and of its weight.
+type balancedClient struct {
+	service.Client
+	weight      int64
+	outstanding int64
+}
+
+func (b *balancedClient) Send(ctx context.Context, req *pb.TheRequest) (*pb.TheResponse, error) {
+	atomic.AddInt64(&b.outstanding, 1)
+	defer atomic.AddInt64(&b.outstanding, -1)
+	return b.Client.Send(ctx, req)
+}
+
+func (b *balancedClient) inFlight() int64 { return atomic.LoadInt64(&b.outstanding) }
+
+func (b *balancedClient) getWeight() int64 { return atomic.LoadInt64(&b.weight) }
+
+// loadBalancer picks the downstream service that should receive the next request.
+type loadBalancer interface {
+	pick() *balancedClient
+}
+
+type roundRobinBalancer struct {
+	clients []*balancedClient
+	next    uint64
+}
+
+func (b *roundRobinBalancer) pick() *balancedClient {
+	n := atomic.AddUint64(&b.next, 1) - 1
+	return b.clients[n%uint64(len(b.clients))]
+}
+
+type randomBalancer struct {
+	clients []*balancedClient
+}
+
+func (b *randomBalancer) pick() *balancedClient {
+	return b.clients[rand.Intn(len(b.clients))]
+}
+
+type weightedBalancer struct {
+	clients []*balancedClient
+}
+
+func (b *weightedBalancer) pick() *balancedClient {
+	var total int64
+	for _, c := range b.clients {
+		total += c.getWeight()
+	}
+
+	if total <= 0 {
+		return b.clients[rand.Intn(len(b.clients))]
+	}
+
+	target := rand.Int63n(total)
+	for _, c := range b.clients {
+		target -= c.getWeight()
+		if target < 0 {
+			return c
+		}
+	}
+	return b.clients[len(b.clients)-1]
+}
+
+type leastOutstandingRequestsBalancer struct {
+	clients []*balancedClient
+	next    uint64
+}
+
+func (b *leastOutstandingRequestsBalancer) pick() *balancedClient {
+	// start scanning from a rotating offset so that ties don't always go to the first client
+	offset := atomic.AddUint64(&b.next, 1) - 1
+	var chosen *balancedClient
+	for i := range b.clients {
+		c := b.clients[(offset+uint64(i))%uint64(len(b.clients))]
+		if chosen == nil || c.inFlight() < chosen.inFlight() {
+			chosen = c
+		}
+	}
+	return chosen
+}
+
+type powerOfTwoChoicesBalancer struct {
+	clients []*balancedClient
+}
+
+func (b *powerOfTwoChoicesBalancer) pick() *balancedClient {
+	if len(b.clients) == 1 {
+		return b.clients[0]
+	}
+
+	i := rand.Intn(len(b.clients))
+	j := rand.Intn(len(b.clients) - 1)
+	if j >= i {
+		j++
+	}
+
+	first, second := b.clients[i], b.clients[j]
+	if second.inFlight() < first.inFlight() {
+		return second
+	}
+	return first
+}
+
+func newLoadBalancer(algorithm string, clients []*balancedClient) (loadBalancer, error) {
+	switch algorithm {
+	case RoundRobinLoadBalancer, "":
+		return &roundRobinBalancer{clients: clients}, nil
+	case RandomLoadBalancer:
+		return &randomBalancer{clients: clients}, nil
+	case WeightedLoadBalancer:
+		return &weightedBalancer{clients: clients}, nil
+	case LeastOutstandingRequestsLoadBalancer:
+		return &leastOutstandingRequestsBalancer{clients: clients}, nil
+	case PowerOfTwoChoicesLoadBalancer:
+		return &powerOfTwoChoicesBalancer{clients: clients}, nil
+	default:
+		return nil, fmt.Errorf("unknown load balancer [%s], must be one of %v", algorithm, LoadBalancers)
+	}
+}
+
+// newBalancedClients wraps each client, assigning it the weight configured for its downstream service, or 1 if
+// none was configured.
+func newBalancedClients(clients []service.Client, weights map[string]int64) ([]*balancedClient, error) {
+	balanced := make([]*balancedClient, 0, len(clients))
+	for _, c := range clients {
+		balanced = append(balanced, &balancedClient{Client: c, weight: 1})
+	}
+
+	for downstream, weight := range weights {
+		found := false
+		for _, b := range balanced {
+			if matchesDownstream(b.Client, downstream) {
+				atomic.StoreInt64(&b.weight, weight)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("weight configured for [%s], which isn't one of the downstream services", downstream)
+		}
+	}
+
+	return balanced, nil
+}
+
+// matchesDownstream returns true if the client was configured to talk to the downstream server supplied, either
+// directly or via a proxy.
+func matchesDownstream(client service.Client, downstream string) bool {
+	id := client.GetID()
+	return id == downstream || strings.HasSuffix(id, " / "+downstream)
+}
+
+// parseDownstreamWeights parses a comma-separated list of downstream=weight pairs.
+func parseDownstreamWeights(arg string) (map[string]int64, error) {
+	weights := map[string]int64{}
+	if strings.TrimSpace(arg) == "" {
+		return weights, nil
+	}
+
+	for _, pair := range strings.Split(arg, ",") {
+		separator := strings.LastIndex(pair, "=")
+		if separator < 1 {
+			return nil, fmt.Errorf("weight [%s] must be in the format downstream=weight", pair)
+		}
+
+		downstream := strings.TrimSpace(pair[:separator])
+		weight, err := strconv.ParseInt(strings.TrimSpace(pair[separator+1:]), 10, 64)
+		if err != nil || weight < 0 {
+			return nil, fmt.Errorf("weight for [%s] must be a non-negative integer, was [%s]", downstream, pair[separator+1:])
+		}
+		weights[downstream] = weight
+	}
+
+	return weights, nil
+}
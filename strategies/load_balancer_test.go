@@ -0,0 +1,103 @@
+package strategies
+
+import (
+	"testing"
+
+	"github.com/buoyantio/bb/service"
+)
+
+func newTestBalancedClients(t *testing.T, weights map[string]int64, ids ...string) []*balancedClient {
+	clients := make([]service.Client, 0)
+	for _, id := range ids {
+		clients = append(clients, &service.MockClient{IDToReturn: id})
+	}
+
+	balanced, err := newBalancedClients(clients, weights)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return balanced
+}
+
+func TestLoadBalancers(t *testing.T) {
+	t.Run("round-robin picks each client in turn", func(t *testing.T) {
+		clients := newTestBalancedClients(t, nil, "a", "b", "c")
+		balancer, err := newLoadBalancer(RoundRobinLoadBalancer, clients)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		for i := 0; i < 6; i++ {
+			expected := clients[i%3]
+			actual := balancer.pick()
+			if actual != expected {
+				t.Fatalf("Expected pick #%d to be [%s], but got [%s]", i, expected.GetID(), actual.GetID())
+			}
+		}
+	})
+
+	t.Run("weighted never picks clients with weight zero", func(t *testing.T) {
+		clients := newTestBalancedClients(t, map[string]int64{"a": 0, "b": 3}, "a", "b")
+		balancer, err := newLoadBalancer(WeightedLoadBalancer, clients)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		for i := 0; i < 1000; i++ {
+			if actual := balancer.pick(); actual.GetID() != "b" {
+				t.Fatalf("Expected only [b] to be picked, but got [%s]", actual.GetID())
+			}
+		}
+	})
+
+	t.Run("least-outstanding-requests and power-of-two-choices avoid busy clients", func(t *testing.T) {
+		for _, algorithm := range []string{LeastOutstandingRequestsLoadBalancer, PowerOfTwoChoicesLoadBalancer} {
+			clients := newTestBalancedClients(t, nil, "busy", "idle")
+			clients[0].outstanding = 10
+
+			balancer, err := newLoadBalancer(algorithm, clients)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			for i := 0; i < 100; i++ {
+				if actual := balancer.pick(); actual.GetID() != "idle" {
+					t.Fatalf("Expected [%s] to pick [idle], but got [%s]", algorithm, actual.GetID())
+				}
+			}
+		}
+	})
+
+	t.Run("random only picks configured clients", func(t *testing.T) {
+		clients := newTestBalancedClients(t, nil, "a", "b")
+		balancer, err := newLoadBalancer(RandomLoadBalancer, clients)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		for i := 0; i < 100; i++ {
+			if actual := balancer.pick(); actual != clients[0] && actual != clients[1] {
+				t.Fatalf("Expected one of the configured clients, but got [%v]", actual)
+			}
+		}
+	})
+
+	t.Run("rejects weights for unknown downstream services", func(t *testing.T) {
+		_, err := newBalancedClients([]service.Client{&service.MockClient{IDToReturn: "a"}}, map[string]int64{"b": 1})
+		if err == nil {
+			t.Fatalf("Expecting error, got nothing")
+		}
+	})
+
+	t.Run("matches weights to clients configured via a proxy", func(t *testing.T) {
+		weights, err := parseDownstreamWeights("localhost:9090=5")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		clients := newTestBalancedClients(t, weights, "proxy:4140 / localhost:9090")
+		if clients[0].getWeight() != 5 {
+			t.Fatalf("Expected weight to be [5], but got [%d]", clients[0].getWeight())
+		}
+	})
+}
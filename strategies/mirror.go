@@ -0,0 +1,135 @@
+package strategies
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/buoyantio/bb/admin"
+	pb "github.com/buoyantio/bb/gen"
+	"github.com/buoyantio/bb/service"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// MirrorStrategyName is the user-friendly name of this strategy
+	MirrorStrategyName = "mirror"
+
+	// MirrorShadowDownstreamsArgName is the parameter used to supply which downstream services receive shadow traffic
+	MirrorShadowDownstreamsArgName = "shadow-downstream-servers"
+
+	// MirrorShadowPercentageArgName is the parameter used to supply the percentage of requests copied to the shadows
+	MirrorShadowPercentageArgName = "shadow-percentage"
+
+	// MirrorAdminPath is where the admin server exposes the shadow traffic counters
+	MirrorAdminPath = "/mirror"
+)
+
+// MirrorStats counts the requests copied to shadow downstream services and their outcomes.
+type MirrorStats struct {
+	Requests  int64 `json:"requests"`
+	Successes int64 `json:"successes"`
+	Failures  int64 `json:"failures"`
+}
+
+// MirrorStrategy is a strategy that forwards each request to a primary downstream service and returns its response,
+// while also sending a copy to one or more shadow downstream services without waiting for them.
+type MirrorStrategy struct {
+	primary          service.Client
+	shadows          []service.Client
+	shadowPercentage int
+	stats            MirrorStats
+}
+
+// Do executes the request
+func (s *MirrorStrategy) Do(ctx context.Context, req *pb.TheRequest) (*pb.TheResponse, error) {
+	if rand.Intn(100) < s.shadowPercentage {
+		// shadow requests must outlive the request that triggered them, so they aren't cancelled alongside it
+		shadowCtx := context.WithoutCancel(ctx)
+		for _, shadow := range s.shadows {
+			go s.sendToShadow(shadowCtx, shadow, req)
+		}
+	}
+
+	return s.primary.Send(ctx, req)
+}
+
+func (s *MirrorStrategy) sendToShadow(ctx context.Context, shadow service.Client, req *pb.TheRequest) {
+	atomic.AddInt64(&s.stats.Requests, 1)
+	log.Infof("Sending shadow request to [%s] for request UID [%s]", shadow.GetID(), req.RequestUID)
+
+	resp, err := shadow.Send(ctx, req)
+	if err != nil {
+		failures := atomic.AddInt64(&s.stats.Failures, 1)
+		log.Warnf("Shadow request to [%s] for request UID [%s] failed (%d shadow failures so far): %v", shadow.GetID(), req.RequestUID, failures, err)
+		return
+	}
+
+	successes := atomic.AddInt64(&s.stats.Successes, 1)
+	log.Infof("Shadow request to [%s] for request UID [%s] returned [%+v] (%d shadow successes so far)", shadow.GetID(), req.RequestUID, resp, successes)
+}
+
+// Stats returns the counters for shadow requests made so far
+func (s *MirrorStrategy) Stats() MirrorStats {
+	return MirrorStats{
+		Requests:  atomic.LoadInt64(&s.stats.Requests),
+		Successes: atomic.LoadInt64(&s.stats.Successes),
+		Failures:  atomic.LoadInt64(&s.stats.Failures),
+	}
+}
+
+// AdminPath is where the admin server exposes the shadow traffic counters
+func (s *MirrorStrategy) AdminPath() string { return MirrorAdminPath }
+
+// ServeHTTP returns the shadow traffic counters
+func (s *MirrorStrategy) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	admin.WriteJSON(w, s.Stats())
+}
+
+// NewMirror creates a new MirrorStrategy
+func NewMirror(config *service.Config, servers []service.Server, clients []service.Client) (service.Strategy, error) {
+	shadowDownstreams := make([]string, 0)
+	for _, downstream := range strings.Split(config.ExtraArguments[MirrorShadowDownstreamsArgName], ",") {
+		if strings.TrimSpace(downstream) != "" {
+			shadowDownstreams = append(shadowDownstreams, strings.TrimSpace(downstream))
+		}
+	}
+
+	var primaries []service.Client
+	var shadows []service.Client
+	for _, client := range clients {
+		isShadow := false
+		for _, downstream := range shadowDownstreams {
+			isShadow = isShadow || matchesDownstream(client, downstream)
+		}
+
+		if isShadow {
+			shadows = append(shadows, client)
+		} else {
+			primaries = append(primaries, client)
+		}
+	}
+
+	if len(primaries) != 1 || len(shadows) == 0 || len(servers) != 1 {
+		return nil, fmt.Errorf("strategy [%s] requires exactly one server, exactly one primary and at least one shadow downstream service, but had primaries [%v] shadows [%v] servers [%v] and configured as: %+v", MirrorStrategyName, primaries, shadows, servers, config)
+	}
+
+	shadowPercentage := 100
+	if arg := config.ExtraArguments[MirrorShadowPercentageArgName]; arg != "" {
+		percentage, err := strconv.Atoi(arg)
+		if err != nil || percentage < 0 || percentage > 100 {
+			return nil, fmt.Errorf("shadow percentage must be between 0 and 100, was [%s]", arg)
+		}
+		shadowPercentage = percentage
+	}
+
+	return &MirrorStrategy{
+		primary:          primaries[0],
+		shadows:          shadows,
+		shadowPercentage: shadowPercentage,
+	}, nil
+}
@@ -0,0 +1,119 @@
+package strategies
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	pb "github.com/buoyantio/bb/gen"
+	"github.com/buoyantio/bb/service"
+)
+
+func TestMirrorStrategy(t *testing.T) {
+	allServers := []service.Server{service.MockServer{}}
+
+	newStrategy := func(t *testing.T, shadowPercentage string, clients ...service.Client) *MirrorStrategy {
+		config := &service.Config{ExtraArguments: map[string]string{
+			MirrorShadowDownstreamsArgName: "shadow1:9091,shadow2:9092",
+			MirrorShadowPercentageArgName:  shadowPercentage,
+		}}
+		strategy, err := NewMirror(config, allServers, clients)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return strategy.(*MirrorStrategy)
+	}
+
+	waitForShadowRequests := func(t *testing.T, strategy *MirrorStrategy, expected int64) MirrorStats {
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			stats := strategy.Stats()
+			if stats.Successes+stats.Failures == expected {
+				return stats
+			}
+			time.Sleep(time.Millisecond)
+		}
+		t.Fatalf("Expected [%d] shadow requests to complete, but got %+v", expected, strategy.Stats())
+		return MirrorStats{}
+	}
+
+	t.Run("returns the primary's response and copies the request to every shadow", func(t *testing.T) {
+		shadowRequests := make(chan *pb.TheRequest, 2)
+		primary := &service.MockClient{IDToReturn: "primary:9090", ResponseToReturn: &pb.TheResponse{Payload: "primary"}}
+		shadow1 := &service.MockClient{
+			IDToReturn:         "shadow1:9091",
+			ResponseToReturn:   &pb.TheResponse{Payload: "shadow1"},
+			RequestInterceptor: func(req *pb.TheRequest) { shadowRequests <- req },
+		}
+		shadow2 := &service.MockClient{
+			IDToReturn:         "shadow2:9092",
+			ErrorToReturn:      errors.New("expected"),
+			RequestInterceptor: func(req *pb.TheRequest) { shadowRequests <- req },
+		}
+		strategy := newStrategy(t, "100", primary, shadow1, shadow2)
+
+		expectedRequest := &pb.TheRequest{RequestUID: "expected-req"}
+		resp, err := strategy.Do(context.TODO(), expectedRequest)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if resp.Payload != "primary" {
+			t.Fatalf("Expected response from [primary], but got [%s]", resp.Payload)
+		}
+
+		for i := 0; i < 2; i++ {
+			if actualRequest := <-shadowRequests; actualRequest != expectedRequest {
+				t.Fatalf("Expected shadows to receive request [%v], but got [%v]", expectedRequest, actualRequest)
+			}
+		}
+
+		stats := waitForShadowRequests(t, strategy, 2)
+		if stats.Requests != 2 || stats.Successes != 1 || stats.Failures != 1 {
+			t.Fatalf("Expected one successful and one failed shadow request, but got %+v", stats)
+		}
+	})
+
+	t.Run("does not fail when the primary succeeds but shadows fail", func(t *testing.T) {
+		primary := &service.MockClient{IDToReturn: "primary:9090", ResponseToReturn: &pb.TheResponse{Payload: "primary"}}
+		shadow := &service.MockClient{IDToReturn: "shadow1:9091", ErrorToReturn: errors.New("expected")}
+		strategy := newStrategy(t, "100", primary, shadow)
+
+		if _, err := strategy.Do(context.TODO(), &pb.TheRequest{RequestUID: "expected-req"}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		waitForShadowRequests(t, strategy, 1)
+	})
+
+	t.Run("does not copy requests when the shadow percentage is zero", func(t *testing.T) {
+		primary := &service.MockClient{IDToReturn: "primary:9090", ResponseToReturn: &pb.TheResponse{Payload: "primary"}}
+		shadow := &service.MockClient{IDToReturn: "shadow1:9091", ResponseToReturn: &pb.TheResponse{}}
+		strategy := newStrategy(t, "0", primary, shadow)
+
+		for i := 0; i < 100; i++ {
+			if _, err := strategy.Do(context.TODO(), &pb.TheRequest{RequestUID: "expected-req"}); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		}
+
+		if stats := strategy.Stats(); stats.Requests != 0 {
+			t.Fatalf("Expected no shadow requests, but got %+v", stats)
+		}
+	})
+
+	t.Run("requires exactly one primary and at least one shadow", func(t *testing.T) {
+		config := &service.Config{ExtraArguments: map[string]string{MirrorShadowDownstreamsArgName: "shadow1:9091"}}
+		invalidClients := [][]service.Client{
+			{&service.MockClient{IDToReturn: "primary:9090"}},
+			{&service.MockClient{IDToReturn: "shadow1:9091"}},
+			{&service.MockClient{IDToReturn: "primary:9090"}, &service.MockClient{IDToReturn: "other:9092"}, &service.MockClient{IDToReturn: "shadow1:9091"}},
+		}
+
+		for _, clients := range invalidClients {
+			if _, err := NewMirror(config, allServers, clients); err == nil {
+				t.Fatalf("Expecting error for clients %v, got nothing", clients)
+			}
+		}
+	})
+}
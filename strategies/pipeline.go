@@ -0,0 +1,84 @@
+package strategies
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	pb "github.com/buoyantio/bb/gen"
+	"github.com/buoyantio/bb/service"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// PipelineStrategyName is the user-friendly name of this strategy
+	PipelineStrategyName = "pipeline"
+
+	// PipelineResponseModeArgName is the parameter used to supply how the payloads of every stage make up the response
+	PipelineResponseModeArgName = "response-mode"
+
+	// PipelineConcatResponseMode returns the payloads from every stage, in order, separated by commas
+	PipelineConcatResponseMode = "concat"
+
+	// PipelineLastResponseMode returns the payload from the last stage, which received the previous stages' output
+	PipelineLastResponseMode = "last"
+)
+
+// PipelineStrategy is a strategy that calls each downstream service one after the other, feeding the payload
+// returned by each stage into the request sent to the next one.
+type PipelineStrategy struct {
+	clients      []service.Client
+	responseMode string
+}
+
+// Do executes the request
+func (s *PipelineStrategy) Do(ctx context.Context, req *pb.TheRequest) (*pb.TheResponse, error) {
+	payload := req.Payload
+	allResponsePayloads := make([]string, 0)
+	for i, client := range s.clients {
+		stageReq := &pb.TheRequest{
+			RequestUID: req.RequestUID,
+			Payload:    payload,
+		}
+
+		log.Infof("Making request to pipeline stage [%d] [%s]", i+1, client.GetID())
+		stageResp, err := client.Send(ctx, stageReq)
+		if err != nil {
+			log.Errorf("Error when sending request [%v] to pipeline stage [%d] [%s]: %v", req, i+1, client.GetID(), err)
+			return nil, fmt.Errorf("pipeline stage [%d] downstream server [%s] returned error: %v", i+1, client.GetID(), err)
+		}
+
+		log.Debugf("Received response from pipeline stage [%d] [%s]: %+v", i+1, client.GetID(), stageResp)
+		payload = stageResp.Payload
+		allResponsePayloads = append(allResponsePayloads, stageResp.Payload)
+	}
+
+	if s.responseMode == PipelineLastResponseMode {
+		return &pb.TheResponse{Payload: payload}, nil
+	}
+
+	return &pb.TheResponse{
+		Payload: strings.Join(allResponsePayloads, ","),
+	}, nil
+}
+
+// NewPipeline creates a new PipelineStrategy
+func NewPipeline(config *service.Config, servers []service.Server, clients []service.Client) (service.Strategy, error) {
+	if len(clients) == 0 || len(servers) != 1 {
+		return nil, fmt.Errorf("strategy [%s] requires exactly one server and at least one downstream service, but had clients [%v] servers [%v] and configured as: %+v", PipelineStrategyName, clients, servers, config)
+	}
+
+	responseMode := config.ExtraArguments[PipelineResponseModeArgName]
+	if responseMode == "" {
+		responseMode = PipelineConcatResponseMode
+	}
+
+	if responseMode != PipelineConcatResponseMode && responseMode != PipelineLastResponseMode {
+		return nil, fmt.Errorf("response mode [%s] isn't supported, must be [%s] or [%s]", responseMode, PipelineConcatResponseMode, PipelineLastResponseMode)
+	}
+
+	return &PipelineStrategy{
+		clients:      clients,
+		responseMode: responseMode,
+	}, nil
+}
@@ -0,0 +1,93 @@
+package strategies
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	pb "github.com/buoyantio/bb/gen"
+	"github.com/buoyantio/bb/service"
+)
+
+func TestPipelineStrategy(t *testing.T) {
+	allServers := []service.Server{service.MockServer{}}
+
+	t.Run("feeds each stage's response into the next stage's request and concatenates all payloads", func(t *testing.T) {
+		client1 := &service.MockClient{IDToReturn: "auth", ResponseToReturn: &pb.TheResponse{Payload: "token"}}
+		client2 := &service.MockClient{IDToReturn: "inventory", ResponseToReturn: &pb.TheResponse{Payload: "items"}}
+		client3 := &service.MockClient{IDToReturn: "pricing", ResponseToReturn: &pb.TheResponse{Payload: "prices"}}
+
+		strategy, err := NewPipeline(&service.Config{}, allServers, []service.Client{client1, client2, client3})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		response, err := strategy.Do(context.TODO(), &pb.TheRequest{RequestUID: "expected-req", Payload: "user"})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		expectedPayloads := map[*service.MockClient]string{client1: "user", client2: "token", client3: "items"}
+		for client, expectedPayload := range expectedPayloads {
+			if client.RequestReceived.Payload != expectedPayload {
+				t.Fatalf("Expected client [%s] to receive payload [%s], but got [%s]", client.GetID(), expectedPayload, client.RequestReceived.Payload)
+			}
+
+			if client.RequestReceived.RequestUID != "expected-req" {
+				t.Fatalf("Expected client [%s] to receive request UID [expected-req], but got [%s]", client.GetID(), client.RequestReceived.RequestUID)
+			}
+		}
+
+		expectedResponse := "token,items,prices"
+		if response.Payload != expectedResponse {
+			t.Fatalf("Expected response [%s], but got [%s]", expectedResponse, response.Payload)
+		}
+	})
+
+	t.Run("returns only the last stage's payload when configured to", func(t *testing.T) {
+		client1 := &service.MockClient{IDToReturn: "1", ResponseToReturn: &pb.TheResponse{Payload: "1"}}
+		client2 := &service.MockClient{IDToReturn: "2", ResponseToReturn: &pb.TheResponse{Payload: "2"}}
+
+		config := &service.Config{ExtraArguments: map[string]string{PipelineResponseModeArgName: PipelineLastResponseMode}}
+		strategy, err := NewPipeline(config, allServers, []service.Client{client1, client2})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		response, err := strategy.Do(context.TODO(), &pb.TheRequest{RequestUID: "expected-req"})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if response.Payload != "2" {
+			t.Fatalf("Expected response [2], but got [%s]", response.Payload)
+		}
+	})
+
+	t.Run("stops at the first error", func(t *testing.T) {
+		client1 := &service.MockClient{IDToReturn: "1", ErrorToReturn: errors.New("expected")}
+		client2 := &service.MockClient{IDToReturn: "2", ResponseToReturn: &pb.TheResponse{Payload: "2"}}
+
+		strategy, err := NewPipeline(&service.Config{}, allServers, []service.Client{client1, client2})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		response, err := strategy.Do(context.TODO(), &pb.TheRequest{RequestUID: "expected-req"})
+		if err == nil {
+			t.Fatalf("Expecting error, got response [%v]", response)
+		}
+
+		if client2.RequestReceived != nil {
+			t.Fatalf("Expected stage after the failure not to be called, but it received [%v]", client2.RequestReceived)
+		}
+	})
+
+	t.Run("rejects unknown response modes", func(t *testing.T) {
+		config := &service.Config{ExtraArguments: map[string]string{PipelineResponseModeArgName: "unknown"}}
+		_, err := NewPipeline(config, allServers, []service.Client{&service.MockClient{}})
+		if err == nil {
+			t.Fatalf("Expecting error, got nothing")
+		}
+	})
+}
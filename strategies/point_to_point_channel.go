@@ -8,27 +8,73 @@ import (
 	"github.com/buoyantio/bb/service"
 )
 
-// PointToPointStrategyName is the user-friendly name of this strategy
-const PointToPointStrategyName = "point-to-point-channel"
+const (
+	// PointToPointStrategyName is the user-friendly name of this strategy
+	PointToPointStrategyName = "point-to-point-channel"
 
-// PointToPointChannelStrategy is a strategy that takes a request and forwards it to a single downstream service.
+	// PointToPointLoadBalancerArgName is the parameter used to supply the algorithm used to pick a downstream service
+	PointToPointLoadBalancerArgName = "load-balancer"
+
+	// PointToPointDownstreamWeightArgName is the parameter used to supply the weights used by the weighted load balancer
+	PointToPointDownstreamWeightArgName = "downstream-weight"
+)
+
+// PointToPointChannelStrategy is a strategy that takes a request and forwards it to a single downstream service,
+// picked by a load balancer when more than one is configured. When hedging, the request is forwarded to the others too
+// if the first one is slow.
 type PointToPointChannelStrategy struct {
-	clients []service.Client
+	balancer loadBalancer
 }
 
 func (s *PointToPointChannelStrategy) Do(ctx context.Context, req *pb.TheRequest) (*pb.TheResponse, error) {
-	client := s.clients[0]
+	client := s.balancer.pick()
 	resp, err := client.Send(ctx, req)
 	return resp, err
 }
 
 // NewPointToPointChannel creates a new PointToPointChannelStrategy
 func NewPointToPointChannel(config *service.Config, servers []service.Server, clients []service.Client) (service.Strategy, error) {
-	if len(clients) != 1 || len(servers) != 1 {
-		return nil, fmt.Errorf("strategy [%s] requires exactly one server and one downstream service, but had clients [%v] servers [%v] and configured as: %+v", PointToPointStrategyName, clients, servers, config)
+	if len(clients) == 0 || len(servers) != 1 {
+		return nil, fmt.Errorf("strategy [%s] requires exactly one server and at least one downstream service, but had clients [%v] servers [%v] and configured as: %+v", PointToPointStrategyName, clients, servers, config)
+	}
+
+	if config.Hedging() {
+		algorithm := config.ExtraArguments[PointToPointLoadBalancerArgName]
+		if (algorithm != "" && algorithm != RoundRobinLoadBalancer) || config.ExtraArguments[PointToPointDownstreamWeightArgName] != "" {
+			return nil, fmt.Errorf("hedged requests are sent to each downstream service in turn, so [%s] can only be [%s] and [%s] can't be set", PointToPointLoadBalancerArgName, RoundRobinLoadBalancer, PointToPointDownstreamWeightArgName)
+		}
+
+		policy, err := service.NewHedgePolicy(config)
+		if err != nil {
+			return nil, err
+		}
+		clients = []service.Client{service.MakeHedged(clients, policy)}
+	}
+
+	algorithm := config.ExtraArguments[PointToPointLoadBalancerArgName]
+	if algorithm != WeightedLoadBalancer && config.ExtraArguments[PointToPointDownstreamWeightArgName] != "" {
+		if algorithm == "" {
+			algorithm = RoundRobinLoadBalancer
+		}
+		return nil, fmt.Errorf("[%s] only applies to the [%s] load balancer, but [%s] was [%s]", PointToPointDownstreamWeightArgName, WeightedLoadBalancer, PointToPointLoadBalancerArgName, algorithm)
+	}
+
+	weights, err := parseDownstreamWeights(config.ExtraArguments[PointToPointDownstreamWeightArgName])
+	if err != nil {
+		return nil, err
+	}
+
+	balancedClients, err := newBalancedClients(clients, weights)
+	if err != nil {
+		return nil, err
+	}
+
+	balancer, err := newLoadBalancer(algorithm, balancedClients)
+	if err != nil {
+		return nil, err
 	}
 
 	return &PointToPointChannelStrategy{
-		clients: clients,
+		balancer: balancer,
 	}, nil
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	pb "github.com/buoyantio/bb/gen"
 	"github.com/buoyantio/bb/service"
@@ -60,4 +61,96 @@ func TestPointToPointChannelStrategy(t *testing.T) {
 			t.Fatalf("Expected client [%s] to receive request [%v], but got [%v]", mockClient.GetID(), expectedRequest, actualRequest)
 		}
 	})
+
+	t.Run("spreads requests across all downstream services when more than one is configured", func(t *testing.T) {
+		client1 := &service.MockClient{IDToReturn: "1", ResponseToReturn: &pb.TheResponse{Payload: "1"}}
+		client2 := &service.MockClient{IDToReturn: "2", ResponseToReturn: &pb.TheResponse{Payload: "2"}}
+
+		strategy, err := NewPointToPointChannel(&service.Config{}, []service.Server{service.MockServer{}}, []service.Client{client1, client2})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		payloads := map[string]int{}
+		for i := 0; i < 4; i++ {
+			resp, err := strategy.Do(context.TODO(), &pb.TheRequest{RequestUID: "expected-req"})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			payloads[resp.Payload]++
+		}
+
+		if payloads["1"] != 2 || payloads["2"] != 2 {
+			t.Fatalf("Expected requests to be evenly spread across clients, but got %v", payloads)
+		}
+	})
+
+	t.Run("hedges requests across its downstream services", func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+
+		slow := &service.MockClient{IDToReturn: "slow", ResponseToReturn: &pb.TheResponse{Payload: "slow"}, RequestInterceptor: func(*pb.TheRequest) { <-release }}
+		fast := &service.MockClient{IDToReturn: "fast", ResponseToReturn: &pb.TheResponse{Payload: "fast"}}
+		config := &service.Config{HedgeDelay: time.Millisecond * 10, HedgeMaxAttempts: 2}
+
+		strategy, err := NewPointToPointChannel(config, []service.Server{service.MockServer{}}, []service.Client{slow, fast})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		resp, err := strategy.Do(context.TODO(), &pb.TheRequest{RequestUID: "expected-req"})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if resp.Payload != "fast" {
+			t.Fatalf("Expected the hedged request to be answered by [fast], got [%s]", resp.Payload)
+		}
+
+		config.ExtraArguments = map[string]string{PointToPointLoadBalancerArgName: RoundRobinLoadBalancer}
+		if _, err := NewPointToPointChannel(config, []service.Server{service.MockServer{}}, []service.Client{slow, fast}); err != nil {
+			t.Fatalf("Unexpected error when hedging with the round-robin load balancer: %v", err)
+		}
+
+		config.ExtraArguments = map[string]string{PointToPointLoadBalancerArgName: RandomLoadBalancer}
+		_, err = NewPointToPointChannel(config, []service.Server{service.MockServer{}}, []service.Client{slow, fast})
+		if err == nil {
+			t.Fatalf("Expecting error when hedging with a load balancer, got nothing")
+		}
+	})
+
+	t.Run("rejects configuration without downstream services or with an unknown load balancer", func(t *testing.T) {
+		_, err := NewPointToPointChannel(&service.Config{}, []service.Server{service.MockServer{}}, []service.Client{})
+		if err == nil {
+			t.Fatalf("Expecting error when no clients are configured, got nothing")
+		}
+
+		config := &service.Config{
+			ExtraArguments: map[string]string{PointToPointLoadBalancerArgName: "unknown"},
+		}
+		_, err = NewPointToPointChannel(config, []service.Server{service.MockServer{}}, []service.Client{&service.MockClient{}})
+		if err == nil {
+			t.Fatalf("Expecting error when load balancer is unknown, got nothing")
+		}
+	})
+
+	t.Run("rejects downstream weights unless using the weighted load balancer", func(t *testing.T) {
+		clients := []service.Client{&service.MockClient{IDToReturn: "1"}, &service.MockClient{IDToReturn: "2"}}
+		for _, algorithm := range []string{"", RoundRobinLoadBalancer, LeastOutstandingRequestsLoadBalancer} {
+			config := &service.Config{ExtraArguments: map[string]string{
+				PointToPointLoadBalancerArgName:     algorithm,
+				PointToPointDownstreamWeightArgName: "1=3",
+			}}
+			if _, err := NewPointToPointChannel(config, []service.Server{service.MockServer{}}, clients); err == nil {
+				t.Fatalf("Expecting error when setting weights with load balancer [%s], got nothing", algorithm)
+			}
+		}
+
+		config := &service.Config{ExtraArguments: map[string]string{
+			PointToPointLoadBalancerArgName:     WeightedLoadBalancer,
+			PointToPointDownstreamWeightArgName: "1=3",
+		}}
+		if _, err := NewPointToPointChannel(config, []service.Server{service.MockServer{}}, clients); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
 }
@@ -0,0 +1,161 @@
+package strategies
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	pb "github.com/buoyantio/bb/gen"
+	"github.com/buoyantio/bb/service"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// RouterStrategyName is the user-friendly name of this strategy
+	RouterStrategyName = "router"
+
+	// RouterRoutesArgName is the parameter used to supply the routing rules, one per line
+	RouterRoutesArgName = "route"
+
+	// RouterDefaultRouteArgName is the parameter used to supply the downstream service used when no rule matches
+	RouterDefaultRouteArgName = "default-route"
+
+	routeDownstreamSeparator = "->"
+)
+
+// routeRule sends requests whose field matches a regular expression to a downstream service.
+type routeRule struct {
+	field      string
+	pattern    *regexp.Regexp
+	downstream string
+	client     service.Client
+}
+
+func (r *routeRule) matches(req *pb.TheRequest, inbound *service.InboundRequest) bool {
+	var values []string
+	switch {
+	case r.field == "uid":
+		values = []string{req.RequestUID}
+	case inbound == nil:
+		return false
+	case r.field == "path":
+		values = []string{inbound.Path}
+	case r.field == "method":
+		values = []string{inbound.Method}
+	case strings.HasPrefix(r.field, "header:"):
+		values = inbound.Header(strings.TrimPrefix(r.field, "header:"))
+	case strings.HasPrefix(r.field, "metadata:"):
+		values = inbound.Header(strings.TrimPrefix(r.field, "metadata:"))
+	}
+
+	for _, value := range values {
+		if r.pattern.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// RouterStrategy is a strategy that forwards each request to the downstream service of the first rule matching it.
+type RouterStrategy struct {
+	rules        []*routeRule
+	defaultRoute service.Client
+}
+
+// Do executes the request
+func (s *RouterStrategy) Do(ctx context.Context, req *pb.TheRequest) (*pb.TheResponse, error) {
+	inbound, _ := service.InboundRequestFromContext(ctx)
+	for _, rule := range s.rules {
+		if rule.matches(req, inbound) {
+			log.Debugf("Request UID [%s] matched rule [%s=%s], routing to [%s]", req.RequestUID, rule.field, rule.pattern, rule.client.GetID())
+			return rule.client.Send(ctx, req)
+		}
+	}
+
+	if s.defaultRoute == nil {
+		return nil, fmt.Errorf("no route matched request UID [%s] and no default route is configured", req.RequestUID)
+	}
+
+	log.Debugf("Request UID [%s] matched no rules, routing to default [%s]", req.RequestUID, s.defaultRoute.GetID())
+	return s.defaultRoute.Send(ctx, req)
+}
+
+func findClientForDownstream(clients []service.Client, downstream string) (service.Client, error) {
+	for _, client := range clients {
+		if matchesDownstream(client, downstream) {
+			return client, nil
+		}
+	}
+	return nil, fmt.Errorf("route to [%s], which isn't one of the downstream services", downstream)
+}
+
+// parseRouteRule parses a rule in the format field=regex->downstream, where field is uid, path, method,
+// header:<name> or metadata:<name>
+func parseRouteRule(route string) (*routeRule, error) {
+	downstreamSeparator := strings.LastIndex(route, routeDownstreamSeparator)
+	fieldSeparator := strings.Index(route, "=")
+	if downstreamSeparator < 0 || fieldSeparator < 0 || fieldSeparator > downstreamSeparator {
+		return nil, fmt.Errorf("route [%s] must be in the format field=regex%sdownstream", route, routeDownstreamSeparator)
+	}
+
+	field := route[:fieldSeparator]
+	isHeader := strings.HasPrefix(field, "header:") || strings.HasPrefix(field, "metadata:")
+	if field != "uid" && field != "path" && field != "method" && !isHeader {
+		return nil, fmt.Errorf("field in route [%s] must be uid, path, method, header:<name> or metadata:<name>, was [%s]", route, field)
+	}
+
+	pattern, err := regexp.Compile(route[fieldSeparator+1 : downstreamSeparator])
+	if err != nil {
+		return nil, fmt.Errorf("error while parsing regex in route [%s]: %v", route, err)
+	}
+
+	return &routeRule{
+		field:      field,
+		pattern:    pattern,
+		downstream: strings.TrimSpace(route[downstreamSeparator+len(routeDownstreamSeparator):]),
+	}, nil
+}
+
+// NewRouter creates a new RouterStrategy
+func NewRouter(config *service.Config, servers []service.Server, clients []service.Client) (service.Strategy, error) {
+	if len(clients) == 0 || len(servers) != 1 {
+		return nil, fmt.Errorf("strategy [%s] requires exactly one server and at least one downstream service, but had clients [%v] servers [%v] and configured as: %+v", RouterStrategyName, clients, servers, config)
+	}
+
+	rules := make([]*routeRule, 0)
+	for _, route := range strings.Split(config.ExtraArguments[RouterRoutesArgName], "\n") {
+		if strings.TrimSpace(route) == "" {
+			continue
+		}
+
+		rule, err := parseRouteRule(route)
+		if err != nil {
+			return nil, err
+		}
+
+		rule.client, err = findClientForDownstream(clients, rule.downstream)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+
+	var defaultRoute service.Client
+	if downstream := config.ExtraArguments[RouterDefaultRouteArgName]; downstream != "" {
+		client, err := findClientForDownstream(clients, downstream)
+		if err != nil {
+			return nil, err
+		}
+		defaultRoute = client
+	}
+
+	if len(rules) == 0 && defaultRoute == nil {
+		return nil, fmt.Errorf("strategy [%s] requires at least one route or a default route", RouterStrategyName)
+	}
+
+	return &RouterStrategy{
+		rules:        rules,
+		defaultRoute: defaultRoute,
+	}, nil
+}
@@ -0,0 +1,114 @@
+package strategies
+
+import (
+	"context"
+	"testing"
+
+	pb "github.com/buoyantio/bb/gen"
+	"github.com/buoyantio/bb/service"
+)
+
+func TestRouterStrategy(t *testing.T) {
+	allServers := []service.Server{service.MockServer{}}
+
+	newClients := func() []service.Client {
+		return []service.Client{
+			&service.MockClient{IDToReturn: "gold:9090", ResponseToReturn: &pb.TheResponse{Payload: "gold"}},
+			&service.MockClient{IDToReturn: "admin:9091", ResponseToReturn: &pb.TheResponse{Payload: "admin"}},
+			&service.MockClient{IDToReturn: "proxy:4140 / default:9092", ResponseToReturn: &pb.TheResponse{Payload: "default"}},
+		}
+	}
+
+	newStrategy := func(t *testing.T, routes string, defaultRoute string) service.Strategy {
+		config := &service.Config{ExtraArguments: map[string]string{
+			RouterRoutesArgName:       routes,
+			RouterDefaultRouteArgName: defaultRoute,
+		}}
+		strategy, err := NewRouter(config, allServers, newClients())
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return strategy
+	}
+
+	t.Run("routes requests to the downstream of the first matching rule", func(t *testing.T) {
+		routes := "header:x-tenant=^gold$->gold:9090\npath=^/admin->admin:9091\nmethod=DELETE->admin:9091\nuid=^canary-->gold:9090"
+		strategy := newStrategy(t, routes, "default:9092")
+
+		testCases := []struct {
+			name     string
+			req      *pb.TheRequest
+			inbound  *service.InboundRequest
+			expected string
+		}{
+			{"header", &pb.TheRequest{RequestUID: "1"}, service.NewInboundRequest("http", "GET", "/admin", map[string][]string{"X-Tenant": {"gold"}}), "gold"},
+			{"path", &pb.TheRequest{RequestUID: "2"}, service.NewInboundRequest("http", "GET", "/admin/users", nil), "admin"},
+			{"method", &pb.TheRequest{RequestUID: "3"}, service.NewInboundRequest("http", "DELETE", "/", nil), "admin"},
+			{"request UID", &pb.TheRequest{RequestUID: "canary-4"}, nil, "gold"},
+			{"gRPC metadata", &pb.TheRequest{RequestUID: "5"}, service.NewInboundRequest("grpc", "/buoyantio.bb.TheService/theFunction", "/buoyantio.bb.TheService/theFunction", map[string][]string{"x-tenant": {"silver", "gold"}}), "gold"},
+			{"no match", &pb.TheRequest{RequestUID: "6"}, service.NewInboundRequest("http", "GET", "/", map[string][]string{"X-Tenant": {"silver"}}), "default"},
+		}
+
+		for _, tc := range testCases {
+			ctx := context.TODO()
+			if tc.inbound != nil {
+				ctx = service.WithInboundRequest(ctx, tc.inbound)
+			}
+
+			resp, err := strategy.Do(ctx, tc.req)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if resp.Payload != tc.expected {
+				t.Fatalf("Expected request matching on %s to be routed to [%s], but got [%s]", tc.name, tc.expected, resp.Payload)
+			}
+		}
+	})
+
+	t.Run("metadata rules match headers regardless of their case", func(t *testing.T) {
+		strategy := newStrategy(t, "metadata:X-Tenant=gold->gold:9090", "")
+		ctx := service.WithInboundRequest(context.TODO(), service.NewInboundRequest("http", "GET", "/", map[string][]string{"X-TENANT": {"gold"}}))
+
+		resp, err := strategy.Do(ctx, &pb.TheRequest{RequestUID: "1"})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if resp.Payload != "gold" {
+			t.Fatalf("Expected request to be routed to [gold], but got [%s]", resp.Payload)
+		}
+	})
+
+	t.Run("returns error if no rule matches and there is no default route", func(t *testing.T) {
+		strategy := newStrategy(t, "header:x-tenant=gold->gold:9090", "")
+
+		_, err := strategy.Do(context.TODO(), &pb.TheRequest{RequestUID: "1"})
+		if err == nil {
+			t.Fatalf("Expecting error, got nothing")
+		}
+	})
+
+	t.Run("returns error if misconfigured", func(t *testing.T) {
+		invalidConfigs := []map[string]string{
+			{},
+			{RouterRoutesArgName: "header:x-tenant=gold"},
+			{RouterRoutesArgName: "x-tenant=gold->gold:9090"},
+			{RouterRoutesArgName: "path=[->gold:9090"},
+			{RouterRoutesArgName: "path=/->unknown:9999"},
+			{RouterDefaultRouteArgName: "unknown:9999"},
+		}
+
+		for _, args := range invalidConfigs {
+			_, err := NewRouter(&service.Config{ExtraArguments: args}, allServers, newClients())
+			if err == nil {
+				t.Fatalf("Expecting error for config %v, got nothing", args)
+			}
+		}
+
+		_, err := NewRouter(&service.Config{ExtraArguments: map[string]string{RouterDefaultRouteArgName: "gold:9090"}}, allServers, []service.Client{})
+		if err == nil {
+			t.Fatalf("Expecting error, got nothing")
+		}
+	})
+}
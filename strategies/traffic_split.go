@@ -0,0 +1,172 @@
+package strategies
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/buoyantio/bb/admin"
+	pb "github.com/buoyantio/bb/gen"
+	"github.com/buoyantio/bb/service"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// TrafficSplitStrategyName is the user-friendly name of this strategy
+	TrafficSplitStrategyName = "traffic-split"
+
+	// TrafficSplitArgName is the parameter used to supply the weight of each downstream service
+	TrafficSplitArgName = "split"
+
+	// TrafficSplitAdminPath is where the admin server exposes the traffic split weights
+	TrafficSplitAdminPath = "/traffic-split"
+)
+
+// errAllWeightsZero is returned when no downstream service would be sent any requests
+var errAllWeightsZero = errors.New("the weight of at least one downstream service must be greater than zero, but all were zero")
+
+// TrafficSplitStrategy is a strategy that forwards each request to a single downstream service, picked at random
+// proportionally to weights that can be changed at runtime.
+type TrafficSplitStrategy struct {
+	clients  []*balancedClient
+	balancer loadBalancer
+}
+
+// Do executes the request
+func (s *TrafficSplitStrategy) Do(ctx context.Context, req *pb.TheRequest) (*pb.TheResponse, error) {
+	client := s.balancer.pick()
+	log.Debugf("Traffic split sending request UID [%s] to [%s]", req.RequestUID, client.GetID())
+	return client.Send(ctx, req)
+}
+
+// Weights returns the current weight of each downstream service, by client ID
+func (s *TrafficSplitStrategy) Weights() map[string]int64 {
+	weights := map[string]int64{}
+	for _, c := range s.clients {
+		weights[c.GetID()] = c.getWeight()
+	}
+	return weights
+}
+
+// SetWeights changes the weights of the downstream services supplied, leaving all others untouched. At least one
+// downstream service must be left with a weight greater than zero.
+func (s *TrafficSplitStrategy) SetWeights(weights map[string]int64) error {
+	for downstream, weight := range weights {
+		if weight < 0 {
+			return fmt.Errorf("weight for [%s] must be a non-negative integer, was [%d]", downstream, weight)
+		}
+
+		found := false
+		for _, c := range s.clients {
+			found = found || matchesDownstream(c.Client, downstream)
+		}
+		if !found {
+			return fmt.Errorf("weight configured for [%s], which isn't one of the downstream services", downstream)
+		}
+	}
+
+	var total int64
+	for _, c := range s.clients {
+		weight := c.getWeight()
+		for downstream, changed := range weights {
+			if matchesDownstream(c.Client, downstream) {
+				weight = changed
+			}
+		}
+		total += weight
+	}
+	if total == 0 {
+		return errAllWeightsZero
+	}
+
+	for downstream, weight := range weights {
+		for _, c := range s.clients {
+			if matchesDownstream(c.Client, downstream) {
+				atomic.StoreInt64(&c.weight, weight)
+			}
+		}
+	}
+
+	log.Infof("Traffic split weights changed to: %v", s.Weights())
+	return nil
+}
+
+// AdminPath is where the admin server exposes the traffic split weights
+func (s *TrafficSplitStrategy) AdminPath() string { return TrafficSplitAdminPath }
+
+// ServeHTTP returns the current weights on GET, and changes them to the JSON object sent on PUT or POST
+func (s *TrafficSplitStrategy) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		var weights map[string]int64
+		if !admin.ReadJSON(w, req, &weights) {
+			return
+		}
+		if err := s.SetWeights(weights); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, fmt.Sprintf("method [%s] not allowed", req.Method), http.StatusMethodNotAllowed)
+		return
+	}
+
+	admin.WriteJSON(w, s.Weights())
+}
+
+// ParseTrafficSplit parses a split in the format protocol:downstream=weight, where protocol is either grpc or h1
+func ParseTrafficSplit(split string) (string, string, int64, error) {
+	separator := strings.LastIndex(split, "=")
+	protocolSeparator := strings.Index(split, ":")
+	if separator < 0 || protocolSeparator < 0 || protocolSeparator > separator {
+		return "", "", 0, fmt.Errorf("split [%s] must be in the format protocol:downstream=weight", split)
+	}
+
+	protocol := split[:protocolSeparator]
+	if protocol != "grpc" && protocol != "h1" {
+		return "", "", 0, fmt.Errorf("protocol in split [%s] must be grpc or h1, was [%s]", split, protocol)
+	}
+
+	downstream := split[protocolSeparator+1 : separator]
+	weight, err := strconv.ParseInt(split[separator+1:], 10, 64)
+	if err != nil || weight < 0 {
+		return "", "", 0, fmt.Errorf("weight in split [%s] must be a non-negative integer", split)
+	}
+
+	return protocol, downstream, weight, nil
+}
+
+// NewTrafficSplit creates a new TrafficSplitStrategy
+func NewTrafficSplit(config *service.Config, servers []service.Server, clients []service.Client) (service.Strategy, error) {
+	if len(clients) == 0 || len(servers) != 1 {
+		return nil, fmt.Errorf("strategy [%s] requires exactly one server and at least one downstream service, but had clients [%v] servers [%v] and configured as: %+v", TrafficSplitStrategyName, clients, servers, config)
+	}
+
+	weights, err := parseDownstreamWeights(config.ExtraArguments[TrafficSplitArgName])
+	if err != nil {
+		return nil, err
+	}
+
+	balancedClients, err := newBalancedClients(clients, weights)
+	if err != nil {
+		return nil, err
+	}
+
+	var total int64
+	for _, c := range balancedClients {
+		total += c.getWeight()
+	}
+	if total == 0 {
+		return nil, errAllWeightsZero
+	}
+
+	return &TrafficSplitStrategy{
+		clients:  balancedClients,
+		balancer: &weightedBalancer{clients: balancedClients},
+	}, nil
+}
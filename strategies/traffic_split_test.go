@@ -0,0 +1,133 @@
+package strategies
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	pb "github.com/buoyantio/bb/gen"
+	"github.com/buoyantio/bb/service"
+)
+
+func TestTrafficSplitStrategy(t *testing.T) {
+	allServers := []service.Server{service.MockServer{}}
+
+	newStrategy := func(t *testing.T, split string, clients ...service.Client) *TrafficSplitStrategy {
+		config := &service.Config{ExtraArguments: map[string]string{TrafficSplitArgName: split}}
+		strategy, err := NewTrafficSplit(config, allServers, clients)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return strategy.(*TrafficSplitStrategy)
+	}
+
+	t.Run("sends requests only to downstream services with weight", func(t *testing.T) {
+		v1 := &service.MockClient{IDToReturn: "v1:9090", ResponseToReturn: &pb.TheResponse{Payload: "v1"}}
+		v2 := &service.MockClient{IDToReturn: "v2:9091", ResponseToReturn: &pb.TheResponse{Payload: "v2"}}
+		strategy := newStrategy(t, "v1:9090=100,v2:9091=0", v1, v2)
+
+		for i := 0; i < 100; i++ {
+			resp, err := strategy.Do(context.TODO(), &pb.TheRequest{RequestUID: "expected-req"})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if resp.Payload != "v1" {
+				t.Fatalf("Expected all requests to go to [v1], but got [%s]", resp.Payload)
+			}
+		}
+	})
+
+	t.Run("changes weights at runtime via the admin endpoint", func(t *testing.T) {
+		v1 := &service.MockClient{IDToReturn: "v1:9090", ResponseToReturn: &pb.TheResponse{Payload: "v1"}}
+		v2 := &service.MockClient{IDToReturn: "v2:9091", ResponseToReturn: &pb.TheResponse{Payload: "v2"}}
+		strategy := newStrategy(t, "v1:9090=100,v2:9091=0", v1, v2)
+
+		adminServer := httptest.NewServer(strategy)
+		defer adminServer.Close()
+
+		req, err := http.NewRequest(http.MethodPut, adminServer.URL, strings.NewReader(`{"v1:9090": 0, "v2:9091": 100}`))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expecting response to have status [%d] but was: %v", http.StatusOK, resp)
+		}
+
+		var weights map[string]int64
+		if err := json.NewDecoder(resp.Body).Decode(&weights); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if weights["v1:9090"] != 0 || weights["v2:9091"] != 100 {
+			t.Fatalf("Expected admin endpoint to return the new weights, but got %v", weights)
+		}
+
+		actualResp, err := strategy.Do(context.TODO(), &pb.TheRequest{RequestUID: "expected-req"})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if actualResp.Payload != "v2" {
+			t.Fatalf("Expected request to go to [v2], but got [%s]", actualResp.Payload)
+		}
+	})
+
+	t.Run("rejects weights for unknown downstream services", func(t *testing.T) {
+		strategy := newStrategy(t, "v1:9090=100", &service.MockClient{IDToReturn: "v1:9090"})
+
+		adminServer := httptest.NewServer(strategy)
+		defer adminServer.Close()
+
+		resp, err := http.Post(adminServer.URL, "application/json", strings.NewReader(`{"v3:9092": 10}`))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("Expecting response to have status [%d] but was: %v", http.StatusBadRequest, resp)
+		}
+	})
+
+	t.Run("rejects weights that are all zero", func(t *testing.T) {
+		v1 := &service.MockClient{IDToReturn: "v1:9090"}
+		v2 := &service.MockClient{IDToReturn: "v2:9091"}
+
+		config := &service.Config{ExtraArguments: map[string]string{TrafficSplitArgName: "v1:9090=0,v2:9091=0"}}
+		if _, err := NewTrafficSplit(config, allServers, []service.Client{v1, v2}); err == nil {
+			t.Fatalf("Expecting error, got nothing")
+		}
+
+		strategy := newStrategy(t, "v1:9090=100,v2:9091=0", v1, v2)
+		if err := strategy.SetWeights(map[string]int64{"v1:9090": 0}); err == nil {
+			t.Fatalf("Expecting error, got nothing")
+		}
+
+		if weights := strategy.Weights(); weights["v1:9090"] != 100 {
+			t.Fatalf("Expected weights to be left untouched, but got %v", weights)
+		}
+	})
+
+	t.Run("parses splits", func(t *testing.T) {
+		protocol, downstream, weight, err := ParseTrafficSplit("h1:http://v2:9091=10")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if protocol != "h1" || downstream != "http://v2:9091" || weight != 10 {
+			t.Fatalf("Expected split to be parsed as [h1] [http://v2:9091] [10], but got [%s] [%s] [%d]", protocol, downstream, weight)
+		}
+
+		for _, malformed := range []string{"grpc:v1:9090", "v1:9090=10", "tcp:v1:9090=10", "grpc:v1:9090=-1"} {
+			if _, _, _, err := ParseTrafficSplit(malformed); err == nil {
+				t.Fatalf("Expecting error for split [%s], got nothing", malformed)
+			}
+		}
+	})
+}
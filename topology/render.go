@@ -0,0 +1,420 @@
+package topology
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	pb "github.com/buoyantio/bb/gen"
+	"github.com/buoyantio/bb/service"
+	"github.com/buoyantio/bb/strategies"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// NoMesh renders manifests without any service mesh annotations
+	NoMesh = "none"
+
+	// LinkerdMesh renders manifests annotated for Linkerd to inject its proxy
+	LinkerdMesh = "linkerd"
+
+	// IstioMesh renders manifests annotated for Istio to inject its sidecar
+	IstioMesh = "istio"
+
+	defaultGRPCPort  = 9090
+	defaultH1Port    = 8080
+	defaultAdminPort = 9990
+
+	// scenarioDirectory is where the scenario of a node is mounted from its ConfigMap
+	scenarioDirectory = "/etc/bb"
+	scenarioFile      = "scenario.yaml"
+)
+
+// Meshes lists the service meshes manifests can be annotated for
+var Meshes = []string{NoMesh, LinkerdMesh, IstioMesh}
+
+var meshAnnotations = map[string]map[string]string{
+	NoMesh:      {},
+	LinkerdMesh: {"linkerd.io/inject": "enabled"},
+	IstioMesh:   {"sidecar.istio.io/inject": "true"},
+}
+
+// singleNodeReference matches arguments that are nothing but a reference to a node, such as ${node}
+var singleNodeReference = regexp.MustCompile(`^\$\{([^}]*)\}$`)
+
+// RenderOptions controls how a topology is rendered as Kubernetes manifests
+type RenderOptions struct {
+	Namespace string
+	Image     string
+	Mesh      string
+}
+
+type renderedPort struct {
+	Name string
+	Port int
+}
+
+// renderedProbe checks a node's health via HTTP if it has a HTTP 1.1 or admin server, and via gRPC otherwise
+type renderedProbe struct {
+	HTTPPort         int
+	GRPCPort         int
+	GRPCReadyService string
+}
+
+type renderedNode struct {
+	Name        string
+	Replicas    int
+	Args        []string
+	Ports       []renderedPort
+	ServiceType string
+	Probe       *renderedProbe
+	Scenario    string
+}
+
+var kubernetesTemplate = template.Must(template.New("k8s").Funcs(template.FuncMap{"quote": strconv.Quote, "indent": indent}).Parse(`---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: {{.Namespace}}
+{{- range .Nodes}}
+{{- if .Scenario}}
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{$.Namespace}}-{{.Name}}-scenario
+  namespace: {{$.Namespace}}
+data:
+  {{$.ScenarioFile}}: |
+{{indent 4 .Scenario}}
+{{- end}}
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: {{$.Namespace}}-{{.Name}}
+  namespace: {{$.Namespace}}
+spec:
+  replicas: {{.Replicas}}
+  selector:
+    matchLabels:
+      app: {{$.Namespace}}-{{.Name}}
+  template:
+    metadata:
+      labels:
+        app: {{$.Namespace}}-{{.Name}}
+{{- if $.Annotations}}
+      annotations:
+{{- range $name, $value := $.Annotations}}
+        {{$name}}: {{quote $value}}
+{{- end}}
+{{- end}}
+    spec:
+      containers:
+      - name: bb
+        image: {{$.Image}}
+        args: [{{range $i, $arg := .Args}}{{if $i}}, {{end}}{{quote $arg}}{{end}}]
+{{- if .Ports}}
+        ports:
+{{- range .Ports}}
+        - containerPort: {{.Port}}
+{{- end}}
+{{- end}}
+{{- with .Probe}}
+{{- if .HTTPPort}}
+        livenessProbe:
+          httpGet:
+            path: {{$.HealthzPath}}
+            port: {{.HTTPPort}}
+        readinessProbe:
+          httpGet:
+            path: {{$.ReadyzPath}}
+            port: {{.HTTPPort}}
+{{- else}}
+        livenessProbe:
+          grpc:
+            port: {{.GRPCPort}}
+        readinessProbe:
+          grpc:
+            port: {{.GRPCPort}}
+            service: {{.GRPCReadyService}}
+{{- end}}
+{{- end}}
+{{- if .Scenario}}
+        volumeMounts:
+        - name: scenario
+          mountPath: {{$.ScenarioDirectory}}
+      volumes:
+      - name: scenario
+        configMap:
+          name: {{$.Namespace}}-{{.Name}}-scenario
+{{- end}}
+{{- if .Ports}}
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: {{$.Namespace}}-{{.Name}}-svc
+  namespace: {{$.Namespace}}
+spec:
+{{- if .ServiceType}}
+  type: {{.ServiceType}}
+{{- end}}
+  selector:
+    app: {{$.Namespace}}-{{.Name}}
+  ports:
+{{- range .Ports}}
+  - name: {{.Name}}
+    port: {{.Port}}
+    targetPort: {{.Port}}
+{{- end}}
+{{- end}}
+{{- end}}
+`))
+
+// RenderKubernetes writes the Namespace, and a Deployment and Service for every node, needed to run the topology in a
+// Kubernetes cluster. Each node is reached via its Service, and ports not set in the topology default to 9090 for gRPC,
+// 8080 for HTTP 1.1 and 9990 for the admin server. Pods are probed via their health checks, and the scenario of a node
+// is mounted from a ConfigMap of its own.
+func (t *Topology) RenderKubernetes(w io.Writer, options *RenderOptions) error {
+	namespace := options.Namespace
+	if namespace == "" {
+		namespace = t.Name
+	}
+	if !names.MatchString(namespace) {
+		return fmt.Errorf("namespace [%s] must be set and consist of lower case alphanumeric characters or '-'", namespace)
+	}
+
+	annotations, ok := meshAnnotations[options.Mesh]
+	if !ok {
+		return fmt.Errorf("mesh [%s] must be one of: %s", options.Mesh, strings.Join(Meshes, ", "))
+	}
+
+	ports, err := t.ports(func(server string) (int, error) {
+		switch server {
+		case GRPCProtocol:
+			return defaultGRPCPort, nil
+		case H1Protocol:
+			return defaultH1Port, nil
+		default:
+			return defaultAdminPort, nil
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	serviceHost := func(name string) string { return fmt.Sprintf("%s-%s-svc", namespace, name) }
+	nodes := make([]*renderedNode, 0, len(t.Nodes))
+	for _, node := range t.Nodes {
+		args, err := t.args(node, ports, serviceHost)
+		if err != nil {
+			return fmt.Errorf("node [%s]: %v", node.Name, err)
+		}
+
+		replicas := node.Replicas
+		if replicas == 0 {
+			replicas = 1
+		}
+
+		rendered := &renderedNode{
+			Name:        node.Name,
+			Replicas:    replicas,
+			Args:        args,
+			ServiceType: node.ServiceType,
+		}
+		for _, port := range []renderedPort{{"grpc", ports[node.Name].grpc}, {"http", ports[node.Name].h1}, {"admin", ports[node.Name].admin}} {
+			if port.Port != -1 {
+				rendered.Ports = append(rendered.Ports, port)
+			}
+		}
+
+		switch {
+		case ports[node.Name].h1 != -1:
+			rendered.Probe = &renderedProbe{HTTPPort: ports[node.Name].h1}
+		case ports[node.Name].admin != -1:
+			rendered.Probe = &renderedProbe{HTTPPort: ports[node.Name].admin}
+		case ports[node.Name].grpc != -1:
+			rendered.Probe = &renderedProbe{GRPCPort: ports[node.Name].grpc, GRPCReadyService: pb.TheService_ServiceDesc.ServiceName}
+		}
+
+		if node.Scenario != nil {
+			data, err := yaml.Marshal(node.Scenario)
+			if err != nil {
+				return fmt.Errorf("node [%s] scenario: %v", node.Name, err)
+			}
+			rendered.Scenario = string(data)
+			rendered.Args = append(rendered.Args, "--scenario", path.Join(scenarioDirectory, scenarioFile))
+		}
+		nodes = append(nodes, rendered)
+	}
+
+	return kubernetesTemplate.Execute(w, map[string]interface{}{
+		"Namespace":   namespace,
+		"Image":       options.Image,
+		"Annotations": annotations,
+		"Nodes":       nodes,
+		"HealthzPath": service.HealthzPath,
+		"ReadyzPath":  service.ReadyzPath,
+		// scenarios are mounted as files, which bb reads via --scenario
+		"ScenarioDirectory": scenarioDirectory,
+		"ScenarioFile":      scenarioFile,
+	})
+}
+
+// args returns the command line a node is started with. Arguments are passed as flags of the same name, except for
+// those of strategies whose flags differ from their arguments, such as traffic-split and mirror, which take their
+// downstream services along with their arguments.
+func (t *Topology) args(node *Node, ports map[string]*nodePorts, host func(name string) string) ([]string, error) {
+	args := []string{node.Strategy}
+	for _, port := range []struct {
+		flag string
+		port int
+	}{{"--grpc-server-port", ports[node.Name].grpc}, {"--h1-server-port", ports[node.Name].h1}, {"--admin-port", ports[node.Name].admin}} {
+		if port.port != -1 {
+			args = append(args, port.flag, strconv.Itoa(port.port))
+		}
+	}
+
+	grpcAddresses, h1Addresses, byNode := t.downstreamAddresses(node, ports, host)
+	protocols := map[string]string{}
+	for _, downstream := range node.Downstreams {
+		protocols[downstream.Node] = t.protocolFor(downstream)
+	}
+
+	arguments := map[string]string{}
+	for name, value := range node.Arguments {
+		arguments[name] = value
+	}
+
+	switch node.Strategy {
+	case strategies.TrafficSplitStrategyName:
+		// every downstream service comes with its weight
+		for _, split := range strings.Split(arguments[strategies.TrafficSplitArgName], ",") {
+			separator := strings.LastIndex(split, "=")
+			if separator < 0 {
+				return nil, fmt.Errorf("split [%s] must be in the format ${node}=weight", split)
+			}
+			name, err := referencedNode(strings.TrimSpace(split[:separator]), byNode)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, "--"+strategies.TrafficSplitArgName, fmt.Sprintf("%s:%s=%s", protocols[name], byNode[name], strings.TrimSpace(split[separator+1:])))
+		}
+		delete(arguments, strategies.TrafficSplitArgName)
+	case strategies.MirrorStrategyName:
+		// shadows have flags of their own, primaries are regular downstream services
+		shadows := map[string]bool{}
+		for _, shadow := range strings.Split(arguments[strategies.MirrorShadowDownstreamsArgName], ",") {
+			if strings.TrimSpace(shadow) == "" {
+				continue
+			}
+			name, err := referencedNode(strings.TrimSpace(shadow), byNode)
+			if err != nil {
+				return nil, err
+			}
+			shadows[name] = true
+		}
+		delete(arguments, strategies.MirrorShadowDownstreamsArgName)
+
+		for _, downstream := range node.Downstreams {
+			flag := fmt.Sprintf("--%s-downstream-server", protocols[downstream.Node])
+			if shadows[downstream.Node] {
+				flag = fmt.Sprintf("--shadow-%s-downstream-server", protocols[downstream.Node])
+			}
+			args = append(args, flag, byNode[downstream.Node])
+		}
+	default:
+		for _, address := range grpcAddresses {
+			args = append(args, "--grpc-downstream-server", address)
+		}
+		for _, address := range h1Addresses {
+			args = append(args, "--h1-downstream-server", address)
+		}
+	}
+
+	if node.PercentFailure != 0 {
+		args = append(args, "--percent-failure", strconv.Itoa(node.PercentFailure))
+	}
+	for _, failureType := range node.FailureTypes {
+		args = append(args, "--failure-type", failureType)
+	}
+	for _, transportFault := range node.TransportFaults {
+		args = append(args, "--transport-fault", transportFault)
+	}
+	if node.SleepInMillis != 0 {
+		args = append(args, "--sleep-in-millis", strconv.Itoa(node.SleepInMillis))
+	}
+	if node.Latency != "" {
+		args = append(args, "--latency", node.Latency)
+	}
+	if node.CPUBurnInMillis != 0 {
+		args = append(args, "--cpu-burn-in-millis", strconv.Itoa(node.CPUBurnInMillis))
+	}
+	if node.MemoryPerRequestMB != 0 {
+		args = append(args, "--memory-per-request-mb", strconv.Itoa(node.MemoryPerRequestMB))
+	}
+	if node.MemoryHoldFor != 0 {
+		args = append(args, "--memory-hold-for", node.MemoryHoldFor.String())
+	}
+	if node.MemoryLeakMBPerMinute != 0 {
+		args = append(args, "--memory-leak-mb-per-minute", strconv.Itoa(node.MemoryLeakMBPerMinute))
+	}
+	if node.TerminateAfter != 0 {
+		args = append(args, "--terminate-after", strconv.Itoa(node.TerminateAfter))
+	}
+	if node.FireAndForget {
+		args = append(args, "--fire-and-forget")
+	}
+
+	argumentNames := make([]string, 0, len(arguments))
+	for name := range arguments {
+		argumentNames = append(argumentNames, name)
+	}
+	sort.Strings(argumentNames)
+
+	for _, name := range argumentNames {
+		value, err := replaceNodeReferences(arguments[name], byNode)
+		if err != nil {
+			return nil, fmt.Errorf("argument [%s]: %v", name, err)
+		}
+
+		// routes are joined by new lines, but each of them has its own flag
+		values := []string{value}
+		if name == strategies.RouterRoutesArgName {
+			values = strings.Split(value, "\n")
+		}
+		for _, v := range values {
+			if strings.TrimSpace(v) != "" {
+				args = append(args, "--"+name, v)
+			}
+		}
+	}
+	return args, nil
+}
+
+// referencedNode returns the name of the downstream node in a reference such as ${node}
+func referencedNode(reference string, addresses map[string]string) (string, error) {
+	match := singleNodeReference.FindStringSubmatch(reference)
+	if match == nil {
+		return "", fmt.Errorf("[%s] must be a reference to a downstream node, such as ${node}", reference)
+	}
+	if _, ok := addresses[match[1]]; !ok {
+		return "", fmt.Errorf("[%s] isn't one of the downstream nodes", match[1])
+	}
+	return match[1], nil
+}
+
+// indent indents every line of text by spaces, as needed to embed it in a YAML block scalar
+func indent(spaces int, text string) string {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.Repeat(" ", spaces) + line
+	}
+	return strings.Join(lines, "\n")
+}
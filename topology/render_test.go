@@ -0,0 +1,197 @@
+package topology
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderKubernetes(t *testing.T) {
+	t.Run("renders a namespace, deployment and service for every node", func(t *testing.T) {
+		topology, err := Parse(strings.NewReader("name: bb-readme\n" + readmeTopology))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		topology.Node("gateway").Replicas = 3
+		topology.Node("gateway").ServiceType = "LoadBalancer"
+
+		var out bytes.Buffer
+		err = topology.RenderKubernetes(&out, &RenderOptions{Image: "buoyantio/bb:test", Mesh: NoMesh})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		rendered := out.String()
+		expectedLines := []string{
+			"kind: Namespace\nmetadata:\n  name: bb-readme\n",
+			"  name: bb-readme-gateway\n  namespace: bb-readme\nspec:\n  replicas: 3\n",
+			"        image: buoyantio/bb:test\n",
+			`        args: ["point-to-point-channel", "--h1-server-port", "8080", "--grpc-downstream-server", "bb-readme-terminus-svc:9090"]`,
+			`        args: ["terminus", "--grpc-server-port", "9090", "--sleep-in-millis", "10", "--response-text", "BANANA"]`,
+			"  name: bb-readme-gateway-svc\n  namespace: bb-readme\nspec:\n  type: LoadBalancer\n",
+			"  - name: grpc\n    port: 9090\n    targetPort: 9090\n",
+			"        readinessProbe:\n          httpGet:\n            path: /readyz\n            port: 8080\n",
+			"        readinessProbe:\n          grpc:\n            port: 9090\n            service: buoyantio.bb.TheService\n",
+		}
+		for _, expected := range expectedLines {
+			if !strings.Contains(rendered, expected) {
+				t.Fatalf("Expected manifests to contain:\n%s\nbut got:\n%s", expected, rendered)
+			}
+		}
+
+		if strings.Contains(rendered, "annotations") {
+			t.Fatalf("Expected no annotations without a mesh, got:\n%s", rendered)
+		}
+	})
+
+	t.Run("annotates pods for mesh injection", func(t *testing.T) {
+		topology, err := Parse(strings.NewReader(readmeTopology))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		var out bytes.Buffer
+		err = topology.RenderKubernetes(&out, &RenderOptions{Namespace: "lab", Mesh: LinkerdMesh})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if strings.Count(out.String(), "      annotations:\n        linkerd.io/inject: \"enabled\"\n") != 2 {
+			t.Fatalf("Expected both pods to be annotated for injection, got:\n%s", out.String())
+		}
+	})
+
+	t.Run("mounts the scenario of a node from a config map", func(t *testing.T) {
+		topology, err := Parse(strings.NewReader(readmeTopology + "  scenario:\n    steps:\n    - duration: 1m30s\n      percent-failure: 30\n    - crash: true\n"))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		var out bytes.Buffer
+		err = topology.RenderKubernetes(&out, &RenderOptions{Namespace: "lab", Mesh: NoMesh})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		rendered := out.String()
+		expectedLines := []string{
+			"kind: ConfigMap\nmetadata:\n  name: lab-terminus-scenario\n  namespace: lab\ndata:\n  scenario.yaml: |\n    steps:\n        - duration: 1m30s\n          percent-failure: 30\n",
+			`"--response-text", "BANANA", "--scenario", "/etc/bb/scenario.yaml"]`,
+			"        volumeMounts:\n        - name: scenario\n          mountPath: /etc/bb\n      volumes:\n      - name: scenario\n        configMap:\n          name: lab-terminus-scenario\n",
+		}
+		for _, expected := range expectedLines {
+			if !strings.Contains(rendered, expected) {
+				t.Fatalf("Expected manifests to contain:\n%s\nbut got:\n%s", expected, rendered)
+			}
+		}
+
+		if strings.Count(rendered, "kind: ConfigMap") != 1 {
+			t.Fatalf("Expected a single config map, got:\n%s", rendered)
+		}
+	})
+
+	t.Run("returns error without a valid namespace or mesh", func(t *testing.T) {
+		topology, err := Parse(strings.NewReader(readmeTopology))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		for _, options := range []*RenderOptions{{Mesh: NoMesh}, {Namespace: "Lab", Mesh: NoMesh}, {Namespace: "lab", Mesh: "consul"}} {
+			err = topology.RenderKubernetes(&bytes.Buffer{}, options)
+			if err == nil {
+				t.Fatalf("Expecting error for options %+v, got nothing", options)
+			}
+		}
+	})
+}
+
+func TestArgs(t *testing.T) {
+	ports := map[string]*nodePorts{
+		"front": {grpc: -1, h1: 8080, admin: 9990},
+		"a":     {grpc: 9090, h1: -1, admin: -1},
+		"b":     {grpc: -1, h1: 8080, admin: -1},
+	}
+	host := func(name string) string { return name + "-svc" }
+
+	testCases := []struct {
+		name     string
+		node     *Node
+		expected []string
+	}{
+		{
+			name: "traffic-split takes its downstream services along with their weights",
+			node: &Node{
+				Name:        "front",
+				Strategy:    "traffic-split",
+				Downstreams: []*Downstream{{Node: "a"}, {Node: "b", Protocol: H1Protocol}},
+				Arguments:   map[string]string{"split": "${a}=90,${b}=10"},
+			},
+			expected: []string{"traffic-split", "--h1-server-port", "8080", "--admin-port", "9990", "--split", "grpc:a-svc:9090=90", "--split", "h1:http://b-svc:8080=10"},
+		},
+		{
+			name: "resource consumption is passed as flags",
+			node: &Node{
+				Name:                  "a",
+				Strategy:              "terminus",
+				CPUBurnInMillis:       50,
+				MemoryPerRequestMB:    2,
+				MemoryHoldFor:         time.Second * 30,
+				MemoryLeakMBPerMinute: 10,
+			},
+			expected: []string{"terminus", "--grpc-server-port", "9090", "--cpu-burn-in-millis", "50", "--memory-per-request-mb", "2", "--memory-hold-for", "30s", "--memory-leak-mb-per-minute", "10"},
+		},
+		{
+			name: "mirror takes shadows apart from its primary downstream services",
+			node: &Node{
+				Name:        "front",
+				Strategy:    "mirror",
+				Downstreams: []*Downstream{{Node: "a"}, {Node: "b", Protocol: H1Protocol}},
+				Arguments:   map[string]string{"shadow-downstream-servers": "${b}", "shadow-percentage": "50"},
+			},
+			expected: []string{"mirror", "--h1-server-port", "8080", "--admin-port", "9990", "--grpc-downstream-server", "a-svc:9090", "--shadow-h1-downstream-server", "http://b-svc:8080", "--shadow-percentage", "50"},
+		},
+		{
+			name: "router takes each route as a flag of its own",
+			node: &Node{
+				Name:           "front",
+				Strategy:       "router",
+				PercentFailure: 5,
+				Downstreams:    []*Downstream{{Node: "a"}, {Node: "b", Protocol: H1Protocol}},
+				Arguments:      map[string]string{"route": "path=^/a->${a}\npath=^/b->${b}\n", "default-route": "${a}"},
+			},
+			expected: []string{"router", "--h1-server-port", "8080", "--admin-port", "9990", "--grpc-downstream-server", "a-svc:9090", "--h1-downstream-server", "http://b-svc:8080", "--percent-failure", "5", "--default-route", "a-svc:9090", "--route", "path=^/a->a-svc:9090", "--route", "path=^/b->http://b-svc:8080"},
+		},
+	}
+
+	topology := &Topology{Nodes: []*Node{{Name: "a", Strategy: "terminus"}, {Name: "b", Strategy: "terminus"}}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			topology.Nodes = append(topology.Nodes[:2], tc.node)
+
+			args, err := topology.args(tc.node, ports, host)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if strings.Join(args, " ") != strings.Join(tc.expected, " ") {
+				t.Fatalf("Expected args %v, got %v", tc.expected, args)
+			}
+		})
+	}
+
+	t.Run("returns error when traffic-split refers to something other than a downstream node", func(t *testing.T) {
+		node := &Node{
+			Name:        "front",
+			Strategy:    "traffic-split",
+			Downstreams: []*Downstream{{Node: "a"}},
+			Arguments:   map[string]string{"split": "a-svc:9090=100"},
+		}
+		topology.Nodes = append(topology.Nodes[:2], node)
+
+		_, err := topology.args(node, ports, host)
+		if err == nil {
+			t.Fatalf("Expecting error, got nothing")
+		}
+	})
+}
@@ -0,0 +1,381 @@
+package topology
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/buoyantio/bb/scenario"
+	"github.com/buoyantio/bb/service"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// GRPCProtocol is used to reach a downstream node via its gRPC server
+	GRPCProtocol = "grpc"
+
+	// H1Protocol is used to reach a downstream node via its HTTP 1.1 server
+	H1Protocol = "h1"
+
+	adminServer = "admin"
+)
+
+// nodeReference matches ${node} in strategy arguments, which is replaced by the address used to reach that node
+var nodeReference = regexp.MustCompile(`\$\{([^}]*)\}`)
+
+// names matches valid node and topology names, which are used as Kubernetes resource names
+var names = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// Topology is a graph of bb services, declared in a YAML file
+type Topology struct {
+	Name  string  `yaml:"name"`
+	Nodes []*Node `yaml:"nodes"`
+}
+
+// Node is a single bb service within a Topology. Ports left unset are only bound if another node uses that protocol
+// to reach this one, and ports set to 0 are picked at random when running the topology. Replicas and ServiceType only
+// apply to rendered manifests.
+type Node struct {
+	Name                  string             `yaml:"name"`
+	Strategy              string             `yaml:"strategy"`
+	Replicas              int                `yaml:"replicas"`
+	ServiceType           string             `yaml:"service-type"`
+	GRPCServerPort        *int               `yaml:"grpc-server-port"`
+	H1ServerPort          *int               `yaml:"h1-server-port"`
+	AdminPort             *int               `yaml:"admin-port"`
+	PercentFailure        int                `yaml:"percent-failure"`
+	FailureTypes          []string           `yaml:"failure-types"`
+	TransportFaults       []string           `yaml:"transport-faults"`
+	SleepInMillis         int                `yaml:"sleep-in-millis"`
+	CPUBurnInMillis       int                `yaml:"cpu-burn-in-millis"`
+	MemoryPerRequestMB    int                `yaml:"memory-per-request-mb"`
+	MemoryHoldFor         time.Duration      `yaml:"memory-hold-for"`
+	MemoryLeakMBPerMinute int                `yaml:"memory-leak-mb-per-minute"`
+	Latency               string             `yaml:"latency"`
+	TerminateAfter        int                `yaml:"terminate-after"`
+	FireAndForget         bool               `yaml:"fire-and-forget"`
+	Downstreams           []*Downstream      `yaml:"downstreams"`
+	Arguments             map[string]string  `yaml:"arguments"`
+	Scenario              *scenario.Scenario `yaml:"scenario"`
+}
+
+// Downstream is a node that another node sends requests to
+type Downstream struct {
+	Node     string `yaml:"node"`
+	Protocol string `yaml:"protocol"`
+}
+
+// NodeConfig is the configuration a node is started with
+type NodeConfig struct {
+	Node   *Node
+	Config *service.Config
+}
+
+// ReadFile reads and validates the topology declared in the YAML file at path
+func ReadFile(path string) (*Topology, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Parse(f)
+}
+
+// Parse reads and validates a topology declared in YAML
+func Parse(r io.Reader) (*Topology, error) {
+	decoder := yaml.NewDecoder(r)
+	decoder.KnownFields(true)
+
+	var t Topology
+	if err := decoder.Decode(&t); err != nil {
+		return nil, fmt.Errorf("error parsing topology: %v", err)
+	}
+
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// Validate checks that every node is named, has a strategy, and only has downstreams that are other nodes, without
+// any cycles
+func (t *Topology) Validate() error {
+	if len(t.Nodes) == 0 {
+		return fmt.Errorf("topology has no nodes")
+	}
+
+	if t.Name != "" && !names.MatchString(t.Name) {
+		return fmt.Errorf("topology name [%s] must consist of lower case alphanumeric characters or '-'", t.Name)
+	}
+
+	seen := map[string]bool{}
+	for _, node := range t.Nodes {
+		if node.Name == "" {
+			return fmt.Errorf("every node must have a name")
+		}
+		if !names.MatchString(node.Name) {
+			return fmt.Errorf("node name [%s] must consist of lower case alphanumeric characters or '-'", node.Name)
+		}
+		if seen[node.Name] {
+			return fmt.Errorf("node [%s] is declared more than once", node.Name)
+		}
+		seen[node.Name] = true
+
+		if node.Strategy == "" {
+			return fmt.Errorf("node [%s] has no strategy", node.Name)
+		}
+		if node.Replicas < 0 {
+			return fmt.Errorf("node [%s] has [%d] replicas, must not be negative", node.Name, node.Replicas)
+		}
+		if node.CPUBurnInMillis < 0 || node.MemoryPerRequestMB < 0 || node.MemoryHoldFor < 0 || node.MemoryLeakMBPerMinute < 0 {
+			return fmt.Errorf("node [%s] has negative CPU burn, memory per request, memory hold time or memory leak", node.Name)
+		}
+		if _, err := service.NewLatencyDistribution(node.Latency, 0); err != nil {
+			return fmt.Errorf("node [%s]: %v", node.Name, err)
+		}
+		if _, err := service.NewFailureTypes(node.FailureTypes); err != nil {
+			return fmt.Errorf("node [%s]: %v", node.Name, err)
+		}
+		if _, err := service.NewTransportFaults(node.TransportFaults); err != nil {
+			return fmt.Errorf("node [%s]: %v", node.Name, err)
+		}
+		if node.Scenario != nil {
+			if err := node.Scenario.Validate(); err != nil {
+				return fmt.Errorf("node [%s] scenario: %v", node.Name, err)
+			}
+		}
+	}
+
+	for _, node := range t.Nodes {
+		for _, downstream := range node.Downstreams {
+			if !seen[downstream.Node] {
+				return fmt.Errorf("node [%s] has downstream [%s], which isn't a node", node.Name, downstream.Node)
+			}
+			if downstream.Protocol != "" && downstream.Protocol != GRPCProtocol && downstream.Protocol != H1Protocol {
+				return fmt.Errorf("node [%s] reaches [%s] via [%s], protocol must be [%s] or [%s]", node.Name, downstream.Node, downstream.Protocol, GRPCProtocol, H1Protocol)
+			}
+		}
+	}
+
+	_, err := t.StartOrder()
+	return err
+}
+
+// Node returns the node with this name, or nil if there isn't one
+func (t *Topology) Node(name string) *Node {
+	for _, node := range t.Nodes {
+		if node.Name == name {
+			return node
+		}
+	}
+	return nil
+}
+
+// StartOrder returns the nodes in the order they have to be started in, each one after all of its downstreams
+func (t *Topology) StartOrder() ([]*Node, error) {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := map[string]int{}
+	order := make([]*Node, 0, len(t.Nodes))
+
+	var visit func(node *Node, path []string) error
+	visit = func(node *Node, path []string) error {
+		path = append(path, node.Name)
+		switch state[node.Name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("topology has a cycle: %v", path)
+		}
+
+		state[node.Name] = visiting
+		for _, downstream := range node.Downstreams {
+			if err := visit(t.Node(downstream.Node), path); err != nil {
+				return err
+			}
+		}
+		state[node.Name] = visited
+		order = append(order, node)
+		return nil
+	}
+
+	for _, node := range t.Nodes {
+		if err := visit(node, nil); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// protocolFor returns the protocol used to reach a downstream node, gRPC unless the node only declares a HTTP 1.1
+// server
+func (t *Topology) protocolFor(downstream *Downstream) string {
+	if downstream.Protocol != "" {
+		return downstream.Protocol
+	}
+
+	node := t.Node(downstream.Node)
+	if node.GRPCServerPort == nil && node.H1ServerPort != nil {
+		return H1Protocol
+	}
+	return GRPCProtocol
+}
+
+// nodePorts holds the ports a node binds its servers to, -1 for those it doesn't start
+type nodePorts struct {
+	grpc  int
+	h1    int
+	admin int
+}
+
+// ports returns the ports of every node, by node name. A node starts the servers declared in the topology as well as
+// those other nodes use to reach it, and pick is called for each of them that has no port set.
+func (t *Topology) ports(pick func(server string) (int, error)) (map[string]*nodePorts, error) {
+	ports := map[string]*nodePorts{}
+	for _, node := range t.Nodes {
+		ports[node.Name] = &nodePorts{
+			grpc:  portOrUnset(node.GRPCServerPort),
+			h1:    portOrUnset(node.H1ServerPort),
+			admin: portOrUnset(node.AdminPort),
+		}
+	}
+
+	for _, node := range t.Nodes {
+		for _, downstream := range node.Downstreams {
+			downstreamPorts := ports[downstream.Node]
+			if t.protocolFor(downstream) == GRPCProtocol && downstreamPorts.grpc == -1 {
+				downstreamPorts.grpc = 0
+			}
+			if t.protocolFor(downstream) == H1Protocol && downstreamPorts.h1 == -1 {
+				downstreamPorts.h1 = 0
+			}
+		}
+	}
+
+	var err error
+	for _, node := range t.Nodes {
+		nodePorts := ports[node.Name]
+		servers := []string{GRPCProtocol, H1Protocol, adminServer}
+		for i, port := range []*int{&nodePorts.grpc, &nodePorts.h1, &nodePorts.admin} {
+			if *port != 0 {
+				continue
+			}
+			if *port, err = pick(servers[i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return ports, nil
+}
+
+// downstreamAddresses returns the addresses a node sends gRPC and HTTP 1.1 requests to, as well as the address of
+// each downstream node by name
+func (t *Topology) downstreamAddresses(node *Node, ports map[string]*nodePorts, host func(name string) string) ([]string, []string, map[string]string) {
+	grpcAddresses := []string{}
+	h1Addresses := []string{}
+	byNode := map[string]string{}
+	for _, downstream := range node.Downstreams {
+		if t.protocolFor(downstream) == GRPCProtocol {
+			address := fmt.Sprintf("%s:%d", host(downstream.Node), ports[downstream.Node].grpc)
+			grpcAddresses = append(grpcAddresses, address)
+			byNode[downstream.Node] = address
+		} else {
+			address := fmt.Sprintf("http://%s:%d", host(downstream.Node), ports[downstream.Node].h1)
+			h1Addresses = append(h1Addresses, address)
+			byNode[downstream.Node] = address
+		}
+	}
+	return grpcAddresses, h1Addresses, byNode
+}
+
+// Configs returns the configuration of each node, in the order they have to be started in. Every node starts from a
+// copy of base, which holds the settings shared by all nodes, such as timeouts and retries. Nodes only bind to the
+// loopback interface, and ports not set in the topology are picked via freePort. Replicas are ignored, as each node is
+// started only once.
+func (t *Topology) Configs(base *service.Config, freePort func() (int, error)) ([]*NodeConfig, error) {
+	order, err := t.StartOrder()
+	if err != nil {
+		return nil, err
+	}
+
+	ports, err := t.ports(func(string) (int, error) { return freePort() })
+	if err != nil {
+		return nil, err
+	}
+
+	loopback := func(string) string { return "127.0.0.1" }
+	configs := make([]*NodeConfig, 0, len(order))
+	for _, node := range order {
+		config := *base
+		config.ID = node.Name
+		config.GRPCServerPort = ports[node.Name].grpc
+		config.H1ServerPort = ports[node.Name].h1
+		config.AdminPort = ports[node.Name].admin
+		config.BindHost = "127.0.0.1"
+		config.PercentageFailedRequests = node.PercentFailure
+		config.FailureTypes = node.FailureTypes
+		config.TransportFaults = node.TransportFaults
+		config.SleepInMillis = node.SleepInMillis
+		config.CPUBurnInMillis = node.CPUBurnInMillis
+		config.MemoryPerRequestMB = node.MemoryPerRequestMB
+		config.MemoryHoldFor = node.MemoryHoldFor
+		config.MemoryLeakMBPerMinute = node.MemoryLeakMBPerMinute
+		config.Latency = node.Latency
+		config.TerminateAfter = node.TerminateAfter
+		config.FireAndForget = node.FireAndForget
+
+		var addresses map[string]string
+		config.GRPCDownstreamServers, config.H1DownstreamServers, addresses = t.downstreamAddresses(node, ports, loopback)
+
+		config.ExtraArguments = map[string]string{}
+		for name, value := range base.ExtraArguments {
+			config.ExtraArguments[name] = value
+		}
+		for name, value := range node.Arguments {
+			if config.ExtraArguments[name], err = replaceNodeReferences(value, addresses); err != nil {
+				return nil, fmt.Errorf("node [%s] argument [%s]: %v", node.Name, name, err)
+			}
+		}
+
+		configs = append(configs, &NodeConfig{Node: node, Config: &config})
+	}
+	return configs, nil
+}
+
+// replaceNodeReferences replaces every ${node} in value by the address used to reach that downstream node
+func replaceNodeReferences(value string, addresses map[string]string) (string, error) {
+	var err error
+	replaced := nodeReference.ReplaceAllStringFunc(value, func(reference string) string {
+		name := nodeReference.FindStringSubmatch(reference)[1]
+		address, ok := addresses[name]
+		if !ok && err == nil {
+			err = fmt.Errorf("[%s] isn't one of the downstream nodes", name)
+		}
+		return address
+	})
+	return replaced, err
+}
+
+func portOrUnset(port *int) int {
+	if port == nil {
+		return -1
+	}
+	return *port
+}
+
+// FreePort returns a port on the loopback interface that nothing is currently bound to
+func FreePort() (int, error) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer lis.Close()
+
+	return lis.Addr().(*net.TCPAddr).Port, nil
+}
@@ -0,0 +1,218 @@
+package topology
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/buoyantio/bb/service"
+)
+
+const readmeTopology = `
+nodes:
+- name: gateway
+  strategy: point-to-point-channel
+  h1-server-port: 8080
+  downstreams:
+  - node: terminus
+- name: terminus
+  strategy: terminus
+  sleep-in-millis: 10
+  arguments:
+    response-text: BANANA
+`
+
+func sequentialPorts(first int) func() (int, error) {
+	next := first
+	return func() (int, error) {
+		next++
+		return next - 1, nil
+	}
+}
+
+func TestParse(t *testing.T) {
+	t.Run("reads nodes, their settings and downstreams", func(t *testing.T) {
+		topology, err := Parse(strings.NewReader(readmeTopology))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if len(topology.Nodes) != 2 {
+			t.Fatalf("Expected 2 nodes, got %d", len(topology.Nodes))
+		}
+
+		gateway := topology.Node("gateway")
+		if gateway.Strategy != "point-to-point-channel" || *gateway.H1ServerPort != 8080 || gateway.GRPCServerPort != nil {
+			t.Fatalf("Unexpected gateway node: %+v", gateway)
+		}
+
+		if len(gateway.Downstreams) != 1 || gateway.Downstreams[0].Node != "terminus" {
+			t.Fatalf("Expected gateway to have downstream [terminus], got %+v", gateway.Downstreams)
+		}
+
+		terminus := topology.Node("terminus")
+		if terminus.SleepInMillis != 10 || terminus.Arguments["response-text"] != "BANANA" {
+			t.Fatalf("Unexpected terminus node: %+v", terminus)
+		}
+	})
+
+	t.Run("returns error for invalid topologies", func(t *testing.T) {
+		invalid := map[string]string{
+			"no nodes":                "nodes: []",
+			"unknown field":           "nodes:\n- name: a\n  strategy: terminus\n  colour: red",
+			"missing name":            "nodes:\n- strategy: terminus",
+			"invalid name":            "nodes:\n- name: Gateway_1\n  strategy: terminus",
+			"invalid topology name":   "name: My Lab\nnodes:\n- name: a\n  strategy: terminus",
+			"negative replicas":       "nodes:\n- name: a\n  strategy: terminus\n  replicas: -1",
+			"duplicated name":         "nodes:\n- name: a\n  strategy: terminus\n- name: a\n  strategy: terminus",
+			"missing strategy":        "nodes:\n- name: a",
+			"invalid latency":         "nodes:\n- name: a\n  strategy: terminus\n  latency: normal:mean=10ms",
+			"invalid transport fault": "nodes:\n- name: a\n  strategy: terminus\n  transport-faults: [drop:percent=5]",
+			"invalid scenario":        "nodes:\n- name: a\n  strategy: terminus\n  scenario:\n    steps:\n    - crash: true\n    - duration: 1m",
+			"unknown downstream":      "nodes:\n- name: a\n  strategy: point-to-point-channel\n  downstreams:\n  - node: b",
+			"unknown protocol":        "nodes:\n- name: a\n  strategy: point-to-point-channel\n  downstreams:\n  - node: b\n    protocol: h2\n- name: b\n  strategy: terminus",
+			"cycle":                   "nodes:\n- name: a\n  strategy: point-to-point-channel\n  downstreams:\n  - node: b\n- name: b\n  strategy: point-to-point-channel\n  downstreams:\n  - node: a",
+			"downstream to itself":    "nodes:\n- name: a\n  strategy: point-to-point-channel\n  downstreams:\n  - node: a",
+		}
+
+		for name, yaml := range invalid {
+			_, err := Parse(strings.NewReader(yaml))
+			if err == nil {
+				t.Fatalf("Expecting error for topology with %s, got nothing", name)
+			}
+		}
+	})
+}
+
+func TestStartOrder(t *testing.T) {
+	t.Run("starts every node after its downstreams", func(t *testing.T) {
+		topology := &Topology{Nodes: []*Node{
+			{Name: "gateway", Strategy: "broadcast-channel", Downstreams: []*Downstream{{Node: "a"}, {Node: "b"}}},
+			{Name: "a", Strategy: "point-to-point-channel", Downstreams: []*Downstream{{Node: "c"}}},
+			{Name: "b", Strategy: "point-to-point-channel", Downstreams: []*Downstream{{Node: "c"}}},
+			{Name: "c", Strategy: "terminus"},
+		}}
+
+		order, err := topology.StartOrder()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		names := make([]string, 0)
+		for _, node := range order {
+			names = append(names, node.Name)
+		}
+
+		expected := []string{"c", "a", "b", "gateway"}
+		if !reflect.DeepEqual(names, expected) {
+			t.Fatalf("Expected start order %v, got %v", expected, names)
+		}
+	})
+}
+
+func TestConfigs(t *testing.T) {
+	base := &service.Config{
+		ID:                "base",
+		DownstreamTimeout: time.Second,
+		RetryMaxAttempts:  3,
+		ExtraArguments:    map[string]string{"load-balancer": "random"},
+	}
+
+	t.Run("binds the servers other nodes need on free ports and points downstreams at them", func(t *testing.T) {
+		topology, err := Parse(strings.NewReader(readmeTopology))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		configs, err := topology.Configs(base, sequentialPorts(20000))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		terminus := configs[0].Config
+		if configs[0].Node.Name != "terminus" || terminus.ID != "terminus" {
+			t.Fatalf("Expected terminus to be started first, got %+v", configs[0].Node)
+		}
+
+		if terminus.GRPCServerPort != 20000 || terminus.H1ServerPort != -1 || terminus.AdminPort != -1 || terminus.BindHost != "127.0.0.1" {
+			t.Fatalf("Expected terminus to only bind gRPC port 20000, got %+v", terminus)
+		}
+
+		if terminus.SleepInMillis != 10 || terminus.ExtraArguments["response-text"] != "BANANA" {
+			t.Fatalf("Expected terminus to keep its settings, got %+v", terminus)
+		}
+
+		gateway := configs[1].Config
+		if gateway.H1ServerPort != 8080 || gateway.GRPCServerPort != -1 {
+			t.Fatalf("Expected gateway to only bind HTTP 1.1 port 8080, got %+v", gateway)
+		}
+
+		if !reflect.DeepEqual(gateway.GRPCDownstreamServers, []string{"127.0.0.1:20000"}) || len(gateway.H1DownstreamServers) != 0 {
+			t.Fatalf("Expected gateway to reach terminus via gRPC, got %+v", gateway)
+		}
+
+		if gateway.DownstreamTimeout != time.Second || gateway.RetryMaxAttempts != 3 || gateway.ExtraArguments["load-balancer"] != "random" {
+			t.Fatalf("Expected gateway to inherit the base settings, got %+v", gateway)
+		}
+
+		if base.ID != "base" || len(base.ExtraArguments) != 1 {
+			t.Fatalf("Expected base config to be left untouched, got %+v", base)
+		}
+	})
+
+	t.Run("reaches nodes via HTTP 1.1 when they only declare a HTTP 1.1 server", func(t *testing.T) {
+		h1Port := 0
+		topology := &Topology{Nodes: []*Node{
+			{Name: "gateway", Strategy: "point-to-point-channel", Downstreams: []*Downstream{{Node: "a"}, {Node: "b", Protocol: H1Protocol}}},
+			{Name: "a", Strategy: "terminus", H1ServerPort: &h1Port},
+			{Name: "b", Strategy: "terminus"},
+		}}
+
+		configs, err := topology.Configs(base, sequentialPorts(20000))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		gateway := configs[2].Config
+		expected := []string{"http://127.0.0.1:20000", "http://127.0.0.1:20001"}
+		if !reflect.DeepEqual(gateway.H1DownstreamServers, expected) || len(gateway.GRPCDownstreamServers) != 0 {
+			t.Fatalf("Expected HTTP 1.1 downstreams %v, got %+v", expected, gateway)
+		}
+	})
+
+	t.Run("replaces node references in arguments by their addresses", func(t *testing.T) {
+		topology := &Topology{Nodes: []*Node{
+			{
+				Name:        "split",
+				Strategy:    "traffic-split",
+				Downstreams: []*Downstream{{Node: "a"}, {Node: "b", Protocol: H1Protocol}},
+				Arguments:   map[string]string{"split": "${a}=90,${b}=10"},
+			},
+			{Name: "a", Strategy: "terminus"},
+			{Name: "b", Strategy: "terminus"},
+		}}
+
+		configs, err := topology.Configs(base, sequentialPorts(20000))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		expected := "127.0.0.1:20000=90,http://127.0.0.1:20001=10"
+		if split := configs[2].Config.ExtraArguments["split"]; split != expected {
+			t.Fatalf("Expected argument [%s], got [%s]", expected, split)
+		}
+	})
+
+	t.Run("returns error when arguments refer to a node that isn't a downstream", func(t *testing.T) {
+		topology := &Topology{Nodes: []*Node{
+			{Name: "a", Strategy: "router", Arguments: map[string]string{"default-route": "${b}"}},
+			{Name: "b", Strategy: "terminus"},
+		}}
+
+		_, err := topology.Configs(base, sequentialPorts(20000))
+		if err == nil {
+			t.Fatalf("Expecting error, got nothing")
+		}
+	})
+}
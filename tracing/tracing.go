@@ -0,0 +1,167 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/buoyantio/bb/service"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// NoExporter disables exporting spans, trace context is still propagated
+	NoExporter = "none"
+
+	// OTLPExporter exports spans to an OpenTelemetry collector using OTLP over HTTP
+	OTLPExporter = "otlp"
+
+	// FileExporter writes spans as JSON to a local file
+	FileExporter = "file"
+
+	// W3CPropagator propagates the W3C traceparent, tracestate and baggage headers
+	W3CPropagator = "w3c"
+
+	// B3Propagator propagates the multiple X-B3-* headers used by Zipkin
+	B3Propagator = "b3"
+
+	// B3SinglePropagator propagates the single b3 header
+	B3SinglePropagator = "b3-single"
+)
+
+// Exporters lists all supported span exporters
+var Exporters = []string{NoExporter, OTLPExporter, FileExporter}
+
+// Propagators lists all supported trace context propagation formats
+var Propagators = []string{W3CPropagator, B3Propagator, B3SinglePropagator}
+
+// Provider exports the spans recorded by the services running in this process, each of which has its own
+// TracerProvider so that its spans are reported under its own service name.
+type Provider struct {
+	exporter        sdktrace.SpanExporter
+	sampler         sdktrace.Sampler
+	file            *os.File
+	mu              sync.Mutex
+	tracerProviders []*sdktrace.TracerProvider
+}
+
+// sharedExporter lets several TracerProviders send spans to the same exporter, which is only shut down by the Provider
+type sharedExporter struct {
+	sdktrace.SpanExporter
+}
+
+func (e sharedExporter) Shutdown(context.Context) error { return nil }
+
+// TracerProvider returns a TracerProvider recording spans for the service named, and exporting them with the others
+func (p *Provider) TracerProvider(serviceName string) trace.TracerProvider {
+	tracerProvider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(sharedExporter{p.exporter}),
+		sdktrace.WithSampler(p.sampler),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName))),
+	)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.tracerProviders = append(p.tracerProviders, tracerProvider)
+	return tracerProvider
+}
+
+// Shutdown exports any spans still buffered and stops the exporter
+func (p *Provider) Shutdown() error {
+	log.Infof("Shutting down tracing")
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var err error
+	for _, tracerProvider := range p.tracerProviders {
+		if shutdownErr := tracerProvider.Shutdown(context.Background()); err == nil {
+			err = shutdownErr
+		}
+	}
+	if shutdownErr := p.exporter.Shutdown(context.Background()); err == nil {
+		err = shutdownErr
+	}
+	if p.file != nil {
+		if closeErr := p.file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// SetPropagator installs the configured trace context propagators, used by every service in this process.
+func SetPropagator(config *service.Config) error {
+	propagator, err := newPropagator(config.TracingPropagators)
+	if err != nil {
+		return err
+	}
+	otel.SetTextMapPropagator(propagator)
+	return nil
+}
+
+func newPropagator(names []string) (propagation.TextMapPropagator, error) {
+	propagators := make([]propagation.TextMapPropagator, 0)
+	for _, name := range names {
+		switch name {
+		case W3CPropagator:
+			propagators = append(propagators, propagation.TraceContext{}, propagation.Baggage{})
+		case B3Propagator:
+			propagators = append(propagators, b3.New(b3.WithInjectEncoding(b3.B3MultipleHeader)))
+		case B3SinglePropagator:
+			propagators = append(propagators, b3.New(b3.WithInjectEncoding(b3.B3SingleHeader)))
+		default:
+			return nil, fmt.Errorf("unknown trace propagator [%s], must be one of %v", name, Propagators)
+		}
+	}
+	return propagation.NewCompositeTextMapPropagator(propagators...), nil
+}
+
+func newExporter(config *service.Config) (sdktrace.SpanExporter, *os.File, error) {
+	switch config.TracingExporter {
+	case OTLPExporter:
+		exporter, err := otlptracehttp.New(context.Background(), otlptracehttp.WithEndpointURL(config.TracingOTLPEndpoint))
+		return exporter, nil, err
+	case FileExporter:
+		file, err := os.OpenFile(config.TracingFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, nil, err
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(file))
+		return exporter, file, err
+	default:
+		return nil, nil, fmt.Errorf("unknown tracing exporter [%s], must be one of %v", config.TracingExporter, Exporters)
+	}
+}
+
+// NewProviderIfConfigured returns a Provider sending spans to the configured exporter, or nil if there's none.
+func NewProviderIfConfigured(config *service.Config) (*Provider, error) {
+	if config.TracingExporter == NoExporter || config.TracingExporter == "" {
+		return nil, nil
+	}
+
+	if config.TracingSampleRatio < 0 || config.TracingSampleRatio > 1 {
+		return nil, fmt.Errorf("tracing sample ratio must be between 0 and 1, but was [%f]", config.TracingSampleRatio)
+	}
+
+	exporter, file, err := newExporter(config)
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Exporting spans using [%s]", config.TracingExporter)
+
+	return &Provider{
+		exporter: exporter,
+		sampler:  sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.TracingSampleRatio)),
+		file:     file,
+	}, nil
+}
@@ -0,0 +1,117 @@
+package tracing
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/buoyantio/bb/service"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+func TestSetPropagator(t *testing.T) {
+	defer otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+
+	t.Run("installs propagators for every configured format", func(t *testing.T) {
+		err := SetPropagator(&service.Config{TracingPropagators: []string{W3CPropagator, B3Propagator}})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		carrier := propagation.MapCarrier{
+			"x-b3-traceid": "0af7651916cd43dd8448eb211c80319c",
+			"x-b3-spanid":  "b7ad6b7169203331",
+			"x-b3-sampled": "1",
+		}
+		ctx := otel.GetTextMapPropagator().Extract(context.TODO(), carrier)
+
+		injected := propagation.MapCarrier{}
+		otel.GetTextMapPropagator().Inject(ctx, injected)
+
+		expectedTraceparent := "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+		if injected.Get("traceparent") != expectedTraceparent {
+			t.Fatalf("Expected B3 trace context to be injected as traceparent [%s], but got %v", expectedTraceparent, injected)
+		}
+
+		if injected.Get("x-b3-traceid") != "0af7651916cd43dd8448eb211c80319c" {
+			t.Fatalf("Expected B3 trace context to be injected as B3 headers, but got %v", injected)
+		}
+	})
+
+	t.Run("returns error if misconfigured", func(t *testing.T) {
+		err := SetPropagator(&service.Config{TracingPropagators: []string{"jaeger"}})
+		if err == nil {
+			t.Fatalf("Expecting error, got nothing")
+		}
+	})
+}
+
+func TestNewProviderIfConfigured(t *testing.T) {
+	t.Run("returns no provider without an exporter", func(t *testing.T) {
+		provider, err := NewProviderIfConfigured(&service.Config{TracingExporter: NoExporter})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if provider != nil {
+			t.Fatalf("Expected no provider without an exporter, but got [%v]", provider)
+		}
+	})
+
+	t.Run("exports the spans of each service as JSON to a file, under its own name", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "traces.json")
+		provider, err := NewProviderIfConfigured(&service.Config{
+			TracingExporter:    FileExporter,
+			TracingFile:        file,
+			TracingSampleRatio: 1,
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		for _, serviceName := range []string{"first-service", "second-service"} {
+			_, span := provider.TracerProvider(serviceName).Tracer("test").Start(context.TODO(), "span-of-"+serviceName)
+			span.End()
+		}
+
+		if err := provider.Shutdown(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		contents, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		for _, line := range strings.Split(strings.TrimSpace(string(contents)), "\n") {
+			for _, serviceName := range []string{"first-service", "second-service"} {
+				if strings.Contains(line, "span-of-"+serviceName) && !strings.Contains(line, "\"Value\":\""+serviceName+"\"") {
+					t.Fatalf("Expected span [span-of-%s] to be reported under service [%s], but got:\n%s", serviceName, serviceName, line)
+				}
+			}
+		}
+
+		for _, serviceName := range []string{"first-service", "second-service"} {
+			if !strings.Contains(string(contents), "\"Name\":\"span-of-"+serviceName+"\"") {
+				t.Fatalf("Expected file to contain span [span-of-%s], but got:\n%s", serviceName, contents)
+			}
+		}
+	})
+
+	t.Run("returns error if misconfigured", func(t *testing.T) {
+		invalidConfigs := []*service.Config{
+			{TracingExporter: "zipkin", TracingSampleRatio: 1},
+			{TracingExporter: FileExporter, TracingSampleRatio: 2},
+		}
+
+		for _, config := range invalidConfigs {
+			_, err := NewProviderIfConfigured(config)
+			if err == nil {
+				t.Fatalf("Expecting error for config %+v, got nothing", config)
+			}
+		}
+	})
+}